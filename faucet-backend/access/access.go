@@ -0,0 +1,388 @@
+// Package access implements the faucet's block and allow lists of account
+// addresses, client IP ranges and API keys.
+package access
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// List is the name of a list.
+type List string
+
+const (
+	// Blocked is the list of denied addresses, IP ranges and API keys.
+	Blocked List = "blocked"
+	// Allowed is the list of trusted addresses, IP ranges and API keys.
+	Allowed List = "allowed"
+)
+
+// Reason is the kind of entry that matched a request.
+type Reason string
+
+const (
+	// ReasonAddress is an account address entry.
+	ReasonAddress Reason = "address"
+	// ReasonIP is a client IP range entry.
+	ReasonIP Reason = "ip"
+	// ReasonAPIKey is an API key entry.
+	ReasonAPIKey Reason = "api_key"
+)
+
+// Entry is a list entry, matching exactly one of an account address, an IP
+// range or an API key.
+type Entry struct {
+	// Address is the account address (Oasis or Ethereum).
+	Address string `json:"address,omitempty"`
+	// CIDR is the client IP range, or a single IP.
+	CIDR string `json:"cidr,omitempty"`
+	// APIKey is the API key.
+	APIKey string `json:"api_key,omitempty"`
+
+	// Expires is when the entry expires, or nil if it never does.
+	Expires *time.Time `json:"expires,omitempty"`
+	// Comment is a human readable note, eg: why the entry was added.
+	Comment string `json:"comment,omitempty"`
+
+	address *types.Address
+	ipNet   *net.IPNet
+}
+
+func (e *Entry) validate() error {
+	var n int
+	for _, s := range []string{e.Address, e.CIDR, e.APIKey} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return fmt.Errorf("access: entry must have exactly one of address, cidr and api_key")
+	}
+
+	switch {
+	case e.Address != "":
+		addr, _, err := helpers.ResolveEthOrOasisAddress(e.Address)
+		if err != nil || addr == nil {
+			return fmt.Errorf("access: malformed address: '%s'", e.Address)
+		}
+		e.address = addr
+	case e.CIDR != "":
+		cidr := e.CIDR
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return fmt.Errorf("access: malformed IP: '%s'", cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("access: malformed CIDR: '%s'", e.CIDR)
+		}
+		e.ipNet = ipNet
+	}
+	return nil
+}
+
+// key returns the address, CIDR or API key that the entry matches.
+func (e *Entry) key() string {
+	switch {
+	case e.Address != "":
+		return e.Address
+	case e.CIDR != "":
+		return e.CIDR
+	default:
+		return e.APIKey
+	}
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return e.Expires != nil && !now.Before(*e.Expires)
+}
+
+// match returns the reason the entry matches the request, if it does.
+func (e *Entry) match(addr *types.Address, ip net.IP, apiKey string) (Reason, bool) {
+	switch {
+	case e.address != nil:
+		return ReasonAddress, addr != nil && e.address.Equal(*addr)
+	case e.ipNet != nil:
+		return ReasonIP, ip != nil && e.ipNet.Contains(ip)
+	default:
+		return ReasonAPIKey, apiKey != "" && subtle.ConstantTimeCompare([]byte(e.APIKey), []byte(apiKey)) == 1
+	}
+}
+
+// Lists are the block and allow lists.
+type Lists struct {
+	Blocked []*Entry `json:"blocked"`
+	Allowed []*Entry `json:"allowed"`
+}
+
+func (l *Lists) list(list List) (*[]*Entry, error) {
+	switch list {
+	case Blocked:
+		return &l.Blocked, nil
+	case Allowed:
+		return &l.Allowed, nil
+	default:
+		return nil, fmt.Errorf("access: unknown list: '%s'", list)
+	}
+}
+
+func (l *Lists) validate() error {
+	for _, entries := range [][]*Entry{l.Blocked, l.Allowed} {
+		for _, e := range entries {
+			if err := e.validate(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prune removes the expired entries.
+func (l *Lists) prune(now time.Time) {
+	for _, entries := range []*[]*Entry{&l.Blocked, &l.Allowed} {
+		kept := (*entries)[:0]
+		for _, e := range *entries {
+			if !e.expired(now) {
+				kept = append(kept, e)
+			}
+		}
+		*entries = kept
+	}
+}
+
+// Decision is the outcome of checking a request against the lists.
+type Decision struct {
+	// Blocked is set if the request matches the block list.
+	Blocked bool
+	// Allowed is set if the request matches the allow list, and not the
+	// block list.
+	Allowed bool
+	// Reason is the kind of entry that matched, if any.
+	Reason Reason
+}
+
+// Store is the persistent block and allow lists, stored as a JSON file.
+type Store struct {
+	mu sync.RWMutex
+
+	path string
+	log  *log.Logger
+
+	lists   Lists
+	modTime time.Time
+}
+
+// NewStore creates a new store backed by the file at path, which is
+// created on the first modification if it does not exist.
+func NewStore(path string, logger *log.Logger) (*Store, error) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	s := &Store{
+		path: path,
+		log:  logger,
+	}
+	if _, err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload reloads the lists if the file has changed, and returns true iff
+// it did.
+func (s *Store) reload() (bool, error) {
+	fi, err := os.Stat(s.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("access: failed to stat lists: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if fi.ModTime().Equal(s.modTime) {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, fmt.Errorf("access: failed to read lists: %w", err)
+	}
+	var lists Lists
+	if err = json.Unmarshal(b, &lists); err != nil {
+		return false, fmt.Errorf("access: failed to parse lists: %w", err)
+	}
+	if err = lists.validate(); err != nil {
+		return false, err
+	}
+
+	s.lists = lists
+	s.modTime = fi.ModTime()
+	return true, nil
+}
+
+// save writes the lists to the file.  The caller must hold the lock.
+func (s *Store) save() error {
+	s.lists.prune(time.Now())
+
+	b, err := json.MarshalIndent(&s.lists, "", "  ")
+	if err != nil {
+		return fmt.Errorf("access: failed to encode lists: %w", err)
+	}
+	tmpPath := s.path + ".tmp"
+	if err = os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("access: failed to create directory: %w", err)
+	}
+	if err = os.WriteFile(tmpPath, b, 0o600); err != nil {
+		return fmt.Errorf("access: failed to write lists: %w", err)
+	}
+	if err = os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("access: failed to replace lists: %w", err)
+	}
+
+	if fi, err := os.Stat(s.path); err == nil {
+		s.modTime = fi.ModTime()
+	}
+	return nil
+}
+
+// Watch reloads the lists whenever the file changes, polling every
+// interval until the context is canceled.
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := s.reload()
+		switch {
+		case err != nil:
+			s.log.Printf("access: failed to reload lists: %v", err)
+		case reloaded:
+			s.log.Printf("access: reloaded lists")
+		}
+	}
+}
+
+// Lists returns a copy of the unexpired entries of the lists.
+func (s *Store) Lists() Lists {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	var lists Lists
+	for _, e := range s.lists.Blocked {
+		if !e.expired(now) {
+			lists.Blocked = append(lists.Blocked, e)
+		}
+	}
+	for _, e := range s.lists.Allowed {
+		if !e.expired(now) {
+			lists.Allowed = append(lists.Allowed, e)
+		}
+	}
+	return lists
+}
+
+// Add adds the entry to the list, replacing any entry for the same
+// address, CIDR or API key, and saves the lists.
+func (s *Store) Add(list List, entry *Entry) error {
+	if err := entry.validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.lists.list(list)
+	if err != nil {
+		return err
+	}
+	for i, e := range *entries {
+		if e.key() == entry.key() {
+			(*entries)[i] = entry
+			return s.save()
+		}
+	}
+	*entries = append(*entries, entry)
+	return s.save()
+}
+
+// Remove removes the entry for the address, CIDR or API key from the list,
+// saves the lists, and returns true iff there was such an entry.
+func (s *Store) Remove(list List, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := s.lists.list(list)
+	if err != nil {
+		return false, err
+	}
+	for i, e := range *entries {
+		if e.key() == key {
+			*entries = append((*entries)[:i], (*entries)[i+1:]...)
+			return true, s.save()
+		}
+	}
+	return false, nil
+}
+
+// Check checks a request for the account address from the client IP, with
+// the API key, against the lists.  The block list takes precedence.  Any
+// of the arguments may be empty.  Checking against a nil store always
+// returns an empty decision.
+func (s *Store) Check(addr *types.Address, ip net.IP, apiKey string) Decision {
+	if s == nil {
+		return Decision{}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	for _, e := range s.lists.Blocked {
+		if reason, ok := e.match(addr, ip, apiKey); ok && !e.expired(now) {
+			return Decision{
+				Blocked: true,
+				Reason:  reason,
+			}
+		}
+	}
+	for _, e := range s.lists.Allowed {
+		if reason, ok := e.match(addr, ip, apiKey); ok && !e.expired(now) {
+			return Decision{
+				Allowed: true,
+				Reason:  reason,
+			}
+		}
+	}
+	return Decision{}
+}
@@ -0,0 +1,209 @@
+package access
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+const (
+	testOasisAddress = "oasis1qrvsa8ukfw3p6kw2vcs0fk9t59mceqq7fyttwqgx"
+	testEthAddress   = "0x90adE3B7065fa715c7a150313877dF1d33e777D5"
+)
+
+func testAddress(t *testing.T, s string) *types.Address {
+	t.Helper()
+
+	addr, _, err := helpers.ResolveEthOrOasisAddress(s)
+	if err != nil {
+		t.Fatalf("failed to resolve address '%s': %v", s, err)
+	}
+	return addr
+}
+
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "access", "access.json")
+	s, err := NewStore(path, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	return s, path
+}
+
+func TestEntryValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		entry Entry
+		valid bool
+	}{
+		{"OasisAddress", Entry{Address: testOasisAddress}, true},
+		{"EthAddress", Entry{Address: testEthAddress}, true},
+		{"IPv4", Entry{CIDR: "192.0.2.1"}, true},
+		{"IPv6", Entry{CIDR: "2001:db8::1"}, true},
+		{"CIDR", Entry{CIDR: "192.0.2.0/24"}, true},
+		{"APIKey", Entry{APIKey: "key"}, true},
+		{"Empty", Entry{}, false},
+		{"Several", Entry{Address: testOasisAddress, APIKey: "key"}, false},
+		{"MalformedAddress", Entry{Address: "oasis1bogus"}, false},
+		{"MalformedIP", Entry{CIDR: "192.0.2"}, false},
+		{"MalformedCIDR", Entry{CIDR: "192.0.2.0/33"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.entry.validate(); (err == nil) != tc.valid {
+				t.Fatalf("validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	expired := time.Now().Add(-time.Minute)
+	for _, tc := range []struct {
+		list  List
+		entry *Entry
+	}{
+		{Blocked, &Entry{Address: testOasisAddress}},
+		{Blocked, &Entry{CIDR: "192.0.2.0/24"}},
+		{Allowed, &Entry{CIDR: "2001:db8::1"}},
+		{Allowed, &Entry{APIKey: "trusted"}},
+		{Allowed, &Entry{Address: testEthAddress}},
+		{Allowed, &Entry{APIKey: "stale", Expires: &expired}},
+	} {
+		if err := s.Add(tc.list, tc.entry); err != nil {
+			t.Fatalf("Add(%s, %+v): %v", tc.list, tc.entry, err)
+		}
+	}
+	if err := s.Add("bogus", &Entry{APIKey: "key"}); err == nil {
+		t.Errorf("Add: unknown list accepted")
+	}
+
+	for _, tc := range []struct {
+		name     string
+		addr     *types.Address
+		ip       net.IP
+		apiKey   string
+		expected Decision
+	}{
+		{"None", nil, nil, "", Decision{}},
+		{"Unlisted", testAddress(t, testEthAddress[:len(testEthAddress)-1]+"6"), net.ParseIP("198.51.100.1"), "unknown", Decision{}},
+		{"BlockedAddress", testAddress(t, testOasisAddress), nil, "", Decision{Blocked: true, Reason: ReasonAddress}},
+		{"BlockedIP", nil, net.ParseIP("192.0.2.42"), "", Decision{Blocked: true, Reason: ReasonIP}},
+		{"AllowedIP", nil, net.ParseIP("2001:db8::1"), "", Decision{Allowed: true, Reason: ReasonIP}},
+		{"AllowedAPIKey", nil, nil, "trusted", Decision{Allowed: true, Reason: ReasonAPIKey}},
+		{"AllowedAddress", testAddress(t, testEthAddress), nil, "", Decision{Allowed: true, Reason: ReasonAddress}},
+		{"ExpiredAPIKey", nil, nil, "stale", Decision{}},
+		{"BlockedTakesPrecedence", nil, net.ParseIP("192.0.2.1"), "trusted", Decision{Blocked: true, Reason: ReasonIP}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if d := s.Check(tc.addr, tc.ip, tc.apiKey); d != tc.expected {
+				t.Fatalf("Check: got %+v, expected %+v", d, tc.expected)
+			}
+		})
+	}
+
+	var nilStore *Store
+	if d := nilStore.Check(testAddress(t, testOasisAddress), nil, ""); d != (Decision{}) {
+		t.Errorf("Check nil store: got %+v, expected an empty decision", d)
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	s, path := newTestStore(t)
+
+	// Adding an entry for the same key replaces it.
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for _, entry := range []*Entry{
+		{APIKey: "key", Comment: "first"},
+		{APIKey: "key", Comment: "second", Expires: &expires},
+	} {
+		if err := s.Add(Allowed, entry); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	lists := s.Lists()
+	if len(lists.Allowed) != 1 || lists.Allowed[0].Comment != "second" {
+		t.Fatalf("Lists: unexpected allow list: %+v", lists.Allowed)
+	}
+
+	// The lists are persisted.
+	reopened, err := NewStore(path, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	lists = reopened.Lists()
+	if len(lists.Allowed) != 1 || lists.Allowed[0].Comment != "second" || !lists.Allowed[0].Expires.Equal(expires) {
+		t.Fatalf("Lists: unexpected reopened allow list: %+v", lists.Allowed)
+	}
+	if d := reopened.Check(nil, nil, "key"); !d.Allowed {
+		t.Errorf("Check: reopened entry does not match: %+v", d)
+	}
+
+	for _, tc := range []struct {
+		list    List
+		key     string
+		removed bool
+	}{
+		{Blocked, "key", false},
+		{Allowed, "key", true},
+		{Allowed, "key", false},
+	} {
+		removed, err := s.Remove(tc.list, tc.key)
+		if err != nil || removed != tc.removed {
+			t.Fatalf("Remove(%s, %s): got %v (%v), expected %v", tc.list, tc.key, removed, err, tc.removed)
+		}
+	}
+	if d := s.Check(nil, nil, "key"); d.Allowed {
+		t.Errorf("Check: removed entry matches: %+v", d)
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, path := newTestStore(t)
+	if err := s.Add(Blocked, &Entry{CIDR: "192.0.2.1"}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	// Changes made to the file by other means are picked up.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx, 10*time.Millisecond)
+
+	b := []byte(`{"blocked":[],"allowed":[{"cidr":"192.0.2.1"}]}`)
+	future := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("failed to write lists: %v", err)
+	}
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("failed to touch lists: %v", err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for !s.Check(nil, net.ParseIP("192.0.2.1"), "").Allowed {
+		if time.Now().After(deadline) {
+			t.Fatalf("lists not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Invalid files are rejected.
+	for _, b := range []string{
+		`{"blocked":[{"cidr":"bogus"}]}`,
+		`not json`,
+	} {
+		if err := os.WriteFile(path, []byte(b), 0o600); err != nil {
+			t.Fatalf("failed to write lists: %v", err)
+		}
+		if _, err := NewStore(path, nil); err == nil {
+			t.Errorf("NewStore: invalid lists accepted: %s", b)
+		}
+	}
+}
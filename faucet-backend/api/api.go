@@ -0,0 +1,218 @@
+// Package api defines the request and response types of the faucet HTTP
+// API, shared by the faucet and its clients.
+package api
+
+import (
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+)
+
+// HTTP API endpoints.
+const (
+	PathFundV1         = "/api/v1/fund"
+	PathBundleV1       = "/api/v1/bundle"
+	PathStatusV1       = "/api/v1/status"
+	PathStatusStreamV1 = "/api/v1/status/stream"
+	PathPayoutsV1      = "/api/v1/payouts"
+	PathBalanceV1      = "/api/v1/balance"
+
+	PathFundV2    = "/api/v2/fund"
+	PathBundleV2  = "/api/v2/bundle"
+	PathStatusV2  = "/api/v2/status/"
+	PathBalanceV2 = "/api/v2/balance"
+	PathInfoV2    = "/api/v2/info"
+	PathOpenAPIV2 = "/api/v2/openapi.json"
+)
+
+// HeaderAPIKey is the HTTP header, and the gRPC metadata key, carrying the
+// client's API key, if any.
+const HeaderAPIKey = "X-API-Key"
+
+// HTTP API query parameters.
+const (
+	QueryParaTime = "paratime"
+	QueryAccount  = "account"
+	QueryAmount   = "amount"
+)
+
+// Machine readable API error codes.  These are part of the API, and must
+// not be changed once released.
+const (
+	ErrCodeInvalidRequest   = "invalid_request"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeNotAcceptable    = "not_acceptable"
+	ErrCodeUnsupportedMedia = "unsupported_media_type"
+	ErrCodeInvalidParaTime  = "invalid_paratime"
+	ErrCodeInvalidAccount   = "invalid_account"
+	ErrCodeInvalidAmount    = "invalid_amount"
+	ErrCodeMissingAmount    = "missing_amount"
+	ErrCodeAmountTooLarge   = "amount_too_large"
+	ErrCodeAmountTooSmall   = "amount_too_small"
+	ErrCodeInvalidBundle    = "invalid_bundle"
+	ErrCodeCaptchaFailed    = "captcha_failed"
+	ErrCodeAccountFunded    = "account_funded"
+	ErrCodeAccessDenied     = "access_denied"
+	ErrCodeRequestPending   = "request_pending"
+	ErrCodeQuotaExceeded    = "quota_exceeded"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeUnavailable      = "unavailable"
+	ErrCodeInternal         = "internal_error"
+)
+
+// Error is the JSON encoded error model.
+type Error struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	// RetryAfter is the number of seconds to wait before retrying.
+	RetryAfter uint64 `json:"retry_after,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// FundParams are the user supplied parameters of a funding request.
+type FundParams struct {
+	// ParaTime is the paratime name, or empty for consensus.
+	ParaTime string `json:"paratime,omitempty"`
+	// Account is the account to fund.
+	Account string `json:"account"`
+	// Amount is the amount to fund in tokens, or empty for the default.
+	Amount string `json:"amount,omitempty"`
+	// CaptchaResponse is the user's reCAPTCHA response, if required.
+	CaptchaResponse string `json:"captcha_response,omitempty"`
+}
+
+// BundleParams are the user supplied parameters of a bundle request.
+type BundleParams struct {
+	// Bundle is the bundle name.
+	Bundle string `json:"bundle"`
+	// Account is the account to fund.
+	Account string `json:"account"`
+	// CaptchaResponse is the user's reCAPTCHA response, if required.
+	CaptchaResponse string `json:"captcha_response,omitempty"`
+}
+
+// FundResponse is the JSON encoded response of the funding endpoints.
+type FundResponse struct {
+	Result    string `json:"result"`
+	RequestID string `json:"request_id,omitempty"`
+	DryRun    bool   `json:"dry_run,omitempty"`
+
+	// Amount is the formatted amount to be funded, and AmountReason is
+	// the reason why it is less than requested, if it is.
+	Amount       string `json:"amount,omitempty"`
+	AmountReason string `json:"amount_reason,omitempty"`
+
+	// Error is the machine readable error, on failure.  Result contains
+	// the same message, for backward compatibility.
+	Error *Error `json:"error,omitempty"`
+}
+
+// RequestState is the state of a funding request.
+type RequestState string
+
+const (
+	RequestQueued    RequestState = "queued"
+	RequestSigned    RequestState = "signed"
+	RequestSubmitted RequestState = "submitted"
+	RequestIncluded  RequestState = "included"
+	RequestConfirmed RequestState = "confirmed"
+	RequestFailed    RequestState = "failed"
+)
+
+// IsTerminal returns true iff the state is final.
+func (s RequestState) IsTerminal() bool {
+	return s == RequestConfirmed || s == RequestFailed
+}
+
+// RequestStatus is a snapshot of the progress of a funding request.
+type RequestStatus struct {
+	ID     string       `json:"id"`
+	State  RequestState `json:"state"`
+	Reason string       `json:"reason,omitempty"`
+
+	TxHash string `json:"tx_hash,omitempty"`
+	Height int64  `json:"height,omitempty"`
+	Round  uint64 `json:"round,omitempty"`
+
+	// DryRun is set if the request was processed in dry-run mode, in
+	// which case SignedTx is the CBOR encoded signed transaction that
+	// would have been submitted.
+	DryRun   bool   `json:"dry_run,omitempty"`
+	SignedTx []byte `json:"signed_tx,omitempty"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// Payout is an anonymized record of a successful funding request.
+type Payout struct {
+	Time     time.Time `json:"time"`
+	ParaTime string    `json:"paratime,omitempty"`
+	Account  string    `json:"account"`
+	Amount   string    `json:"amount"`
+	Fee      string    `json:"fee,omitempty"`
+	DryRun   bool      `json:"dry_run,omitempty"`
+}
+
+// BalanceResponse is the JSON encoded response of the balance endpoint.
+type BalanceResponse struct {
+	ParaTime string `json:"paratime,omitempty"`
+	Account  string `json:"account"`
+
+	// Balance is the formatted balance.
+	Balance string `json:"balance"`
+	// BaseUnits is the balance in base units.
+	BaseUnits quantity.Quantity `json:"base_units"`
+}
+
+// InfoResponse is the JSON encoded response of the info endpoint.
+type InfoResponse struct {
+	// ChainContext is the chain context of the funded network.
+	ChainContext string `json:"chain_context"`
+	// Address is the faucet's funding address.
+	Address string `json:"address"`
+	// DryRun is set if the faucet is running in dry-run mode.
+	DryRun bool `json:"dry_run,omitempty"`
+	// CaptchaRequired is set if funding requests must carry a reCAPTCHA
+	// response.
+	CaptchaRequired bool `json:"captcha_required"`
+
+	// Consensus is the funding information for the consensus layer.
+	Consensus *FundingInfo `json:"consensus"`
+	// ParaTimes is the funding information for each paratime, by name.
+	ParaTimes map[string]*FundingInfo `json:"paratimes"`
+	// Bundles are the names of the available bundles.
+	Bundles []string `json:"bundles,omitempty"`
+}
+
+// FrontendConfig is the runtime configuration injected into the
+// frontend's pages.
+type FrontendConfig struct {
+	// CaptchaSiteKey is the reCAPTCHA site key, if any.
+	CaptchaSiteKey string `json:"captcha_site_key,omitempty"`
+	// ParaTimes are the names of the supported paratimes.
+	ParaTimes []string `json:"paratimes"`
+}
+
+// FundingInfo is the funding information for the consensus layer or a
+// paratime.  Amounts are formatted.
+type FundingInfo struct {
+	Symbol   string `json:"symbol"`
+	Decimals uint8  `json:"decimals"`
+
+	// DefaultAmount is the current effective amount funded if the request
+	// does not specify one, if any.
+	DefaultAmount string `json:"default_amount,omitempty"`
+	// MinAmount is the minimum amount that may be requested, if any.
+	MinAmount string `json:"min_amount,omitempty"`
+	// MaxAmount is the current effective maximum amount that will be
+	// funded per request, if any.
+	MaxAmount string `json:"max_amount,omitempty"`
+	// PayoutFactor is the factor the default and maximum amounts are
+	// currently scaled by due to low reserves.
+	PayoutFactor float64 `json:"payout_factor"`
+}
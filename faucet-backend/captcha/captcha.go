@@ -0,0 +1,121 @@
+// Package captcha implements verification of CAPTCHA responses.
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const recaptchaAPIURL = "https://www.google.com/recaptcha/api/siteverify"
+
+// ErrBusy is the error returned when too many verifications are in
+// progress.
+var ErrBusy = errors.New("captcha: too many concurrent verifications")
+
+// Verifier verifies users' CAPTCHA responses.
+type Verifier interface {
+	// Verify returns nil iff the user's CAPTCHA response is valid.  The
+	// user's IP address is passed along if known.
+	Verify(ctx context.Context, userResponse string, remoteIP net.IP) error
+}
+
+// limitedVerifier is a verifier with a cap on concurrent verifications.
+type limitedVerifier struct {
+	verifier Verifier
+	sem      chan struct{}
+}
+
+// NewLimited wraps the verifier so that at most n verifications are in
+// progress at once.  Verifications beyond the cap fail with ErrBusy,
+// rather than queueing up behind a slow API.
+func NewLimited(verifier Verifier, n int) Verifier {
+	return &limitedVerifier{
+		verifier: verifier,
+		sem:      make(chan struct{}, n),
+	}
+}
+
+// Verify verifies the user's CAPTCHA response, if under the cap.
+func (v *limitedVerifier) Verify(ctx context.Context, userResponse string, remoteIP net.IP) error {
+	select {
+	case v.sem <- struct{}{}:
+	default:
+		return ErrBusy
+	}
+	defer func() { <-v.sem }()
+
+	return v.verifier.Verify(ctx, userResponse, remoteIP)
+}
+
+// RecaptchaV2Response is the reCAPTCHA V2 siteverify API response.
+type RecaptchaV2Response struct {
+	Success     bool     `json:"success"`
+	ChallengeTS string   `json:"challenge_ts"`
+	Hostname    string   `json:"hostname"`
+	ErrorCodes  []string `json:"error-codes,omitempty"`
+}
+
+// Recaptcha is the reCAPTCHA V2 verifier.
+type Recaptcha struct {
+	secret     string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewRecaptcha creates a new reCAPTCHA V2 verifier with the shared secret,
+// using the HTTP client (Default: http.DefaultClient) to query the API.
+func NewRecaptcha(secret string, httpClient *http.Client) *Recaptcha {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Recaptcha{
+		secret:     secret,
+		apiURL:     recaptchaAPIURL,
+		httpClient: httpClient,
+	}
+}
+
+// Verify verifies the user's reCAPTCHA response.
+func (r *Recaptcha) Verify(ctx context.Context, userResponse string, remoteIP net.IP) error {
+	form := url.Values{
+		"secret":   {r.secret},
+		"response": {userResponse},
+	}
+	if remoteIP != nil {
+		form.Set("remoteip", remoteIP.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("recaptcha: failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("recaptcha: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("recaptcha: failed to read body: %w", err)
+	}
+
+	var apiResponse RecaptchaV2Response
+	if err = json.Unmarshal(b, &apiResponse); err != nil {
+		return fmt.Errorf("recaptcha: failed to parse response: %w", err)
+	}
+
+	if !apiResponse.Success {
+		return fmt.Errorf("recaptcha: verification failed")
+	}
+
+	return nil
+}
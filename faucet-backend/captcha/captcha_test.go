@@ -0,0 +1,133 @@
+package captcha
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testSecret = "test-secret"
+
+// blockingVerifier is a verifier whose verifications wait to be released.
+type blockingVerifier struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (v *blockingVerifier) Verify(context.Context, string, net.IP) error {
+	v.started <- struct{}{}
+	<-v.release
+	return nil
+}
+
+// newTestRecaptcha creates a verifier against a fake siteverify API, that
+// accepts the `valid` response from any address except 192.0.2.1.
+func newTestRecaptcha(t *testing.T) *Recaptcha {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.FormValue("secret") != testSecret {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var resp RecaptchaV2Response
+		switch {
+		case req.FormValue("response") == "malformed":
+			_, _ = w.Write([]byte("not json"))
+			return
+		case req.FormValue("response") != "valid":
+			resp.ErrorCodes = []string{"invalid-input-response"}
+		case req.FormValue("remoteip") == "192.0.2.1":
+			resp.ErrorCodes = []string{"remoteip-mismatch"}
+		default:
+			resp.Success = true
+		}
+		_ = json.NewEncoder(w).Encode(&resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	r := NewRecaptcha(testSecret, srv.Client())
+	r.apiURL = srv.URL
+	return r
+}
+
+func TestRecaptcha(t *testing.T) {
+	r := newTestRecaptcha(t)
+
+	for _, tc := range []struct {
+		name     string
+		response string
+		remoteIP net.IP
+		valid    bool
+	}{
+		{"Valid", "valid", net.ParseIP("198.51.100.1"), true},
+		{"ValidNoIP", "valid", nil, true},
+		{"Invalid", "invalid", nil, false},
+		{"RemoteIP", "valid", net.ParseIP("192.0.2.1"), false},
+		{"Malformed", "malformed", nil, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := r.Verify(context.Background(), tc.response, tc.remoteIP); (err == nil) != tc.valid {
+				t.Fatalf("Verify: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+
+	// Bad secrets are rejected by the API.
+	bad := NewRecaptcha("bogus", r.httpClient)
+	bad.apiURL = r.apiURL
+	if err := bad.Verify(context.Background(), "valid", nil); err == nil {
+		t.Errorf("Verify: bad secret accepted")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := r.Verify(ctx, "valid", nil); err == nil {
+		t.Errorf("Verify: canceled verification succeeded")
+	}
+}
+
+func TestLimited(t *testing.T) {
+	v := &blockingVerifier{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	limited := NewLimited(v, 2)
+
+	// Verifications beyond the cap fail fast.
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- limited.Verify(context.Background(), "valid", nil)
+		}()
+		<-v.started
+	}
+	if err := limited.Verify(context.Background(), "valid", nil); !errors.Is(err, ErrBusy) {
+		t.Fatalf("Verify over the cap: got error %v, expected %v", err, ErrBusy)
+	}
+
+	// Completed verifications free up their slot.
+	v.release <- struct{}{}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	go func() {
+		errCh <- limited.Verify(context.Background(), "valid", nil)
+	}()
+	<-v.started
+	close(v.release)
+	for i := 0; i < 2; i++ {
+		if err := <-errCh; err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+
+	// Errors from the wrapped verifier are passed through.
+	if err := NewLimited(newTestRecaptcha(t), 1).Verify(context.Background(), "invalid", nil); err == nil || errors.Is(err, ErrBusy) {
+		t.Errorf("Verify invalid: unexpected error: %v", err)
+	}
+}
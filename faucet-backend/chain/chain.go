@@ -0,0 +1,204 @@
+// Package chain implements the faucet's view of the consensus layer and
+// the paratimes.
+package chain
+
+import (
+	"context"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/connection"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+)
+
+// Backend is the narrow view of the consensus layer and the paratimes
+// that the bank needs to fund requests.
+type Backend interface {
+	// ChainContext returns the consensus chain context.
+	ChainContext(ctx context.Context) (string, error)
+
+	// ConsensusAccount returns the consensus account at the latest
+	// height, including the nonce, balance and allowances.
+	ConsensusAccount(ctx context.Context, addr staking.Address) (*staking.Account, error)
+	// EstimateConsensusGas estimates the gas required by a consensus
+	// transaction.
+	EstimateConsensusGas(ctx context.Context, req *consensus.EstimateGasRequest) (consensusTx.Gas, error)
+	// SubmitConsensusTx submits a consensus transaction, without waiting
+	// for it to be included in a block.
+	SubmitConsensusTx(ctx context.Context, tx *consensusTx.SignedTransaction) error
+	// WatchConsensusBlocks yields the height of each new consensus block
+	// until the context is canceled, at which point the channel is closed.
+	WatchConsensusBlocks(ctx context.Context) (<-chan int64, error)
+	// ConsensusTransactionsWithResults returns the transactions, and their
+	// results, included in the consensus block at the given height.
+	ConsensusTransactionsWithResults(ctx context.Context, height int64) (*consensus.TransactionsWithResults, error)
+
+	// RuntimeAccountBalance returns the paratime account's native
+	// denomination balance.
+	RuntimeAccountBalance(ctx context.Context, pt *config.ParaTime, addr types.Address) (*quantity.Quantity, error)
+	// RuntimeNonce returns the paratime account nonce.
+	RuntimeNonce(ctx context.Context, pt *config.ParaTime, addr types.Address) (uint64, error)
+	// EstimateRuntimeGas estimates the gas required by a paratime
+	// transaction.
+	EstimateRuntimeGas(ctx context.Context, pt *config.ParaTime, tx *types.Transaction) (uint64, error)
+	// RuntimeMinGasPrice returns the paratime's minimum gas prices.
+	RuntimeMinGasPrice(ctx context.Context, pt *config.ParaTime) (map[types.Denomination]types.Quantity, error)
+	// SubmitRuntimeTx submits a paratime transaction, and waits for it to
+	// be included in a block.
+	SubmitRuntimeTx(ctx context.Context, pt *config.ParaTime, tx *types.UnverifiedTransaction) (*client.SubmitTxRawMeta, error)
+	// WatchRuntimeRounds yields each new paratime round until the context
+	// is canceled, at which point the channel is closed.
+	WatchRuntimeRounds(ctx context.Context, pt *config.ParaTime) (<-chan uint64, error)
+	// LatestRuntimeRound returns the latest paratime round.
+	LatestRuntimeRound(ctx context.Context, pt *config.ParaTime) (uint64, error)
+	// RuntimeEvents returns the raw events emitted in the given round.
+	RuntimeEvents(ctx context.Context, pt *config.ParaTime, round uint64) ([]*types.Event, error)
+}
+
+// EventDecoderFunc adapts an event decoding function to client.EventDecoder.
+type EventDecoderFunc func(*types.Event) ([]client.DecodedEvent, error)
+
+func (fn EventDecoderFunc) DecodeEvent(ev *types.Event) ([]client.DecodedEvent, error) {
+	return fn(ev)
+}
+
+// connectionBackend is the Backend backed by a gRPC connection to an
+// Oasis node.
+type connectionBackend struct {
+	conn connection.Connection
+}
+
+// NewConnectionBackend creates a new Backend backed by the gRPC connection
+// to an Oasis node.
+func NewConnectionBackend(conn connection.Connection) Backend {
+	return &connectionBackend{
+		conn: conn,
+	}
+}
+
+func (b *connectionBackend) ChainContext(ctx context.Context) (string, error) {
+	return b.conn.Consensus().GetChainContext(ctx)
+}
+
+func (b *connectionBackend) ConsensusAccount(ctx context.Context, addr staking.Address) (*staking.Account, error) {
+	return b.conn.Consensus().Staking().Account(ctx, &staking.OwnerQuery{
+		Height: consensus.HeightLatest,
+		Owner:  addr,
+	})
+}
+
+func (b *connectionBackend) EstimateConsensusGas(ctx context.Context, req *consensus.EstimateGasRequest) (consensusTx.Gas, error) {
+	return b.conn.Consensus().EstimateGas(ctx, req)
+}
+
+func (b *connectionBackend) SubmitConsensusTx(ctx context.Context, tx *consensusTx.SignedTransaction) error {
+	return b.conn.Consensus().SubmitTxNoWait(ctx, tx)
+}
+
+func (b *connectionBackend) WatchConsensusBlocks(ctx context.Context) (<-chan int64, error) {
+	blkCh, blkSub, err := b.conn.Consensus().WatchBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan int64)
+	go func() {
+		defer close(ch)
+		defer blkSub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case blk, ok := <-blkCh:
+				if !ok {
+					return
+				}
+				select {
+				case ch <- blk.Height:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *connectionBackend) ConsensusTransactionsWithResults(ctx context.Context, height int64) (*consensus.TransactionsWithResults, error) {
+	return b.conn.Consensus().GetTransactionsWithResults(ctx, height)
+}
+
+func (b *connectionBackend) RuntimeAccountBalance(ctx context.Context, pt *config.ParaTime, addr types.Address) (*quantity.Quantity, error) {
+	balances, err := b.conn.Runtime(pt).Accounts.Balances(ctx, client.RoundLatest, addr)
+	if err != nil {
+		return nil, err
+	}
+	balance := balances.Balances[types.NativeDenomination]
+	return &balance, nil
+}
+
+func (b *connectionBackend) RuntimeNonce(ctx context.Context, pt *config.ParaTime, addr types.Address) (uint64, error) {
+	return b.conn.Runtime(pt).Accounts.Nonce(ctx, client.RoundLatest, addr)
+}
+
+func (b *connectionBackend) EstimateRuntimeGas(ctx context.Context, pt *config.ParaTime, tx *types.Transaction) (uint64, error) {
+	return b.conn.Runtime(pt).Core.EstimateGas(ctx, client.RoundLatest, tx, false)
+}
+
+func (b *connectionBackend) RuntimeMinGasPrice(ctx context.Context, pt *config.ParaTime) (map[types.Denomination]types.Quantity, error) {
+	return b.conn.Runtime(pt).Core.MinGasPrice(ctx)
+}
+
+func (b *connectionBackend) SubmitRuntimeTx(ctx context.Context, pt *config.ParaTime, tx *types.UnverifiedTransaction) (*client.SubmitTxRawMeta, error) {
+	return b.conn.Runtime(pt).SubmitTxRawMeta(ctx, tx)
+}
+
+func (b *connectionBackend) WatchRuntimeRounds(ctx context.Context, pt *config.ParaTime) (<-chan uint64, error) {
+	blkCh, blkSub, err := b.conn.Runtime(pt).WatchBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan uint64)
+	go func() {
+		defer close(ch)
+		defer blkSub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case blk, ok := <-blkCh:
+				if !ok {
+					return
+				}
+				select {
+				case ch <- blk.Block.Header.Round:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+func (b *connectionBackend) LatestRuntimeRound(ctx context.Context, pt *config.ParaTime) (uint64, error) {
+	blk, err := b.conn.Runtime(pt).GetBlock(ctx, client.RoundLatest)
+	if err != nil {
+		return 0, err
+	}
+	return blk.Header.Round, nil
+}
+
+func (b *connectionBackend) RuntimeEvents(ctx context.Context, pt *config.ParaTime, round uint64) ([]*types.Event, error) {
+	return b.conn.Runtime(pt).GetEventsRaw(ctx, round)
+}
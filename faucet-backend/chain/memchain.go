@@ -0,0 +1,769 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	"github.com/oasisprotocol/oasis-core/go/common/errors"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+const (
+	// memoryChainConsensusGas is the gas used by every consensus
+	// transaction on the memory chain.
+	memoryChainConsensusGas = 1000
+	// memoryChainRuntimeGas is the gas used by every paratime transaction
+	// on the memory chain.
+	memoryChainRuntimeGas = 10_000
+
+	// memoryChainSubBuffer is the number of undelivered blocks buffered
+	// per subscriber.
+	memoryChainSubBuffer = 64
+
+	// Error codes of the paratime core module.
+	runtimeCoreModule         = "core"
+	runtimeErrMalformedTx     = 1
+	runtimeErrInvalidMethod   = 3
+	runtimeErrInvalidNonce    = 4
+	runtimeErrInsufficientFee = 5
+
+	// Error codes of the paratime accounts module.
+	runtimeErrInsufficientBalance = 2
+)
+
+// MemoryChainFailures are the failures injected by a memory chain.
+type MemoryChainFailures struct {
+	// CheckTxRate is the fraction of transactions that fail the
+	// transaction check.
+	CheckTxRate float64
+	// TimeoutRate is the fraction of transactions that are accepted, but
+	// never included in a block.
+	TimeoutRate float64
+	// EmptyAllowance makes allowance changes have no effect, so that all
+	// paratime deposits fail.
+	EmptyAllowance bool
+}
+
+type memoryChainFailure int
+
+const (
+	memoryChainNoFailure memoryChainFailure = iota
+	memoryChainCheckTxFailure
+	memoryChainTimeout
+)
+
+// MemoryChain is an in-memory Backend that simulates just enough of
+// the consensus layer and the paratimes for the bank to run against it.
+// Balances, nonces and allowances are enforced, and every transaction is
+// executed in a block of its own.
+type MemoryChain struct {
+	lock sync.Mutex
+
+	network      *config.Network
+	chainContext string
+
+	accounts  map[staking.Address]*staking.Account
+	blocks    []*consensus.TransactionsWithResults
+	blockSubs map[chan int64]struct{}
+
+	runtimes map[string]*memoryRuntime
+
+	depositDelay time.Duration
+	failures     MemoryChainFailures
+	rng          *rand.Rand
+}
+
+// memoryRuntime is the state of a paratime on the memory chain.
+type memoryRuntime struct {
+	balances     map[types.Address]*quantity.Quantity
+	tokens       map[types.Denomination]map[types.Address]*quantity.Quantity
+	nonces       map[types.Address]uint64
+	minGasPrices map[types.Denomination]types.Quantity
+
+	// rounds are the events emitted in each round, indexed by round.
+	rounds    [][]*types.Event
+	roundSubs map[chan uint64]struct{}
+}
+
+// NewMemoryChain creates a new, empty, memory chain for the given network.
+func NewMemoryChain(network *config.Network) *MemoryChain {
+	return &MemoryChain{
+		network:      network,
+		chainContext: hash.NewFromBytes([]byte("faucet-backend memory chain")).String(),
+		accounts:     make(map[staking.Address]*staking.Account),
+		blockSubs:    make(map[chan int64]struct{}),
+		runtimes:     make(map[string]*memoryRuntime),
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetDepositDelay sets the delay between a paratime deposit transaction
+// being included, and the consensus layer processing the deposit.
+func (c *MemoryChain) SetDepositDelay(delay time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.depositDelay = delay
+}
+
+// SetFailures sets the failures injected by the memory chain.
+func (c *MemoryChain) SetFailures(failures MemoryChainFailures) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.failures = failures
+}
+
+// injectFailure decides which failure, if any, to inject into a
+// transaction.
+func (c *MemoryChain) injectFailure() memoryChainFailure {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch r := c.rng.Float64(); {
+	case r < c.failures.CheckTxRate:
+		return memoryChainCheckTxFailure
+	case r < c.failures.CheckTxRate+c.failures.TimeoutRate:
+		return memoryChainTimeout
+	default:
+		return memoryChainNoFailure
+	}
+}
+
+// SetConsensusBalance sets the general balance of a consensus account.
+func (c *MemoryChain) SetConsensusBalance(addr staking.Address, amount quantity.Quantity) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.account(addr).General.Balance = amount
+}
+
+// ConsensusBalance returns the general balance of a consensus account.
+func (c *MemoryChain) ConsensusBalance(addr staking.Address) quantity.Quantity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return *c.account(addr).General.Balance.Clone()
+}
+
+// Allowance returns the allowance the owner has given to the paratime.
+func (c *MemoryChain) Allowance(owner staking.Address, pt *config.ParaTime) quantity.Quantity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	allowance := c.account(owner).General.Allowances[staking.NewRuntimeAddress(pt.Namespace())]
+	return *allowance.Clone()
+}
+
+// SetRuntimeBalance sets the native denomination balance of a paratime
+// account.
+func (c *MemoryChain) SetRuntimeBalance(pt *config.ParaTime, addr types.Address, amount quantity.Quantity) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.runtime(pt).balances[addr] = amount.Clone()
+}
+
+// RuntimeBalance returns the native denomination balance of a paratime
+// account.
+func (c *MemoryChain) RuntimeBalance(pt *config.ParaTime, addr types.Address) quantity.Quantity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return *c.runtime(pt).balance(addr).Clone()
+}
+
+// SetRuntimeTokenBalance sets the balance of a paratime account in a
+// denomination other than the native one.
+func (c *MemoryChain) SetRuntimeTokenBalance(pt *config.ParaTime, addr types.Address, denom types.Denomination, amount quantity.Quantity) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	*c.runtime(pt).tokenBalance(addr, denom) = *amount.Clone()
+}
+
+// RuntimeTokenBalance returns the balance of a paratime account in a
+// denomination other than the native one.
+func (c *MemoryChain) RuntimeTokenBalance(pt *config.ParaTime, addr types.Address, denom types.Denomination) quantity.Quantity {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return *c.runtime(pt).tokenBalance(addr, denom).Clone()
+}
+
+// SetRuntimeMinGasPrice sets the minimum gas price of a paratime.
+func (c *MemoryChain) SetRuntimeMinGasPrice(pt *config.ParaTime, price quantity.Quantity) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.runtime(pt).minGasPrices[types.NativeDenomination] = price
+}
+
+func (c *MemoryChain) account(addr staking.Address) *staking.Account {
+	acct := c.accounts[addr]
+	if acct == nil {
+		acct = &staking.Account{
+			General: staking.GeneralAccount{
+				Allowances: make(map[staking.Address]quantity.Quantity),
+			},
+		}
+		c.accounts[addr] = acct
+	}
+	return acct
+}
+
+func (c *MemoryChain) runtime(pt *config.ParaTime) *memoryRuntime {
+	rt := c.runtimes[pt.ID]
+	if rt == nil {
+		rt = &memoryRuntime{
+			balances:     make(map[types.Address]*quantity.Quantity),
+			tokens:       make(map[types.Denomination]map[types.Address]*quantity.Quantity),
+			nonces:       make(map[types.Address]uint64),
+			minGasPrices: make(map[types.Denomination]types.Quantity),
+			rounds:       [][]*types.Event{nil}, // Genesis.
+			roundSubs:    make(map[chan uint64]struct{}),
+		}
+		c.runtimes[pt.ID] = rt
+	}
+	return rt
+}
+
+func (rt *memoryRuntime) balance(addr types.Address) *quantity.Quantity {
+	q := rt.balances[addr]
+	if q == nil {
+		q = quantity.NewQuantity()
+		rt.balances[addr] = q
+	}
+	return q
+}
+
+// tokenBalance returns the balance of an account in the denomination,
+// which may be the native one.
+func (rt *memoryRuntime) tokenBalance(addr types.Address, denom types.Denomination) *quantity.Quantity {
+	if denom == types.NativeDenomination {
+		return rt.balance(addr)
+	}
+	balances := rt.tokens[denom]
+	if balances == nil {
+		balances = make(map[types.Address]*quantity.Quantity)
+		rt.tokens[denom] = balances
+	}
+	q := balances[addr]
+	if q == nil {
+		q = quantity.NewQuantity()
+		balances[addr] = q
+	}
+	return q
+}
+
+// finishRound finalizes a round with the given events, and notifies the
+// subscribers.
+func (rt *memoryRuntime) finishRound(evs []*types.Event) uint64 {
+	rt.rounds = append(rt.rounds, evs)
+	round := uint64(len(rt.rounds) - 1)
+	for ch := range rt.roundSubs {
+		select {
+		case ch <- round:
+		default:
+		}
+	}
+	return round
+}
+
+func (c *MemoryChain) ChainContext(ctx context.Context) (string, error) {
+	return c.chainContext, nil
+}
+
+func (c *MemoryChain) ConsensusAccount(ctx context.Context, addr staking.Address) (*staking.Account, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	acct := c.account(addr)
+	cpy := &staking.Account{
+		General: staking.GeneralAccount{
+			Balance:    *acct.General.Balance.Clone(),
+			Nonce:      acct.General.Nonce,
+			Allowances: make(map[staking.Address]quantity.Quantity),
+		},
+	}
+	for k, v := range acct.General.Allowances {
+		cpy.General.Allowances[k] = *v.Clone()
+	}
+	return cpy, nil
+}
+
+func (c *MemoryChain) EstimateConsensusGas(ctx context.Context, req *consensus.EstimateGasRequest) (consensusTx.Gas, error) {
+	return memoryChainConsensusGas, nil
+}
+
+func (c *MemoryChain) SubmitConsensusTx(ctx context.Context, sigTx *consensusTx.SignedTransaction) error {
+	var tx consensusTx.Transaction
+	if err := cbor.Unmarshal(sigTx.Blob, &tx); err != nil {
+		return fmt.Errorf("memchain: malformed transaction: %w", err)
+	}
+	from := staking.NewAddress(sigTx.Signature.PublicKey)
+
+	switch c.injectFailure() {
+	case memoryChainCheckTxFailure:
+		return fmt.Errorf("memchain: injected transaction check failure")
+	case memoryChainTimeout:
+		// Accept the transaction, and never include it.
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Check the transaction.
+	acct := c.account(from)
+	if tx.Nonce != acct.General.Nonce {
+		return fmt.Errorf("memchain: invalid nonce: expected %d, got %d", acct.General.Nonce, tx.Nonce)
+	}
+	if tx.Fee == nil {
+		return fmt.Errorf("memchain: missing fee")
+	}
+	if err := acct.General.Balance.Sub(&tx.Fee.Amount); err != nil {
+		return fmt.Errorf("memchain: insufficient balance to pay fees")
+	}
+	acct.General.Nonce++
+
+	// Execute the transaction, and finalize a block with it.
+	c.blocks = append(c.blocks, &consensus.TransactionsWithResults{
+		Transactions: [][]byte{cbor.Marshal(sigTx)},
+		Results:      []*results.Result{c.executeConsensusTx(from, acct, &tx)},
+	})
+	height := int64(len(c.blocks))
+	for ch := range c.blockSubs {
+		select {
+		case ch <- height:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (c *MemoryChain) executeConsensusTx(from staking.Address, acct *staking.Account, tx *consensusTx.Transaction) *results.Result {
+	switch tx.Method {
+	case staking.MethodTransfer:
+		var xfer staking.Transfer
+		if err := cbor.Unmarshal(tx.Body, &xfer); err != nil {
+			return consensusFailure(staking.ErrInvalidArgument)
+		}
+		if err := acct.General.Balance.Sub(&xfer.Amount); err != nil {
+			return consensusFailure(staking.ErrInsufficientBalance)
+		}
+		to := c.account(xfer.To)
+		_ = to.General.Balance.Add(&xfer.Amount)
+
+		return &results.Result{
+			Events: []*results.Event{{
+				Staking: &staking.Event{
+					Transfer: &staking.TransferEvent{
+						From:   from,
+						To:     xfer.To,
+						Amount: xfer.Amount,
+					},
+				},
+			}},
+		}
+	case staking.MethodAllow:
+		var allow staking.Allow
+		if err := cbor.Unmarshal(tx.Body, &allow); err != nil {
+			return consensusFailure(staking.ErrInvalidArgument)
+		}
+		allowance := acct.General.Allowances[allow.Beneficiary]
+		switch {
+		case c.failures.EmptyAllowance:
+		case allow.Negative:
+			if err := allowance.Sub(&allow.AmountChange); err != nil {
+				allowance = *quantity.NewQuantity()
+			}
+		default:
+			_ = allowance.Add(&allow.AmountChange)
+		}
+		acct.General.Allowances[allow.Beneficiary] = allowance
+
+		return &results.Result{
+			Events: []*results.Event{{
+				Staking: &staking.Event{
+					AllowanceChange: &staking.AllowanceChangeEvent{
+						Owner:        from,
+						Beneficiary:  allow.Beneficiary,
+						Allowance:    allowance,
+						Negative:     allow.Negative,
+						AmountChange: allow.AmountChange,
+					},
+				},
+			}},
+		}
+	default:
+		return consensusFailure(staking.ErrInvalidArgument)
+	}
+}
+
+func consensusFailure(err error) *results.Result {
+	module, code := errors.Code(err)
+	return &results.Result{
+		Error: results.Error{
+			Module:  module,
+			Code:    code,
+			Message: err.Error(),
+		},
+	}
+}
+
+func (c *MemoryChain) WatchConsensusBlocks(ctx context.Context) (<-chan int64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	ch := make(chan int64, memoryChainSubBuffer)
+	c.blockSubs[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(c.blockSubs, ch)
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (c *MemoryChain) ConsensusTransactionsWithResults(ctx context.Context, height int64) (*consensus.TransactionsWithResults, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if height < 1 || height > int64(len(c.blocks)) {
+		return nil, fmt.Errorf("memchain: no block at height %d", height)
+	}
+	return c.blocks[height-1], nil
+}
+
+func (c *MemoryChain) RuntimeAccountBalance(ctx context.Context, pt *config.ParaTime, addr types.Address) (*quantity.Quantity, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.runtime(pt).balance(addr).Clone(), nil
+}
+
+func (c *MemoryChain) RuntimeNonce(ctx context.Context, pt *config.ParaTime, addr types.Address) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.runtime(pt).nonces[addr], nil
+}
+
+func (c *MemoryChain) EstimateRuntimeGas(ctx context.Context, pt *config.ParaTime, tx *types.Transaction) (uint64, error) {
+	return memoryChainRuntimeGas, nil
+}
+
+func (c *MemoryChain) RuntimeMinGasPrice(ctx context.Context, pt *config.ParaTime) (map[types.Denomination]types.Quantity, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	prices := make(map[types.Denomination]types.Quantity)
+	for k, v := range c.runtime(pt).minGasPrices {
+		prices[k] = *v.Clone()
+	}
+	return prices, nil
+}
+
+func (c *MemoryChain) SubmitRuntimeTx(ctx context.Context, pt *config.ParaTime, utx *types.UnverifiedTransaction) (*client.SubmitTxRawMeta, error) {
+	checkTxFailure := func(code uint32, msg string) (*client.SubmitTxRawMeta, error) {
+		return &client.SubmitTxRawMeta{
+			TransactionMeta: client.TransactionMeta{
+				CheckTxError: &client.CheckTxError{
+					Module:  runtimeCoreModule,
+					Code:    code,
+					Message: msg,
+				},
+			},
+		}, nil
+	}
+
+	var tx types.Transaction
+	if err := cbor.Unmarshal(utx.Body, &tx); err != nil || len(tx.AuthInfo.SignerInfo) != 1 {
+		return checkTxFailure(runtimeErrMalformedTx, "malformed transaction")
+	}
+	from, err := tx.AuthInfo.SignerInfo[0].AddressSpec.Address()
+	if err != nil {
+		return checkTxFailure(runtimeErrMalformedTx, "malformed transaction")
+	}
+	nonce := tx.AuthInfo.SignerInfo[0].Nonce
+
+	switch c.injectFailure() {
+	case memoryChainCheckTxFailure:
+		return checkTxFailure(runtimeErrMalformedTx, "injected transaction check failure")
+	case memoryChainTimeout:
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Check the transaction.
+	rt := c.runtime(pt)
+	if nonce != rt.nonces[from] {
+		return checkTxFailure(runtimeErrInvalidNonce, "invalid nonce")
+	}
+	if tx.AuthInfo.Fee.Amount.Denomination != types.NativeDenomination {
+		return checkTxFailure(runtimeErrInsufficientFee, "insufficient balance to pay fees")
+	}
+	if err = rt.balance(from).Sub(&tx.AuthInfo.Fee.Amount.Amount); err != nil {
+		return checkTxFailure(runtimeErrInsufficientFee, "insufficient balance to pay fees")
+	}
+	rt.nonces[from]++
+
+	// Execute the transaction.
+	callFailure := func(module string, code uint32, msg string) (*client.SubmitTxRawMeta, error) {
+		return &client.SubmitTxRawMeta{
+			TransactionMeta: client.TransactionMeta{
+				Round: rt.finishRound(nil),
+			},
+			Result: types.CallResult{
+				Failed: &types.FailedCallResult{
+					Module:  module,
+					Code:    code,
+					Message: msg,
+				},
+			},
+		}, nil
+	}
+	switch tx.Call.Method {
+	case "consensus.Deposit":
+	case "accounts.Transfer":
+		var xfer accounts.Transfer
+		if err = cbor.Unmarshal(tx.Call.Body, &xfer); err != nil {
+			return checkTxFailure(runtimeErrMalformedTx, "malformed transaction")
+		}
+
+		// Transfers are executed by the paratime itself, in the round
+		// that includes the transaction.
+		if err = rt.tokenBalance(from, xfer.Amount.Denomination).Sub(&xfer.Amount.Amount); err != nil {
+			return callFailure(accounts.ModuleName, runtimeErrInsufficientBalance, "insufficient balance")
+		}
+		_ = rt.tokenBalance(xfer.To, xfer.Amount.Denomination).Add(&xfer.Amount.Amount)
+		round := rt.finishRound([]*types.Event{transferEvent(&accounts.TransferEvent{
+			From:   from,
+			To:     xfer.To,
+			Amount: xfer.Amount,
+		})})
+
+		return &client.SubmitTxRawMeta{
+			TransactionMeta: client.TransactionMeta{
+				Round: round,
+			},
+			Result: types.CallResult{
+				Ok: cbor.Marshal(nil),
+			},
+		}, nil
+	default:
+		return callFailure(runtimeCoreModule, runtimeErrInvalidMethod, "invalid method")
+	}
+
+	var deposit consensusaccounts.Deposit
+	if err = cbor.Unmarshal(tx.Call.Body, &deposit); err != nil {
+		return checkTxFailure(runtimeErrMalformedTx, "malformed transaction")
+	}
+	round := rt.finishRound(nil)
+
+	// The deposit itself is executed by the consensus layer, and the
+	// outcome is emitted as an event in a following round.
+	executeDeposit := func() {
+		rt.finishRound([]*types.Event{c.executeDeposit(pt, rt, from, nonce, &deposit)})
+	}
+	switch c.depositDelay {
+	case 0:
+		executeDeposit()
+	default:
+		time.AfterFunc(c.depositDelay, func() {
+			c.lock.Lock()
+			defer c.lock.Unlock()
+
+			executeDeposit()
+		})
+	}
+
+	return &client.SubmitTxRawMeta{
+		TransactionMeta: client.TransactionMeta{
+			Round: round,
+		},
+		Result: types.CallResult{
+			Ok: cbor.Marshal(nil),
+		},
+	}, nil
+}
+
+func (c *MemoryChain) executeDeposit(
+	pt *config.ParaTime,
+	rt *memoryRuntime,
+	from types.Address,
+	nonce uint64,
+	deposit *consensusaccounts.Deposit,
+) *types.Event {
+	ev := &consensusaccounts.DepositEvent{
+		From:   from,
+		Nonce:  nonce,
+		To:     from,
+		Amount: deposit.Amount,
+	}
+	if deposit.To != nil {
+		ev.To = *deposit.To
+	}
+
+	fail := func(err error) *types.Event {
+		module, code := errors.Code(err)
+		ev.Error = &consensusaccounts.ConsensusError{
+			Module: module,
+			Code:   code,
+		}
+		return depositEvent(ev)
+	}
+
+	// Convert the amount to consensus base units.
+	amount := deposit.Amount.Amount.ToBigInt()
+	ptDecimals := pt.Denominations[config.NativeDenominationKey].Decimals
+	if scale := int64(ptDecimals) - int64(c.network.Denomination.Decimals); scale > 0 {
+		amount = new(big.Int).Quo(amount, new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil))
+	}
+	consensusAmount := quantity.NewQuantity()
+	_ = consensusAmount.FromBigInt(amount)
+
+	// Move the tokens from the owner to the paratime's account, against
+	// the owner's allowance.
+	acct := c.account(from.ConsensusAddress())
+	rtAddr := staking.NewRuntimeAddress(pt.Namespace())
+	allowance := acct.General.Allowances[rtAddr]
+	if err := allowance.Sub(consensusAmount); err != nil {
+		return fail(staking.ErrForbidden)
+	}
+	if err := acct.General.Balance.Sub(consensusAmount); err != nil {
+		return fail(staking.ErrInsufficientBalance)
+	}
+	acct.General.Allowances[rtAddr] = allowance
+	_ = c.account(rtAddr).General.Balance.Add(consensusAmount)
+	_ = rt.balance(ev.To).Add(&deposit.Amount.Amount)
+
+	return depositEvent(ev)
+}
+
+func depositEvent(ev *consensusaccounts.DepositEvent) *types.Event {
+	return &types.Event{
+		Module: consensusaccounts.ModuleName,
+		Code:   consensusaccounts.DepositEventCode,
+		Value:  cbor.Marshal([]*consensusaccounts.DepositEvent{ev}),
+	}
+}
+
+func transferEvent(ev *accounts.TransferEvent) *types.Event {
+	return &types.Event{
+		Module: accounts.ModuleName,
+		Code:   accounts.TransferEventCode,
+		Value:  cbor.Marshal([]*accounts.TransferEvent{ev}),
+	}
+}
+
+func (c *MemoryChain) WatchRuntimeRounds(ctx context.Context, pt *config.ParaTime) (<-chan uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rt := c.runtime(pt)
+	ch := make(chan uint64, memoryChainSubBuffer)
+	rt.roundSubs[ch] = struct{}{}
+	go func() {
+		<-ctx.Done()
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+
+		delete(rt.roundSubs, ch)
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (c *MemoryChain) LatestRuntimeRound(ctx context.Context, pt *config.ParaTime) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return uint64(len(c.runtime(pt).rounds) - 1), nil
+}
+
+func (c *MemoryChain) RuntimeEvents(ctx context.Context, pt *config.ParaTime, round uint64) ([]*types.Event, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	rt := c.runtime(pt)
+	if round >= uint64(len(rt.rounds)) {
+		return nil, fmt.Errorf("memchain: no round %d", round)
+	}
+	return rt.rounds[round], nil
+}
+
+// NewMockChain creates a memory chain for the -mock-chain mode, with the
+// faucet account funded.  The configuration must have been validated.
+func NewMockChain(network *config.Network, cfg *faucetConfig.MockChainConfig, faucet staking.Address) (*MemoryChain, error) {
+	balanceStr := cfg.Balance
+	if balanceStr == "" {
+		balanceStr = "1000000"
+	}
+
+	chain := NewMemoryChain(network)
+	balance, err := helpers.ParseConsensusDenomination(network, balanceStr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed balance: %w", err)
+	}
+	chain.SetConsensusBalance(faucet, *balance)
+	for _, pt := range network.ParaTimes.All {
+		ptBalance, err := helpers.ParseParaTimeDenomination(pt, balanceStr, types.NativeDenomination)
+		if err != nil {
+			return nil, fmt.Errorf("malformed balance: %w", err)
+		}
+		chain.SetRuntimeBalance(pt, types.NewAddressFromConsensus(faucet), ptBalance.Amount)
+
+		// Hold the same amount of the paratime's other tokens.
+		for denom := range pt.Denominations {
+			if denom == config.NativeDenominationKey {
+				continue
+			}
+			tokenBalance, err := helpers.ParseParaTimeDenomination(pt, balanceStr, types.Denomination(denom))
+			if err != nil {
+				return nil, fmt.Errorf("malformed balance: %w", err)
+			}
+			chain.SetRuntimeTokenBalance(pt, types.NewAddressFromConsensus(faucet), tokenBalance.Denomination, tokenBalance.Amount)
+		}
+	}
+
+	chain.SetDepositDelay(cfg.DepositDelayDuration())
+	chain.SetFailures(MemoryChainFailures{
+		CheckTxRate:    cfg.CheckTxFailureRate,
+		TimeoutRate:    cfg.TimeoutRate,
+		EmptyAllowance: cfg.EmptyAllowance,
+	})
+
+	return chain, nil
+}
@@ -0,0 +1,238 @@
+// Package client implements a client for the faucet v2 HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	// defaultPollInterval is the default interval at which the status of
+	// requests is polled while waiting for them to complete.
+	defaultPollInterval = 1 * time.Second
+
+	// maxResponseSize is the maximum size of a response body.
+	maxResponseSize = 1024 * 1024
+)
+
+// ErrRequestFailed is the error returned when waiting for a funding request
+// that failed.
+var ErrRequestFailed = errors.New("client: funding request failed")
+
+// Client is a faucet API client.
+type Client struct {
+	baseURL      *url.URL
+	httpClient   *http.Client
+	pollInterval time.Duration
+	apiKey       string
+}
+
+// Option is a client option.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used to make requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithPollInterval sets the interval at which the status of requests is
+// polled while waiting for them to complete.
+func WithPollInterval(interval time.Duration) Option {
+	return func(c *Client) {
+		c.pollInterval = interval
+	}
+}
+
+// WithAPIKey sets the API key sent with each request (Default: none).
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// New creates a new client for the faucet at the base URL, eg:
+// `https://faucet.testnet.oasis.io`.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: invalid base URL scheme: '%s'", u.Scheme)
+	}
+
+	c := &Client{
+		baseURL:      u,
+		httpClient:   http.DefaultClient,
+		pollInterval: defaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// do makes a request to the API, and decodes the JSON response into v.  API
+// errors are returned as *api.Error.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, v interface{}) error {
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("client: failed to encode request: %w", err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return fmt.Errorf("client: failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set(api.HeaderAPIKey, c.apiKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("client: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return fmt.Errorf("client: failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp api.FundResponse
+		if err = json.Unmarshal(b, &errResp); err != nil || errResp.Error == nil {
+			return &api.Error{
+				Code:    api.ErrCodeInternal,
+				Message: fmt.Sprintf("client: unexpected response: %s", resp.Status),
+			}
+		}
+		return errResp.Error
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("client: failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// Fund submits a funding request.
+func (c *Client) Fund(ctx context.Context, params *api.FundParams) (*api.FundResponse, error) {
+	var resp api.FundResponse
+	if err := c.do(ctx, http.MethodPost, api.PathFundV2, nil, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Bundle submits a bundle request.
+func (c *Client) Bundle(ctx context.Context, params *api.BundleParams) (*api.FundResponse, error) {
+	var resp api.FundResponse
+	if err := c.do(ctx, http.MethodPost, api.PathBundleV2, nil, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Status returns the status of a funding request.
+func (c *Client) Status(ctx context.Context, id string) (*api.RequestStatus, error) {
+	var status api.RequestStatus
+	if err := c.do(ctx, http.MethodGet, api.PathStatusV2+url.PathEscape(id), nil, nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Info returns the faucet's funding information.
+func (c *Client) Info(ctx context.Context) (*api.InfoResponse, error) {
+	var info api.InfoResponse
+	if err := c.do(ctx, http.MethodGet, api.PathInfoV2, nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Balance returns the balance of a consensus (empty paratime) or paratime
+// account.
+func (c *Client) Balance(ctx context.Context, paraTime, account string) (*api.BalanceResponse, error) {
+	query := url.Values{
+		api.QueryAccount: {account},
+	}
+	if paraTime != "" {
+		query.Set(api.QueryParaTime, paraTime)
+	}
+
+	var balance api.BalanceResponse
+	if err := c.do(ctx, http.MethodGet, api.PathBalanceV2, query, nil, &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// WaitForRequest waits for a funding request to complete, and returns its
+// final status.  If the request failed, the status is returned along with
+// an error wrapping ErrRequestFailed.
+func (c *Client) WaitForRequest(ctx context.Context, id string) (*api.RequestStatus, error) {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.Status(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch status.State {
+		case api.RequestConfirmed:
+			return status, nil
+		case api.RequestFailed:
+			return status, fmt.Errorf("%w: %s", ErrRequestFailed, status.Reason)
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// FundAndWait submits a funding request, and waits for it to complete.
+func (c *Client) FundAndWait(ctx context.Context, params *api.FundParams) (*api.FundResponse, *api.RequestStatus, error) {
+	resp, err := c.Fund(ctx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := c.WaitForRequest(ctx, resp.RequestID)
+	return resp, status, err
+}
+
+// BundleAndWait submits a bundle request, and waits for it to complete.
+func (c *Client) BundleAndWait(ctx context.Context, params *api.BundleParams) (*api.FundResponse, *api.RequestStatus, error) {
+	resp, err := c.Bundle(ctx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	status, err := c.WaitForRequest(ctx, resp.RequestID)
+	return resp, status, err
+}
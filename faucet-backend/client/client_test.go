@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	testAPIKey  = "test-api-key"
+	testTimeout = 10 * time.Second
+)
+
+// testServer is a fake faucet v2 API, whose requests are confirmed after
+// having been polled once, unless their account is `fail`.
+type testServer struct {
+	lock     sync.Mutex
+	requests map[string]*api.RequestStatus
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, &api.FundResponse{
+		Result: msg,
+		Error: &api.Error{
+			Code:    code,
+			Message: msg,
+		},
+	})
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get(api.HeaderAPIKey) != testAPIKey {
+		writeError(w, http.StatusForbidden, api.ErrCodeAccessDenied, "missing API key")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch path := req.URL.Path; {
+	case path == api.PathFundV2 && req.Method == http.MethodPost:
+		var params api.FundParams
+		if err := json.NewDecoder(req.Body).Decode(&params); err != nil || params.Account == "" {
+			writeError(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid request")
+			return
+		}
+		id := params.Account
+		s.requests[id] = &api.RequestStatus{
+			ID:    id,
+			State: api.RequestQueued,
+		}
+		writeJSON(w, http.StatusOK, &api.FundResponse{
+			Result:    "funding request submitted",
+			RequestID: id,
+			Amount:    params.Amount + " TEST",
+		})
+	case path == api.PathBundleV2 && req.Method == http.MethodPost:
+		var params api.BundleParams
+		if err := json.NewDecoder(req.Body).Decode(&params); err != nil || params.Account == "" {
+			writeError(w, http.StatusBadRequest, api.ErrCodeInvalidRequest, "invalid request")
+			return
+		}
+		if params.Bundle != "starter" {
+			writeError(w, http.StatusBadRequest, api.ErrCodeInvalidBundle, "invalid bundle")
+			return
+		}
+		id := params.Account
+		s.requests[id] = &api.RequestStatus{
+			ID:    id,
+			State: api.RequestQueued,
+		}
+		writeJSON(w, http.StatusOK, &api.FundResponse{
+			Result:    "bundle request submitted",
+			RequestID: id,
+		})
+	case strings.HasPrefix(path, api.PathStatusV2) && req.Method == http.MethodGet:
+		status := s.requests[strings.TrimPrefix(path, api.PathStatusV2)]
+		if status == nil {
+			writeError(w, http.StatusNotFound, api.ErrCodeNotFound, "unknown request id")
+			return
+		}
+		st := *status
+		switch {
+		case status.State != api.RequestQueued:
+		case status.ID == "fail":
+			status.State, status.Reason = api.RequestFailed, "transfer not executed"
+		default:
+			status.State = api.RequestConfirmed
+		}
+		writeJSON(w, http.StatusOK, &st)
+	case path == api.PathInfoV2 && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &api.InfoResponse{
+			Address: "oasis1faucet",
+		})
+	case path == api.PathBalanceV2 && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, &api.BalanceResponse{
+			ParaTime: req.URL.Query().Get(api.QueryParaTime),
+			Account:  req.URL.Query().Get(api.QueryAccount),
+			Balance:  "1.0 TEST",
+		})
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+	}
+}
+
+func newTestClient(t *testing.T, opts ...Option) *Client {
+	t.Helper()
+
+	srv := httptest.NewServer(&testServer{
+		requests: make(map[string]*api.RequestStatus),
+	})
+	t.Cleanup(srv.Close)
+
+	opts = append([]Option{WithAPIKey(testAPIKey), WithPollInterval(10 * time.Millisecond)}, opts...)
+	c, err := New(srv.URL+"/", opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	for _, tc := range []struct {
+		baseURL string
+		valid   bool
+	}{
+		{"https://faucet.testnet.oasis.io", true},
+		{"http://localhost:8080/", true},
+		{"ftp://faucet.testnet.oasis.io", false},
+		{"faucet.testnet.oasis.io", false},
+		{"http://[::1", false},
+	} {
+		if _, err := New(tc.baseURL); (err == nil) != tc.valid {
+			t.Errorf("New(%q): got error %v, expected valid: %v", tc.baseURL, err, tc.valid)
+		}
+	}
+}
+
+func TestFundAndWait(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	resp, status, err := c.FundAndWait(ctx, &api.FundParams{
+		Account: "oasis1account",
+		Amount:  "1.0",
+	})
+	if err != nil {
+		t.Fatalf("FundAndWait: %v", err)
+	}
+	if resp.RequestID != "oasis1account" || resp.Amount != "1.0 TEST" || status.State != api.RequestConfirmed {
+		t.Fatalf("FundAndWait: unexpected result: %+v %+v", resp, status)
+	}
+
+	// Failed requests return their final status, along with the error.
+	_, status, err = c.FundAndWait(ctx, &api.FundParams{
+		Account: "fail",
+	})
+	if !errors.Is(err, ErrRequestFailed) || status == nil || status.Reason != "transfer not executed" {
+		t.Fatalf("FundAndWait failed: unexpected result: %+v (%v)", status, err)
+	}
+
+	// Waiting is bounded by the context.
+	if _, err = c.Fund(ctx, &api.FundParams{Account: "pending"}); err != nil {
+		t.Fatalf("Fund: %v", err)
+	}
+	canceledCtx, cancelFn := context.WithCancel(ctx)
+	cancelFn()
+	if _, err = c.WaitForRequest(canceledCtx, "pending"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitForRequest canceled: unexpected error: %v", err)
+	}
+}
+
+func TestBundleAndWait(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	resp, status, err := c.BundleAndWait(ctx, &api.BundleParams{
+		Bundle:  "starter",
+		Account: "oasis1account",
+	})
+	if err != nil {
+		t.Fatalf("BundleAndWait: %v", err)
+	}
+	if resp.RequestID != "oasis1account" || status.State != api.RequestConfirmed {
+		t.Fatalf("BundleAndWait: unexpected result: %+v %+v", resp, status)
+	}
+
+	var apiErr *api.Error
+	_, _, err = c.BundleAndWait(ctx, &api.BundleParams{
+		Bundle:  "bogus",
+		Account: "oasis1account",
+	})
+	if !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeInvalidBundle {
+		t.Fatalf("BundleAndWait unknown bundle: unexpected error: %v", err)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	info, err := c.Info(ctx)
+	if err != nil || info.Address != "oasis1faucet" {
+		t.Fatalf("Info: unexpected result: %+v (%v)", info, err)
+	}
+
+	balance, err := c.Balance(ctx, "sapphire", "oasis1account")
+	if err != nil || balance.ParaTime != "sapphire" || balance.Account != "oasis1account" || balance.Balance != "1.0 TEST" {
+		t.Fatalf("Balance: unexpected result: %+v (%v)", balance, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+
+	// API errors are returned as is.
+	var apiErr *api.Error
+	if _, err := c.Status(ctx, "bogus"); !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeNotFound {
+		t.Fatalf("Status unknown: unexpected error: %v", err)
+	}
+	if _, err := c.Fund(ctx, &api.FundParams{}); !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeInvalidRequest {
+		t.Fatalf("Fund invalid: unexpected error: %v", err)
+	}
+	c.apiKey = ""
+	if _, err := c.Info(ctx); !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeAccessDenied {
+		t.Fatalf("Info without API key: unexpected error: %v", err)
+	}
+
+	// Responses that are not API errors are reported as internal errors.
+	c.apiKey = testAPIKey
+	c.baseURL.Path = "/bogus"
+	if _, err := c.Info(ctx); !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeInternal {
+		t.Fatalf("Info unexpected response: unexpected error: %v", err)
+	}
+}
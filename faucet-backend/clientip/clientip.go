@@ -0,0 +1,207 @@
+// Package clientip derives the IP address of clients behind trusted
+// reverse proxies and load balancers.
+package clientip
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// HeaderForwarded is the standard RFC 7239 header.
+	HeaderForwarded = "Forwarded"
+	// HeaderXForwardedFor is the de-facto standard header, listing the
+	// client and each proxy the request passed through.
+	HeaderXForwardedFor = "X-Forwarded-For"
+	// HeaderXRealIP is the header carrying only the client address, as
+	// set by eg: nginx.
+	HeaderXRealIP = "X-Real-IP"
+)
+
+// hashLen is the length of the hex encoded salted IP address hashes.
+const hashLen = 16
+
+// ParseCIDRs parses a list of CIDR ranges, where single IP addresses are
+// treated as /32 (or /128) ranges.
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("malformed IP: '%s'", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("malformed CIDR: '%s'", cidr)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// CanonicalHeader returns the canonical name of a supported header, or an
+// error if the header is not supported.
+func CanonicalHeader(name string) (string, error) {
+	for _, h := range []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP} {
+		if strings.EqualFold(name, h) {
+			return h, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported header: '%s'", name)
+}
+
+// Resolver derives client IP addresses.
+type Resolver struct {
+	trusted []*net.IPNet
+	headers []string
+	salt    string
+}
+
+// NewResolver creates a new resolver that believes the headers (by order
+// of precedence) set by the trusted proxies.  If the salt is non-empty,
+// Display returns salted hashes of the addresses.
+func NewResolver(trusted []*net.IPNet, headers []string, salt string) *Resolver {
+	return &Resolver{
+		trusted: trusted,
+		headers: headers,
+		salt:    salt,
+	}
+}
+
+// IsTrusted returns true iff the address belongs to a trusted proxy.
+func (r *Resolver) IsTrusted(ip net.IP) bool {
+	if r == nil || ip == nil {
+		return false
+	}
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve returns the address of the client, given the address of the
+// peer that sent the request and the request headers, or nil if the peer
+// address is malformed.  The headers are only believed if the peer is a
+// trusted proxy, and are walked from the most recent hop backwards,
+// skipping trusted proxies.
+func (r *Resolver) Resolve(remoteAddr string, header http.Header) net.IP {
+	peer := parseHost(remoteAddr)
+	if !r.IsTrusted(peer) {
+		return peer
+	}
+	if ip := r.ResolveForwarded(header); ip != nil {
+		return ip
+	}
+	return peer
+}
+
+// ResolveForwarded returns the address of the client from the headers set
+// by a peer that is trusted regardless of its address, eg: a local proxy
+// connected over a Unix domain socket, or nil if not forwarded.
+func (r *Resolver) ResolveForwarded(header http.Header) net.IP {
+	if r == nil {
+		return nil
+	}
+	for _, name := range r.headers {
+		var hops []net.IP
+		switch name {
+		case HeaderForwarded:
+			hops = parseForwarded(header.Values(name))
+		case HeaderXForwardedFor:
+			hops = parseList(header.Values(name))
+		case HeaderXRealIP:
+			if ip := parseHost(strings.TrimSpace(header.Get(name))); ip != nil {
+				hops = []net.IP{ip}
+			}
+		}
+		if ip := r.client(hops); ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+// ResolveRequest returns the address of the client that sent the request.
+func (r *Resolver) ResolveRequest(req *http.Request) net.IP {
+	return r.Resolve(req.RemoteAddr, req.Header)
+}
+
+// client returns the first untrusted hop from the end of the chain, or the
+// first hop if all of them are trusted.  A malformed hop ends the walk, as
+// anything before it can not be believed.
+func (r *Resolver) client(hops []net.IP) net.IP {
+	for i := len(hops) - 1; i >= 0; i-- {
+		switch {
+		case hops[i] == nil:
+			return nil
+		case !r.IsTrusted(hops[i]) || i == 0:
+			return hops[i]
+		}
+	}
+	return nil
+}
+
+// Display returns the address as it should appear in logs, which is a
+// salted hash if configured.
+func (r *Resolver) Display(ip net.IP) string {
+	switch {
+	case ip == nil:
+		return "unknown"
+	case r == nil || r.salt == "":
+		return ip.String()
+	}
+	h := sha256.Sum256([]byte(r.salt + ip.String()))
+	return hex.EncodeToString(h[:])[:hashLen]
+}
+
+// parseHost parses an address with an optional port.
+func parseHost(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
+
+// parseList parses comma separated X-Forwarded-For values.
+func parseList(values []string) []net.IP {
+	var hops []net.IP
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, parseHost(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+// parseForwarded parses the `for` parameters of RFC 7239 Forwarded
+// values.  Obfuscated and unknown identifiers are returned as nil.
+func parseForwarded(values []string) []net.IP {
+	var hops []net.IP
+	for _, v := range values {
+		for _, elem := range strings.Split(v, ",") {
+			var hop net.IP
+			for _, pair := range strings.Split(elem, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					hop = parseHost(strings.Trim(value, `"`))
+				}
+			}
+			hops = append(hops, hop)
+		}
+	}
+	return hops
+}
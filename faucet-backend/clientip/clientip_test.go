@@ -0,0 +1,194 @@
+package clientip
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func newTestResolver(t *testing.T, salt string) *Resolver {
+	t.Helper()
+
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	return NewResolver(trusted, []string{HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}, salt)
+}
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs([]string{"192.0.2.1", "2001:db8::1", "198.51.100.0/24"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+	for i, expected := range []string{"192.0.2.1/32", "2001:db8::1/128", "198.51.100.0/24"} {
+		if s := nets[i].String(); s != expected {
+			t.Errorf("ParseCIDRs[%d]: got %v, expected %v", i, s, expected)
+		}
+	}
+
+	for _, cidr := range []string{"192.0.2", "192.0.2.0/33", "bogus/8"} {
+		if _, err = ParseCIDRs([]string{cidr}); err == nil {
+			t.Errorf("ParseCIDRs: malformed range accepted: %s", cidr)
+		}
+	}
+}
+
+func TestCanonicalHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		expected string
+	}{
+		{"forwarded", HeaderForwarded},
+		{"x-forwarded-for", HeaderXForwardedFor},
+		{"X-REAL-IP", HeaderXRealIP},
+		{"x-client-ip", ""},
+	} {
+		h, err := CanonicalHeader(tc.name)
+		if h != tc.expected || (err == nil) != (tc.expected != "") {
+			t.Errorf("CanonicalHeader(%s): got %q (%v), expected %q", tc.name, h, err, tc.expected)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	r := newTestResolver(t, "")
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{"Direct", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"DirectIPv6", "[2001:db8::2]:1234", nil, "2001:db8::2"},
+		{"UntrustedPeer", "192.0.2.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "192.0.2.1"},
+		{"XForwardedFor", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.1, 198.51.100.1, 10.0.0.2"}}, "198.51.100.1"},
+		{"XForwardedForValues", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1", "10.0.0.2"}}, "198.51.100.1"},
+		{"AllTrusted", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"MalformedHop", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1, bogus, 10.0.0.2"}}, "10.0.0.1"},
+		{"Forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {`for=198.51.100.1;proto=https, for="[2001:db8::1]:4711"`}}, "2001:db8::1"},
+		{"ForwardedObfuscated", "10.0.0.1:1234", http.Header{"Forwarded": {"for=_hidden"}, "X-Real-Ip": {"198.51.100.2"}}, "198.51.100.2"},
+		{"HeaderPrecedence", "10.0.0.1:1234", http.Header{"Forwarded": {"for=198.51.100.1"}, "X-Real-Ip": {"198.51.100.2"}}, "198.51.100.1"},
+		{"XRealIP", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"198.51.100.2"}}, "198.51.100.2"},
+		{"NoHeaders", "10.0.0.1:1234", nil, "10.0.0.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ip := r.Resolve(tc.remoteAddr, tc.header); ip.String() != tc.expected {
+				t.Fatalf("Resolve: got %v, expected %v", ip, tc.expected)
+			}
+		})
+	}
+
+	if ip := r.Resolve("bogus", nil); ip != nil {
+		t.Errorf("Resolve: got %v for a malformed peer address, expected nil", ip)
+	}
+	if ip := r.ResolveForwarded(http.Header{}); ip != nil {
+		t.Errorf("ResolveForwarded: got %v without headers, expected nil", ip)
+	}
+
+	// Without a resolver, nothing is trusted.
+	var nilResolver *Resolver
+	header := http.Header{"X-Forwarded-For": {"198.51.100.1"}}
+	if ip := nilResolver.Resolve("127.0.0.1:1234", header); ip.String() != "127.0.0.1" {
+		t.Errorf("Resolve nil resolver: got %v, expected the peer", ip)
+	}
+	if ip := nilResolver.ResolveForwarded(header); ip != nil {
+		t.Errorf("ResolveForwarded nil resolver: got %v, expected nil", ip)
+	}
+}
+
+func TestDisplay(t *testing.T) {
+	ip := net.ParseIP("192.0.2.1")
+
+	var nilResolver *Resolver
+	for _, r := range []*Resolver{nilResolver, newTestResolver(t, "")} {
+		if s := r.Display(ip); s != ip.String() {
+			t.Errorf("Display: got %v, expected the address", s)
+		}
+	}
+	if s := nilResolver.Display(nil); s != "unknown" {
+		t.Errorf("Display: got %v for a nil address, expected unknown", s)
+	}
+
+	salted := newTestResolver(t, "salt")
+	s := salted.Display(ip)
+	if s == ip.String() || len(s) != hashLen {
+		t.Fatalf("Display: got %v, expected a hash", s)
+	}
+	if s2 := salted.Display(ip); s2 != s {
+		t.Errorf("Display: got %v, expected a stable hash %v", s2, s)
+	}
+	if s2 := newTestResolver(t, "pepper").Display(ip); s2 == s {
+		t.Errorf("Display: got the same hash with a different salt")
+	}
+}
+
+func TestProxyListener(t *testing.T) {
+	v2 := []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x11\x00\x0c")
+	v2 = append(v2, 198, 51, 100, 2, 10, 0, 0, 1, 0x30, 0x39, 0x01, 0xbb)
+	v2Local := []byte("\r\n\r\n\x00\r\nQUIT\n\x20\x00\x00\x00")
+
+	untrusted, err := ParseCIDRs([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("ParseCIDRs: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		resolver *Resolver
+		header   []byte
+		expected string
+		payload  string
+	}{
+		{"V1", newTestResolver(t, ""), []byte("PROXY TCP4 198.51.100.1 10.0.0.1 12345 443\r\n"), "198.51.100.1", "hello"},
+		{"V1IPv6", newTestResolver(t, ""), []byte("PROXY TCP6 2001:db8::1 2001:db8::2 12345 443\r\n"), "2001:db8::1", "hello"},
+		{"V1Unknown", newTestResolver(t, ""), []byte("PROXY UNKNOWN\r\n"), "127.0.0.1", "hello"},
+		{"V2", newTestResolver(t, ""), v2, "198.51.100.2", "hello"},
+		{"V2Local", newTestResolver(t, ""), v2Local, "127.0.0.1", "hello"},
+		{"UntrustedPeer", NewResolver(untrusted, nil, ""), []byte("PROXY TCP4 198.51.100.1 10.0.0.1 12345 443\r\n"), "127.0.0.1", "PROXY TCP4 198.51.100.1 10.0.0.1 12345 443\r\nhello"},
+		{"MissingHeader", newTestResolver(t, ""), []byte("GET / HTTP/1.1\r\n"), "127.0.0.1", ""},
+		{"MalformedV1", newTestResolver(t, ""), []byte("PROXY TCP4 bogus 10.0.0.1 12345 443\r\n"), "127.0.0.1", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("failed to listen: %v", err)
+			}
+			defer ln.Close()
+			ln = NewProxyListener(ln, tc.resolver)
+
+			go func() {
+				conn, err := net.Dial("tcp", ln.Addr().String())
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				_, _ = conn.Write(append(tc.header, "hello"...))
+			}()
+
+			conn, err := ln.Accept()
+			if err != nil {
+				t.Fatalf("failed to accept: %v", err)
+			}
+			defer conn.Close()
+			if host, _, _ := net.SplitHostPort(conn.RemoteAddr().String()); host != tc.expected {
+				t.Errorf("remote address: got %v, expected %v", host, tc.expected)
+			}
+
+			b, err := io.ReadAll(conn)
+			switch tc.payload {
+			case "":
+				// Connections with a bad header are unreadable.
+				if err == nil {
+					t.Errorf("payload: got %q, expected an error", b)
+				}
+			default:
+				if err != nil || string(b) != tc.payload {
+					t.Errorf("payload: got %q (%v), expected %q", b, err, tc.payload)
+				}
+			}
+		})
+	}
+}
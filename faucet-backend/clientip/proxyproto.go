@@ -0,0 +1,194 @@
+package clientip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// proxyHeaderTimeout is how long a connection has to send its PROXY
+	// protocol header.
+	proxyHeaderTimeout = 5 * time.Second
+
+	// proxyV1MaxLen is the maximum length of a v1 (text) header.
+	proxyV1MaxLen = 107
+
+	proxyV2HeaderLen = 16
+	proxyV2Version   = 0x20
+	proxyV2CmdLocal  = 0x00
+	proxyV2CmdProxy  = 0x01
+	proxyV2FamInet   = 0x10
+	proxyV2FamInet6  = 0x20
+)
+
+var (
+	proxyV1Signature = []byte("PROXY ")
+	proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ProxyListener is a listener accepting connections that start with a
+// PROXY protocol (v1 or v2) header, as sent by eg: HAProxy and AWS NLBs.
+// The header is only believed if the peer is a trusted proxy, in which
+// case the connection's remote address is the source address from the
+// header.  Connections from other peers are passed through unmodified.
+type ProxyListener struct {
+	net.Listener
+
+	resolver *Resolver
+}
+
+// NewProxyListener wraps the listener, believing PROXY protocol headers
+// from the resolver's trusted proxies.
+func NewProxyListener(ln net.Listener, resolver *Resolver) *ProxyListener {
+	return &ProxyListener{
+		Listener: ln,
+		resolver: resolver,
+	}
+}
+
+// Accept waits for and returns the next connection.  The PROXY protocol
+// header is read lazily, so that a slow peer does not block the listener.
+func (l *ProxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if addr, ok := conn.RemoteAddr().(*net.TCPAddr); !ok || !l.resolver.IsTrusted(addr.IP) {
+		return conn, nil
+	}
+	return &proxyConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// proxyConn is a connection from a trusted proxy.
+type proxyConn struct {
+	net.Conn
+
+	once   sync.Once
+	reader *bufio.Reader
+	source net.Addr
+	err    error
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.source, c.err = readProxyHeader(c.reader)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+	})
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) {
+	if c.init(); c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address from the PROXY protocol header,
+// or the proxy's address if the header does not carry one.
+func (c *proxyConn) RemoteAddr() net.Addr {
+	if c.init(); c.source != nil {
+		return c.source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyHeader reads a PROXY protocol header, and returns the source
+// address if any.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	b, err := r.Peek(len(proxyV1Signature))
+	if err != nil {
+		return nil, fmt.Errorf("proxyproto: failed to read header: %w", err)
+	}
+	switch {
+	case bytes.Equal(b, proxyV1Signature):
+		return readProxyV1(r)
+	case bytes.Equal(b, proxyV2Signature[:len(b)]):
+		return readProxyV2(r)
+	default:
+		return nil, fmt.Errorf("proxyproto: missing header")
+	}
+}
+
+// readProxyV1 reads a v1 header, eg: `PROXY TCP4 SRC DST SPORT DPORT\r\n`.
+func readProxyV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("proxyproto: failed to read v1 header: %w", err)
+		}
+		if line = append(line, c); len(line) > proxyV1MaxLen {
+			return nil, fmt.Errorf("proxyproto: v1 header too long")
+		}
+	}
+
+	fields := strings.Fields(string(line))
+	switch {
+	case len(fields) >= 2 && fields[1] == "UNKNOWN":
+		return nil, nil
+	case len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6"):
+		return nil, fmt.Errorf("proxyproto: malformed v1 header")
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return nil, fmt.Errorf("proxyproto: malformed v1 source address")
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyV2 reads a v2 (binary) header.
+func readProxyV2(r *bufio.Reader) (net.Addr, error) {
+	var hdr [proxyV2HeaderLen]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, fmt.Errorf("proxyproto: failed to read v2 header: %w", err)
+	}
+	if !bytes.Equal(hdr[:len(proxyV2Signature)], proxyV2Signature) || hdr[12]&0xf0 != proxyV2Version {
+		return nil, fmt.Errorf("proxyproto: malformed v2 header")
+	}
+	body := make([]byte, binary.BigEndian.Uint16(hdr[14:]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("proxyproto: failed to read v2 addresses: %w", err)
+	}
+
+	switch hdr[12] & 0x0f {
+	case proxyV2CmdLocal:
+		// Health checks et al. from the proxy itself.
+		return nil, nil
+	case proxyV2CmdProxy:
+	default:
+		return nil, fmt.Errorf("proxyproto: unknown v2 command")
+	}
+
+	// The source address is followed by the destination address, and the
+	// source and destination ports.
+	var ipLen int
+	switch hdr[13] & 0xf0 {
+	case proxyV2FamInet:
+		ipLen = net.IPv4len
+	case proxyV2FamInet6:
+		ipLen = net.IPv6len
+	default:
+		// Unix sockets and unspecified families carry no usable address.
+		return nil, nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, fmt.Errorf("proxyproto: truncated v2 addresses")
+	}
+	return &net.TCPAddr{
+		IP:   net.IP(body[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(body[2*ipLen:])),
+	}, nil
+}
@@ -0,0 +1,227 @@
+// Command faucet-cli is a command line client for the faucet.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/client"
+)
+
+const (
+	envFaucetURL     = "FAUCET_URL"
+	envAPIKey        = "FAUCET_API_KEY"
+	defaultFaucetURL = "http://localhost:8080"
+)
+
+const usage = `usage: faucet-cli [-url URL] [-api-key KEY] [-json] [-timeout DURATION] COMMAND [ARGS]
+
+commands:
+  fund [-paratime PARATIME] [-amount TOKENS] [-captcha RESPONSE] [-wait] ACCOUNT
+  status [-wait] REQUEST_ID
+  info
+
+The faucet URL defaults to $FAUCET_URL, or ` + defaultFaucetURL + `, and
+the API key defaults to $FAUCET_API_KEY.
+`
+
+// parseInterspersed parses flags that may be interspersed with positional
+// arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func printJSON(v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(b))
+}
+
+func printStatus(status *api.RequestStatus) {
+	fmt.Printf("request %s: %s", status.ID, status.State)
+	switch {
+	case status.Reason != "":
+		fmt.Printf(" (%s)", status.Reason)
+	case status.TxHash != "":
+		fmt.Printf(" (tx: %s)", status.TxHash)
+	}
+	fmt.Println()
+}
+
+func run() error {
+	fs := flag.NewFlagSet("faucet-cli", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	faucetURL := fs.String("url", os.Getenv(envFaucetURL), "faucet base URL")
+	apiKey := fs.String("api-key", os.Getenv(envAPIKey), "API key")
+	jsonOutput := fs.Bool("json", false, "print JSON responses")
+	timeout := fs.Duration("timeout", 2*time.Minute, "timeout")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing command")
+	}
+	if *faucetURL == "" {
+		*faucetURL = defaultFaucetURL
+	}
+
+	c, err := client.New(*faucetURL, client.WithAPIKey(*apiKey))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	cmd, args := fs.Arg(0), fs.Args()[1:]
+	switch cmd {
+	case "fund":
+		cmdFs := flag.NewFlagSet("fund", flag.ContinueOnError)
+		params := new(api.FundParams)
+		cmdFs.StringVar(&params.ParaTime, "paratime", "", "paratime name, empty for consensus")
+		cmdFs.StringVar(&params.Amount, "amount", "", "amount in tokens, empty for the default")
+		cmdFs.StringVar(&params.CaptchaResponse, "captcha", "", "reCAPTCHA response, if required")
+		wait := cmdFs.Bool("wait", false, "wait for the request to complete")
+		positional, err := parseInterspersed(cmdFs, args)
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return fmt.Errorf("fund: expected exactly one account")
+		}
+		params.Account = positional[0]
+
+		resp, err := c.Fund(ctx, params)
+		if err != nil {
+			return err
+		}
+		if !*wait {
+			if *jsonOutput {
+				printJSON(resp)
+			} else {
+				fmt.Printf("request %s: funding %s\n", resp.RequestID, resp.Amount)
+				if resp.AmountReason != "" {
+					fmt.Printf("  amount reduced: %s\n", resp.AmountReason)
+				}
+			}
+			return nil
+		}
+		if !*jsonOutput {
+			fmt.Printf("request %s: funding %s, waiting for confirmation\n", resp.RequestID, resp.Amount)
+		}
+		status, err := c.WaitForRequest(ctx, resp.RequestID)
+		if status != nil {
+			if *jsonOutput {
+				printJSON(status)
+			} else {
+				printStatus(status)
+			}
+		}
+		return err
+	case "status":
+		cmdFs := flag.NewFlagSet("status", flag.ContinueOnError)
+		wait := cmdFs.Bool("wait", false, "wait for the request to complete")
+		positional, err := parseInterspersed(cmdFs, args)
+		if err != nil {
+			return err
+		}
+		if len(positional) != 1 {
+			return fmt.Errorf("status: expected exactly one request ID")
+		}
+
+		var status *api.RequestStatus
+		if *wait {
+			status, err = c.WaitForRequest(ctx, positional[0])
+		} else {
+			status, err = c.Status(ctx, positional[0])
+		}
+		if status != nil {
+			if *jsonOutput {
+				printJSON(status)
+			} else {
+				printStatus(status)
+			}
+		}
+		return err
+	case "info":
+		info, err := c.Info(ctx)
+		if err != nil {
+			return err
+		}
+		if *jsonOutput {
+			printJSON(info)
+			return nil
+		}
+
+		fmt.Printf("address: %s\n", info.Address)
+		fmt.Printf("chain context: %s\n", info.ChainContext)
+		fmt.Printf("captcha required: %v\n", info.CaptchaRequired)
+		if info.DryRun {
+			fmt.Printf("dry run: true\n")
+		}
+		printFundingInfo := func(name string, fi *api.FundingInfo) {
+			fmt.Printf("%s:\n", name)
+			if fi.DefaultAmount != "" {
+				fmt.Printf("  default amount: %s\n", fi.DefaultAmount)
+			}
+			if fi.MinAmount != "" {
+				fmt.Printf("  min amount: %s\n", fi.MinAmount)
+			}
+			if fi.MaxAmount != "" {
+				fmt.Printf("  max amount: %s\n", fi.MaxAmount)
+			}
+			if fi.PayoutFactor != 1 {
+				fmt.Printf("  payout factor: %v\n", fi.PayoutFactor)
+			}
+		}
+		printFundingInfo("consensus", info.Consensus)
+		var names []string
+		for name := range info.ParaTimes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			printFundingInfo(name, info.ParaTimes[name])
+		}
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown command: '%s'", cmd)
+	}
+}
+
+func main() {
+	if err := run(); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		var apiErr *api.Error
+		switch {
+		case !errors.As(err, &apiErr):
+			fmt.Fprintf(os.Stderr, "faucet-cli: %v\n", err)
+		case apiErr.RetryAfter > 0:
+			fmt.Fprintf(os.Stderr, "faucet-cli: %v (%s, retry after %ds)\n", err, apiErr.Code, apiErr.RetryAfter)
+		default:
+			fmt.Fprintf(os.Stderr, "faucet-cli: %v (%s)\n", err, apiErr.Code)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultAccessWatchInterval is the default interval at which the access
+// lists file is checked for changes.
+const defaultAccessWatchInterval = 10 * time.Second
+
+// AccessConfig is the configuration of the block and allow lists.
+type AccessConfig struct {
+	// File is the path of the access lists file (Default:
+	// `access.json` in the data directory).
+	File string `toml:"file"`
+	// WatchInterval is the interval at which the file is checked for
+	// changes (Default: "10s").
+	WatchInterval string `toml:"watch_interval"`
+	// AdminToken is the bearer token of the admin API, which manages the
+	// lists.  The admin API is disabled if empty.
+	AdminToken string `toml:"admin_token"`
+
+	watchInterval time.Duration
+}
+
+// Validate validates the access lists configuration, and parses the watch
+// interval.
+func (cfg *AccessConfig) Validate() error {
+	cfg.watchInterval = defaultAccessWatchInterval
+	if cfg.WatchInterval != "" {
+		var err error
+		if cfg.watchInterval, err = time.ParseDuration(cfg.WatchInterval); err != nil {
+			return fmt.Errorf("malformed watch interval: %w", err)
+		}
+		if cfg.watchInterval <= 0 {
+			return fmt.Errorf("watch interval must be positive")
+		}
+	}
+	return nil
+}
+
+// WatchIntervalDuration returns the parsed watch interval, or the default
+// if the configuration has not been validated.
+func (cfg *AccessConfig) WatchIntervalDuration() time.Duration {
+	if cfg.watchInterval <= 0 {
+		return defaultAccessWatchInterval
+	}
+	return cfg.watchInterval
+}
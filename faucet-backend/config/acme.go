@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ACMEConfig is the configuration of automatic TLS certificates via ACME
+// (eg: Let's Encrypt).
+type ACMEConfig struct {
+	// Domains are the domains to obtain certificates for.  ACME is
+	// disabled if empty.
+	Domains []string `toml:"domains"`
+	// Email is the contact address registered with the ACME server.
+	Email string `toml:"email"`
+	// DirectoryURL is the ACME server's directory URL (Default: Let's
+	// Encrypt).
+	DirectoryURL string `toml:"directory_url"`
+	// DirectoryCAFile is the PEM encoded CA certificate bundle that the
+	// ACME server's certificate is verified with, eg: for a local Pebble
+	// test server (Default: the system roots).
+	DirectoryCAFile string `toml:"directory_ca_file"`
+	// CacheDir is the directory where the account key and certificates
+	// are cached (Default: `acme` in the data directory).
+	CacheDir string `toml:"cache_dir"`
+}
+
+// Enabled returns true iff ACME is enabled.
+func (cfg *ACMEConfig) Enabled() bool {
+	return len(cfg.Domains) > 0
+}
+
+// Validate validates the ACME configuration.
+func (cfg *ACMEConfig) Validate() error {
+	for _, domain := range cfg.Domains {
+		if domain == "" || strings.ContainsAny(domain, "/:* ") {
+			return fmt.Errorf("malformed domain: '%s'", domain)
+		}
+	}
+	if cfg.DirectoryURL != "" {
+		u, err := url.Parse(cfg.DirectoryURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("malformed directory URL: '%s'", cfg.DirectoryURL)
+		}
+	}
+	return nil
+}
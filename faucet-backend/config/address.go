@@ -0,0 +1,34 @@
+package config
+
+import "fmt"
+
+const (
+	// AddressKindOasis is the kind of Bech32 encoded Oasis addresses.
+	AddressKindOasis = "oasis"
+	// AddressKindEth is the kind of hex encoded Ethereum addresses.
+	AddressKindEth = "eth"
+)
+
+// AddressKindsConfig is the kinds of addresses that may be funded on each
+// paratime, by paratime name.  Paratimes that are not listed use the
+// defaults: Ethereum addresses on Emerald, Oasis addresses on Cipher, both
+// on Sapphire, and otherwise Ethereum addresses if the paratime is EVM
+// compatible and Oasis addresses if not.
+type AddressKindsConfig map[string][]string
+
+// Validate validates the address kinds.
+func (cfg AddressKindsConfig) Validate() error {
+	for name, kinds := range cfg {
+		if len(kinds) == 0 {
+			return fmt.Errorf("paratime '%s': no address kinds", name)
+		}
+		for _, kind := range kinds {
+			switch kind {
+			case AddressKindOasis, AddressKindEth:
+			default:
+				return fmt.Errorf("paratime '%s': unknown address kind: '%s'", name, kind)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// AmountsConfig is the default and minimum funding amounts, for requests
+// to the consensus layer and to each paratime.
+type AmountsConfig struct {
+	// Consensus is the amounts for consensus requests.
+	Consensus AmountConfig `toml:"consensus"`
+	// ParaTimes is the amounts for paratime requests, by paratime name.
+	ParaTimes map[string]*AmountConfig `toml:"paratimes"`
+}
+
+// AmountConfig is the default and minimum funding amount.
+type AmountConfig struct {
+	// Default is the amount in tokens funded if the request does not
+	// specify one.  Requests without an amount are rejected if empty.
+	Default string `toml:"default"`
+	// Min is the minimum amount in tokens that may be requested.
+	Min string `toml:"min"`
+}
+
+// Validate validates the amounts, which must not exceed max, in tokens,
+// unless it is empty.
+func (cfg *AmountConfig) Validate(max string) error {
+	isTokens := func(s string) bool {
+		f, ok := new(big.Float).SetString(s)
+		return ok && f.Sign() >= 0
+	}
+
+	if cfg.Default != "" && !isTokens(cfg.Default) {
+		return fmt.Errorf("default must be an amount of tokens")
+	}
+	if cfg.Min != "" && !isTokens(cfg.Min) {
+		return fmt.Errorf("min must be an amount of tokens")
+	}
+	if cfg.Default != "" && cfg.Min != "" {
+		def, _ := new(big.Float).SetString(cfg.Default)
+		min, _ := new(big.Float).SetString(cfg.Min)
+		if def.Cmp(min) < 0 {
+			return fmt.Errorf("default must not be less than min")
+		}
+	}
+	if max != "" {
+		maxAmount, ok := new(big.Float).SetString(max)
+		if !ok {
+			return fmt.Errorf("max must be an amount of tokens")
+		}
+		for _, amount := range []string{cfg.Default, cfg.Min} {
+			if amount == "" {
+				continue
+			}
+			if f, _ := new(big.Float).SetString(amount); f.Cmp(maxAmount) > 0 {
+				return fmt.Errorf("'%s' exceeds the max of '%s'", amount, max)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate validates the amounts of the consensus layer and of each
+// paratime, the latter of which must not exceed maxParaTime, in tokens,
+// unless it is empty.  The consensus maximum is in base units, so it is
+// checked once the network is known.
+func (cfg *AmountsConfig) Validate(maxParaTime string) error {
+	if err := cfg.Consensus.Validate(""); err != nil {
+		return fmt.Errorf("consensus: %w", err)
+	}
+	for name, amounts := range cfg.ParaTimes {
+		if amounts == nil {
+			continue
+		}
+		if err := amounts.Validate(maxParaTime); err != nil {
+			return fmt.Errorf("paratime '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ForParaTime returns the amount configuration for the consensus layer
+// (empty paratime name) or the paratime.
+func (cfg *AmountsConfig) ForParaTime(paraTimeStr string) *AmountConfig {
+	if paraTimeStr == "" {
+		return &cfg.Consensus
+	}
+	if amounts := cfg.ParaTimes[paraTimeStr]; amounts != nil {
+		return amounts
+	}
+	return &AmountConfig{}
+}
@@ -0,0 +1,277 @@
+// Package config implements the faucet configuration.
+package config
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+	"unicode"
+
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+)
+
+// RequestTimeout is how long the faucet waits for a transaction to be
+// included in a block.
+const RequestTimeout = 60 * time.Second // TODO: Make configurable.
+
+// Config is the faucet configuration.
+type Config struct {
+	// DataDir is the base path where all of the faucet configuration data
+	// and log files will live.
+	DataDir string `toml:"data_dir"`
+
+	// DisableLogToFile disables logging to a file
+	DisableLogToFile bool `toml:"disable_log_to_file"`
+
+	// MetricsPullAddr is the address at which to serve prometheus metrics,
+	// unless listeners are configured.
+	MetricsPullAddr string `toml:"metrics_addr"`
+
+	// TargetAllowance is the target per-paratime allowance in base units.
+	TargetAllowance quantity.Quantity `toml:"target_allowance"`
+	// MaxConsensusFundAmount is the maximum amount of tokens funded to
+	// consensus addresses in base units.
+	MaxConsensusFundAmount quantity.Quantity `toml:"max_consensus_fund_amount"`
+	// MaxParatimeFundAmount is the maximum amount of tokens funded to
+	// paratime addresses in tokens.
+	MaxParatimeFundAmount string `toml:"max_paratime_fund_amount"`
+
+	// WebRoot is the base path where the static assets should be stored
+	// and served from, overriding the embedded frontend if any.
+	WebRoot string `toml:"web_root"`
+	// ListenAddr is the faucet RESTful API endpoint address, unless
+	// listeners are configured.
+	ListenAddr string `toml:"listen_addr"`
+	// GRPCListenAddr is the faucet gRPC API endpoint address, or empty to
+	// disable the gRPC API.  The gRPC API uses the same TLS certificate as
+	// the RESTful API.
+	GRPCListenAddr string `toml:"grpc_listen_addr"`
+	// TLSCertFile is the TLS certificate file.
+	TLSCertFile string `toml:"tls_cert_file"`
+	// TLSKeyFile is the TLS certificate key file.
+	TLSKeyFile string `toml:"tls_key_file"`
+	// Listeners are the HTTP listeners, each serving its own set of
+	// routes, replacing the listen and metrics addresses.  The admin
+	// routes are only served by listeners configured for them.
+	Listeners []ListenerConfig `toml:"listeners"`
+	// RedirectListenAddr is the address of a plaintext HTTP listener that
+	// only redirects to HTTPS, and answers ACME HTTP-01 challenges.  It
+	// is disabled if empty.
+	RedirectListenAddr string `toml:"redirect_listen_addr"`
+	// ReaptchaSharedSecret the reCAPTCHA V2 API shared secret for
+	// use in bot prevention.
+	RecaptchaSharedSecret string `toml:"recaptcha_shared_secret"`
+	// RecaptchaSiteKey is the reCAPTCHA V2 site key, injected into the
+	// frontend at runtime.
+	RecaptchaSiteKey string `toml:"recaptcha_site_key"`
+
+	// AddressKinds are the kinds of addresses that may be funded on each
+	// paratime, overriding the kinds derived from the network.
+	AddressKinds AddressKindsConfig `toml:"address_kinds"`
+
+	// Access is the configuration of the block and allow lists.
+	Access AccessConfig `toml:"access"`
+
+	// Proxy is the configuration of the trusted reverse proxies.
+	Proxy ProxyConfig `toml:"proxy"`
+
+	// RateLimits are the funding endpoints' rate limits.
+	RateLimits RateLimitConfig `toml:"rate_limits"`
+
+	// HTTP is the configuration of the HTTP server.
+	HTTP HTTPConfig `toml:"http"`
+
+	// ACME is the configuration of automatic TLS certificates.
+	ACME ACMEConfig `toml:"acme"`
+
+	// Amounts are the default and minimum funding amounts.
+	Amounts AmountsConfig `toml:"amounts"`
+
+	// BalancePolicy is the policy for funding accounts that already hold
+	// plenty of tokens.
+	BalancePolicy BalancePolicyConfig `toml:"balance_policy"`
+
+	// Reserve is the reserve-aware payout policy.
+	Reserve ReserveConfig `toml:"reserve"`
+
+	// DryRun enables the dry-run mode, in which transactions are signed
+	// but never submitted, and funding requests succeed without moving
+	// any tokens.
+	DryRun bool `toml:"dry_run"`
+
+	// Fees is the transaction fee policy.
+	Fees FeeConfig `toml:"fees"`
+
+	// MockChain is the configuration of the simulated chain, used only
+	// when running with -mock-chain.
+	MockChain *MockChainConfig `toml:"mock_chain"`
+
+	// Bundles are the named sets of funding requests that can be
+	// requested together with a single reCAPTCHA check.
+	Bundles map[string]*BundleConfig `toml:"bundles"`
+}
+
+// BundleConfig is a named set of funding requests.
+type BundleConfig struct {
+	// Items are the individual funding requests that make up the bundle.
+	Items []BundleItemConfig `toml:"items"`
+	// MaxClaimsPerDay is the maximum number of times the bundle may be
+	// claimed per day, 0 for unlimited.
+	MaxClaimsPerDay uint64 `toml:"max_claims_per_day"`
+}
+
+// BundleItemConfig is a single funding request that is part of a bundle.
+type BundleItemConfig struct {
+	// ParaTime is the paratime name, or empty for consensus.
+	ParaTime string `toml:"paratime"`
+	// Amount is the amount to fund in tokens.
+	Amount string `toml:"amount"`
+	// Denomination is the paratime denomination of a test token to fund,
+	// or empty for the native denomination.  Test tokens are transferred
+	// from the faucet's paratime account.
+	Denomination string `toml:"denomination"`
+}
+
+// TLSEnabled returns true iff the API is served over TLS, with either a
+// certificate from files or from ACME.
+func (cfg *Config) TLSEnabled() bool {
+	return cfg.TLSCertFile != "" || cfg.ACME.Enabled()
+}
+
+// Load loads and validates the configuration file.
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cfg: failed to read configuration: %w", err)
+	}
+
+	var cfg Config
+	if err = toml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("cfg: failed to parse configuration: %w", err)
+	}
+	envRecaptchaSharedSecret := os.Getenv("CAPTCHA_SHARED_SECRET")
+	if cfg.RecaptchaSharedSecret == "" && envRecaptchaSharedSecret != "" {
+		cfg.RecaptchaSharedSecret = envRecaptchaSharedSecret
+	}
+	envRecaptchaSiteKey := os.Getenv("CAPTCHA_SITE_KEY")
+	if cfg.RecaptchaSiteKey == "" && envRecaptchaSiteKey != "" {
+		cfg.RecaptchaSiteKey = envRecaptchaSiteKey
+	}
+	envAdminToken := os.Getenv("FAUCET_ADMIN_TOKEN")
+	if cfg.Access.AdminToken == "" && envAdminToken != "" {
+		cfg.Access.AdminToken = envAdminToken
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate validates the configuration.  Configurations that are not
+// loaded from a file, eg: when embedding the faucet, must be validated
+// before use.
+func (cfg *Config) Validate() error {
+	if cfg.DataDir == "" {
+		return fmt.Errorf("cfg: empty datadir")
+	}
+	if webRoot := cfg.WebRoot; webRoot != "" {
+		fi, err := os.Stat(webRoot)
+		if err != nil {
+			return fmt.Errorf("cfg: failed to stat webroot: %w", err)
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("cfg: webroot '%s' is not a directory", webRoot)
+		}
+	}
+	switch {
+	case len(cfg.Listeners) == 0 && cfg.ListenAddr == "":
+		return fmt.Errorf("cfg: empty listen addr")
+	case len(cfg.Listeners) > 0 && (cfg.ListenAddr != "" || cfg.MetricsPullAddr != ""):
+		return fmt.Errorf("cfg: listen and metrics addrs may not be combined with listeners")
+	}
+	if err := cfg.validateListeners(); err != nil {
+		return fmt.Errorf("cfg: invalid listeners: %w", err)
+	}
+	if (cfg.TLSCertFile == "" && cfg.TLSKeyFile != "") || (cfg.TLSCertFile != "" && cfg.TLSKeyFile == "") {
+		return fmt.Errorf("cfg: both the TLS certificate and key must be provided")
+	}
+	if err := cfg.ACME.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid acme configuration: %w", err)
+	}
+	if cfg.ACME.Enabled() && cfg.TLSCertFile != "" {
+		return fmt.Errorf("cfg: ACME and a TLS certificate are mutually exclusive")
+	}
+	if cfg.RedirectListenAddr != "" && !cfg.TLSEnabled() {
+		return fmt.Errorf("cfg: redirecting to HTTPS requires TLS")
+	}
+	if cfg.MaxParatimeFundAmount != "" {
+		for _, c := range cfg.MaxParatimeFundAmount {
+			if !unicode.IsDigit(c) {
+				return fmt.Errorf("cfg: max paratime fund amount is not a number")
+			}
+		}
+	}
+	if err := cfg.Fees.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid fee policy: %w", err)
+	}
+	if err := cfg.AddressKinds.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid address kinds: %w", err)
+	}
+	if err := cfg.Access.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid access lists: %w", err)
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid proxy configuration: %w", err)
+	}
+	if err := cfg.RateLimits.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid rate limits: %w", err)
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid http configuration: %w", err)
+	}
+	if err := cfg.Amounts.Validate(cfg.MaxParatimeFundAmount); err != nil {
+		return fmt.Errorf("cfg: invalid amounts: %w", err)
+	}
+	if err := cfg.BalancePolicy.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid balance policy: %w", err)
+	}
+	if err := cfg.Reserve.Validate(); err != nil {
+		return fmt.Errorf("cfg: invalid reserve policy: %w", err)
+	}
+	if cfg.MockChain != nil {
+		if err := cfg.MockChain.Validate(); err != nil {
+			return fmt.Errorf("cfg: invalid mock chain: %w", err)
+		}
+	}
+	for name, bundle := range cfg.Bundles {
+		if bundle == nil || len(bundle.Items) == 0 {
+			return fmt.Errorf("cfg: bundle '%s' has no items", name)
+		}
+		for _, item := range bundle.Items {
+			if item.Amount == "" {
+				return fmt.Errorf("cfg: bundle '%s' has an item with no amount", name)
+			}
+			if item.Denomination != "" && item.ParaTime == "" {
+				return fmt.Errorf("cfg: bundle '%s' has a consensus item with a denomination", name)
+			}
+			amount, ok := new(big.Float).SetString(item.Amount)
+			if !ok || amount.Sign() <= 0 {
+				return fmt.Errorf("cfg: bundle '%s' has an item with an invalid amount: '%s'", name, item.Amount)
+			}
+			// The consensus maximum is in base units, so it is checked
+			// once the network is known.
+			if item.ParaTime != "" && item.Denomination == "" && cfg.MaxParatimeFundAmount != "" {
+				max, _ := new(big.Float).SetString(cfg.MaxParatimeFundAmount)
+				if amount.Cmp(max) > 0 {
+					return fmt.Errorf("cfg: bundle '%s' has an item above the max paratime fund amount: '%s'", name, item.Amount)
+				}
+			}
+		}
+	}
+
+	return nil
+}
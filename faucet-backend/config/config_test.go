@@ -0,0 +1,730 @@
+package config
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+func testQuantity(t *testing.T, s string) *quantity.Quantity {
+	t.Helper()
+
+	var q quantity.Quantity
+	if err := q.UnmarshalText([]byte(s)); err != nil {
+		t.Fatalf("failed to parse quantity '%s': %v", s, err)
+	}
+	return &q
+}
+
+// newTestConfig returns a minimal valid configuration.
+func newTestConfig(t *testing.T) *Config {
+	t.Helper()
+
+	return &Config{
+		DataDir:    t.TempDir(),
+		ListenAddr: ":8080",
+	}
+}
+
+func TestLoad(t *testing.T) {
+	// The example configuration is valid.
+	cfg, err := Load(filepath.Join("..", "faucet-backend.toml"))
+	if err != nil {
+		t.Fatalf("Load example: %v", err)
+	}
+	if cfg.ListenAddr != ":8080" || cfg.MaxConsensusFundAmount.Cmp(testQuantity(t, "1000000000")) != 0 {
+		t.Errorf("Load example: unexpected configuration: %+v", cfg)
+	}
+
+	// Secrets may be provided by the environment instead.
+	path := filepath.Join(t.TempDir(), "faucet.toml")
+	write := func(s string) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatalf("failed to write configuration: %v", err)
+		}
+	}
+	write(`data_dir = "/var/faucet"
+listen_addr = ":8080"
+recaptcha_site_key = "site-key"
+`)
+	t.Setenv("CAPTCHA_SHARED_SECRET", "env-secret")
+	t.Setenv("CAPTCHA_SITE_KEY", "env-site-key")
+	t.Setenv("FAUCET_ADMIN_TOKEN", "env-admin-token")
+	if cfg, err = Load(path); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RecaptchaSharedSecret != "env-secret" || cfg.RecaptchaSiteKey != "site-key" || cfg.Access.AdminToken != "env-admin-token" {
+		t.Errorf("Load: unexpected secrets: %+v", cfg)
+	}
+
+	for _, s := range []string{
+		`data_dir = "/var/faucet"`,
+		`data_dir = [`,
+	} {
+		write(s)
+		if _, err = Load(path); err == nil {
+			t.Errorf("Load: invalid configuration accepted: %s", s)
+		}
+	}
+	if _, err = Load(filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Errorf("Load: missing configuration accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0o600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *Config)
+		valid  bool
+	}{
+		{"Minimal", func(*Config) {}, true},
+		{"NoDataDir", func(cfg *Config) { cfg.DataDir = "" }, false},
+		{"NoListenAddr", func(cfg *Config) { cfg.ListenAddr = "" }, false},
+		{"WebRoot", func(cfg *Config) { cfg.WebRoot = cfg.DataDir }, true},
+		{"MissingWebRoot", func(cfg *Config) { cfg.WebRoot = filepath.Join(cfg.DataDir, "missing") }, false},
+		{"WebRootNotDir", func(cfg *Config) { cfg.WebRoot = notDir }, false},
+		{"TLS", func(cfg *Config) { cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem" }, true},
+		{"TLSNoKey", func(cfg *Config) { cfg.TLSCertFile = "cert.pem" }, false},
+		{"TLSNoCert", func(cfg *Config) { cfg.TLSKeyFile = "key.pem" }, false},
+		{"MaxParaTimeFundAmount", func(cfg *Config) { cfg.MaxParatimeFundAmount = "100" }, true},
+		{"MaxParaTimeFundAmountDecimal", func(cfg *Config) { cfg.MaxParatimeFundAmount = "1.5" }, false},
+		{"Bundle", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{Amount: "1"}, {ParaTime: "sapphire", Amount: "1"}}}}
+		}, true},
+		{"BundleNoItems", func(cfg *Config) { cfg.Bundles = map[string]*BundleConfig{"dev": {}} }, false},
+		{"BundleNil", func(cfg *Config) { cfg.Bundles = map[string]*BundleConfig{"dev": nil} }, false},
+		{"BundleNoAmount", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "sapphire"}}}}
+		}, false},
+		{"BundleToken", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "pontusx", Amount: "1", Denomination: "TEST"}}}}
+		}, true},
+		{"BundleInvalidAmount", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "sapphire", Amount: "bogus"}}}}
+		}, false},
+		{"BundleZeroAmount", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "sapphire", Amount: "0"}}}}
+		}, false},
+		{"BundleAboveMaxParaTimeFundAmount", func(cfg *Config) {
+			cfg.MaxParatimeFundAmount = "10"
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "sapphire", Amount: "10.5"}}}}
+		}, false},
+		{"BundleTokenAboveMaxParaTimeFundAmount", func(cfg *Config) {
+			cfg.MaxParatimeFundAmount = "10"
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{ParaTime: "pontusx", Amount: "20", Denomination: "TEST"}}}}
+		}, true},
+		{"BundleConsensusToken", func(cfg *Config) {
+			cfg.Bundles = map[string]*BundleConfig{"dev": {Items: []BundleItemConfig{{Amount: "1", Denomination: "TEST"}}}}
+		}, false},
+		{"InvalidFees", func(cfg *Config) { cfg.Fees.MaxRetries = -1 }, false},
+		{"InvalidAmounts", func(cfg *Config) { cfg.Amounts.Consensus.Min = "bogus" }, false},
+		{"AmountsAboveMaxParaTimeFundAmount", func(cfg *Config) {
+			cfg.MaxParatimeFundAmount = "10"
+			cfg.Amounts.ParaTimes = map[string]*AmountConfig{"sapphire": {Default: "20"}}
+		}, false},
+		{"InvalidBalancePolicy", func(cfg *Config) { cfg.BalancePolicy.Mode = "bogus" }, false},
+		{"InvalidReserve", func(cfg *Config) { cfg.Reserve.Floor = "-1" }, false},
+		{"InvalidMockChain", func(cfg *Config) { cfg.MockChain = &MockChainConfig{TimeoutRate: 2} }, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			tc.modify(cfg)
+			if err := cfg.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestFees(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   FeeConfig
+		valid bool
+	}{
+		{FeeConfig{}, true},
+		{FeeConfig{GasMultiplier: 1.5, RetryFeeBump: 2, MaxRetries: 3}, true},
+		{FeeConfig{GasMultiplier: 0.5}, false},
+		{FeeConfig{RetryFeeBump: 0.5}, false},
+		{FeeConfig{MaxRetries: -1}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+		}
+	}
+
+	cfg := FeeConfig{GasMultiplier: 1.5, RetryFeeBump: 2}
+	if gas := cfg.ScaleGas(1001); gas != 1502 {
+		t.Errorf("ScaleGas: got %d, expected 1502", gas)
+	}
+	if gas := (&FeeConfig{}).ScaleGas(1001); gas != 1001 {
+		t.Errorf("ScaleGas unset: got %d, expected 1001", gas)
+	}
+
+	price := testQuantity(t, "100")
+	for _, tc := range []struct {
+		cfg      FeeConfig
+		price    *quantity.Quantity
+		attempt  int
+		expected string
+	}{
+		{cfg, price, 0, "100"},
+		{cfg, price, 1, "200"},
+		{cfg, price, 3, "800"},
+		{FeeConfig{}, price, 3, "100"},
+		{cfg, testQuantity(t, "0"), 3, "0"},
+	} {
+		bumped := tc.cfg.BumpPrice(tc.price, tc.attempt)
+		if bumped.String() != tc.expected {
+			t.Errorf("BumpPrice(%v, %d): got %v, expected %v", tc.price, tc.attempt, bumped, tc.expected)
+		}
+		if bumped == tc.price {
+			t.Errorf("BumpPrice(%v, %d): price not copied", tc.price, tc.attempt)
+		}
+	}
+}
+
+func TestAmounts(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   AmountConfig
+		max   string
+		valid bool
+	}{
+		{AmountConfig{}, "", true},
+		{AmountConfig{Default: "1.5", Min: "0.1"}, "", true},
+		{AmountConfig{Default: "1", Min: "1"}, "", true},
+		{AmountConfig{Default: "0.1", Min: "1"}, "", false},
+		{AmountConfig{Default: "-1"}, "", false},
+		{AmountConfig{Min: "bogus"}, "", false},
+		{AmountConfig{Default: "10", Min: "1"}, "10", true},
+		{AmountConfig{Default: "10.5"}, "10", false},
+		{AmountConfig{Min: "11"}, "10", false},
+	} {
+		if err := tc.cfg.Validate(tc.max); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v, %q): got error %v, expected valid: %v", tc.cfg, tc.max, err, tc.valid)
+		}
+	}
+
+	cfg := AmountsConfig{
+		Consensus: AmountConfig{Default: "10"},
+		ParaTimes: map[string]*AmountConfig{
+			"sapphire": {Default: "1"},
+			"emerald":  nil,
+		},
+	}
+	if err := cfg.Validate("10"); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	for paraTime, expected := range map[string]string{
+		"":         "10",
+		"sapphire": "1",
+		"emerald":  "",
+		"cipher":   "",
+	} {
+		if def := cfg.ForParaTime(paraTime).Default; def != expected {
+			t.Errorf("ForParaTime(%s): got default '%s', expected '%s'", paraTime, def, expected)
+		}
+	}
+
+	// The consensus amounts are not subject to the paratime maximum.
+	if err := cfg.Validate("1"); err != nil {
+		t.Errorf("Validate: consensus amounts checked against the paratime maximum: %v", err)
+	}
+	if err := cfg.Validate("0.5"); err == nil {
+		t.Errorf("Validate: paratime amounts above the maximum accepted")
+	}
+
+	cfg.ParaTimes["cipher"] = &AmountConfig{Min: "bogus"}
+	if err := cfg.Validate(""); err == nil {
+		t.Errorf("Validate: invalid paratime amounts accepted")
+	}
+}
+
+func TestBalancePolicy(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   BalancePolicyConfig
+		valid bool
+	}{
+		{BalancePolicyConfig{}, true},
+		{BalancePolicyConfig{Mode: BalancePolicyReject, Threshold: "100"}, true},
+		{BalancePolicyConfig{Mode: BalancePolicyScale, Threshold: "0.5"}, true},
+		{BalancePolicyConfig{Mode: BalancePolicyTopUp, Target: "10"}, true},
+		{BalancePolicyConfig{Mode: BalancePolicyReject}, false},
+		{BalancePolicyConfig{Mode: BalancePolicyScale, Threshold: "0"}, false},
+		{BalancePolicyConfig{Mode: BalancePolicyTopUp, Threshold: "10"}, false},
+		{BalancePolicyConfig{Mode: "bogus", Threshold: "10"}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   ReserveConfig
+		valid bool
+	}{
+		{ReserveConfig{}, true},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "1000", Factor: 0.5}, {Below: "100", Factor: 0.1}}, Floor: "1"}, true},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "1000", Factor: 1}}}, true},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "0", Factor: 0.5}}}, false},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "bogus", Factor: 0.5}}}, false},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "1000", Factor: 0}}}, false},
+		{ReserveConfig{Tiers: []ReserveTierConfig{{Below: "1000", Factor: 1.5}}}, false},
+		{ReserveConfig{Floor: "-1"}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+		}
+	}
+}
+
+func TestMockChain(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   MockChainConfig
+		valid bool
+		delay time.Duration
+	}{
+		{MockChainConfig{}, true, 0},
+		{MockChainConfig{DepositDelay: "6s", CheckTxFailureRate: 0.5, TimeoutRate: 0.5}, true, 6 * time.Second},
+		{MockChainConfig{CheckTxFailureRate: 0.6, TimeoutRate: 0.5}, false, 0},
+		{MockChainConfig{TimeoutRate: -0.1}, false, 0},
+		{MockChainConfig{DepositDelay: "bogus"}, false, 0},
+		{MockChainConfig{DepositDelay: "-1s"}, false, 0},
+		{MockChainConfig{DepositDelay: RequestTimeout.String()}, false, 0},
+	} {
+		err := tc.cfg.Validate()
+		if (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+			continue
+		}
+		if d := tc.cfg.DepositDelayDuration(); tc.valid && d != tc.delay {
+			t.Errorf("DepositDelayDuration(%+v): got %v, expected %v", tc.cfg, d, tc.delay)
+		}
+	}
+}
+
+func TestAddressKinds(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   AddressKindsConfig
+		valid bool
+	}{
+		{"Empty", nil, true},
+		{"Valid", AddressKindsConfig{"emerald": {AddressKindOasis, AddressKindEth}, "cipher": {AddressKindOasis}}, true},
+		{"NoKinds", AddressKindsConfig{"emerald": {}}, false},
+		{"UnknownKind", AddressKindsConfig{"emerald": {"btc"}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.AddressKinds = tc.cfg
+			if err := cfg.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestAccess(t *testing.T) {
+	for _, tc := range []struct {
+		cfg      AccessConfig
+		valid    bool
+		interval time.Duration
+	}{
+		{AccessConfig{}, true, defaultAccessWatchInterval},
+		{AccessConfig{WatchInterval: "1m"}, true, time.Minute},
+		{AccessConfig{WatchInterval: "0s"}, false, 0},
+		{AccessConfig{WatchInterval: "bogus"}, false, 0},
+	} {
+		err := tc.cfg.Validate()
+		if (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+			continue
+		}
+		if d := tc.cfg.WatchIntervalDuration(); tc.valid && d != tc.interval {
+			t.Errorf("WatchIntervalDuration(%+v): got %v, expected %v", tc.cfg, d, tc.interval)
+		}
+	}
+
+	// The default applies until validated.
+	if d := (&AccessConfig{WatchInterval: "1m"}).WatchIntervalDuration(); d != defaultAccessWatchInterval {
+		t.Errorf("WatchIntervalDuration: got %v before validation, expected the default", d)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   ProxyConfig
+		valid bool
+	}{
+		{ProxyConfig{}, true},
+		{ProxyConfig{TrustedProxies: []string{"10.0.0.0/8", "::1"}, Headers: []string{"forwarded", "X-Real-IP"}}, true},
+		{ProxyConfig{TrustedProxies: []string{"10.0.0"}}, false},
+		{ProxyConfig{Headers: []string{"x-client-ip"}}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+		}
+	}
+
+	// Nothing is trusted until validated.
+	cfg := ProxyConfig{TrustedProxies: []string{"10.0.0.0/8"}}
+	header := http.Header{"X-Forwarded-For": {"198.51.100.1"}}
+	if ip := cfg.NewResolver().Resolve("10.0.0.1:1234", header); ip.String() != "10.0.0.1" {
+		t.Errorf("Resolve: got %v before validation, expected the peer", ip)
+	}
+
+	// The headers default to X-Forwarded-For.
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	r := cfg.NewResolver()
+	if ip := r.Resolve("10.0.0.1:1234", header); ip.String() != "198.51.100.1" {
+		t.Errorf("Resolve: got %v, expected the forwarded address", ip)
+	}
+	if s := r.Display(net.ParseIP("198.51.100.1")); s != "198.51.100.1" {
+		t.Errorf("Display: got %v without a salt, expected the address", s)
+	}
+}
+
+func TestRateLimits(t *testing.T) {
+	for _, tc := range []struct {
+		cfg   RateLimitConfig
+		valid bool
+	}{
+		{RateLimitConfig{}, true},
+		{RateLimitConfig{PerClient: RateConfig{PerMinute: 1, Burst: 3}, Global: RateConfig{PerMinute: 60}, MaxConcurrentCaptcha: 4}, true},
+		{RateLimitConfig{PerClient: RateConfig{PerMinute: -1}}, false},
+		{RateLimitConfig{Global: RateConfig{Burst: -1}}, false},
+		{RateLimitConfig{PerAllowlisted: RateConfig{PerMinute: -1}}, false},
+		{RateLimitConfig{Balance: RateConfig{PerMinute: -1}}, false},
+		{RateLimitConfig{MaxConcurrentCaptcha: -1}, false},
+	} {
+		if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v): got error %v, expected valid: %v", tc.cfg, err, tc.valid)
+		}
+	}
+
+	var cfg RateLimitConfig
+	if cfg.PerClient.Enabled() || cfg.PerClient.BurstSize() != 1 || cfg.CaptchaConcurrency() != defaultMaxConcurrentCaptcha {
+		t.Errorf("unexpected defaults: enabled: %v, burst: %d, captcha: %d", cfg.PerClient.Enabled(), cfg.PerClient.BurstSize(), cfg.CaptchaConcurrency())
+	}
+	cfg = RateLimitConfig{PerClient: RateConfig{PerMinute: 1, Burst: 3}, MaxConcurrentCaptcha: 4}
+	if !cfg.PerClient.Enabled() || cfg.PerClient.BurstSize() != 3 || cfg.CaptchaConcurrency() != 4 {
+		t.Errorf("unexpected limits: enabled: %v, burst: %d, captcha: %d", cfg.PerClient.Enabled(), cfg.PerClient.BurstSize(), cfg.CaptchaConcurrency())
+	}
+
+	// Allowlisted clients get the per client limit, unless configured.
+	if rate := cfg.AllowlistedRate(); *rate != cfg.PerClient {
+		t.Errorf("AllowlistedRate: got %+v, expected the per client limit", rate)
+	}
+	cfg.PerAllowlisted = RateConfig{PerMinute: 10, Burst: 5}
+	if rate := cfg.AllowlistedRate(); *rate != cfg.PerAllowlisted {
+		t.Errorf("AllowlistedRate: got %+v, expected the per allowlisted limit", rate)
+	}
+
+	// Balance queries are always limited.
+	if rate := cfg.BalanceRate(); *rate != defaultBalanceRate {
+		t.Errorf("BalanceRate: got %+v, expected the default limit", rate)
+	}
+	cfg.Balance = RateConfig{PerMinute: 120, Burst: 10}
+	if rate := cfg.BalanceRate(); *rate != cfg.Balance {
+		t.Errorf("BalanceRate: got %+v, expected the balance limit", rate)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		cfg   HTTPConfig
+		valid bool
+	}{
+		{"Empty", HTTPConfig{}, true},
+		{"Timeouts", HTTPConfig{ReadHeaderTimeout: "5s", ReadTimeout: "10s", WriteTimeout: "1m", IdleTimeout: "5m"}, true},
+		{"MalformedTimeout", HTTPConfig{ReadTimeout: "bogus"}, false},
+		{"NegativeTimeout", HTTPConfig{IdleTimeout: "-1s"}, false},
+		{"NegativeSize", HTTPConfig{MaxBodyBytes: -1}, false},
+		{"TLS13", HTTPConfig{TLS: TLSConfig{MinVersion: "1.3"}}, true},
+		{"TLS11", HTTPConfig{TLS: TLSConfig{MinVersion: "1.1"}}, false},
+		{"CipherSuite", HTTPConfig{TLS: TLSConfig{CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}}}, true},
+		{"InsecureCipherSuite", HTTPConfig{TLS: TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}}, false},
+		{"FrameOptions", HTTPConfig{SecurityHeaders: SecurityHeadersConfig{FrameOptions: "sameorigin", HSTSMaxAge: "1h"}}, true},
+		{"UnknownFrameOptions", HTTPConfig{SecurityHeaders: SecurityHeadersConfig{FrameOptions: "ALLOW-FROM"}}, false},
+		{"MalformedHSTSMaxAge", HTTPConfig{SecurityHeaders: SecurityHeadersConfig{HSTSMaxAge: "0s"}}, false},
+		{"CORS", HTTPConfig{CORS: CORSConfig{AllowedOrigins: []string{"*", "https://app.example.com"}, AllowedHeaders: []string{"X-Request-ID"}, MaxAge: "1h"}}, true},
+		{"MalformedOrigin", HTTPConfig{CORS: CORSConfig{AllowedOrigins: []string{"app.example.com"}}}, false},
+		{"MalformedHeader", HTTPConfig{CORS: CORSConfig{AllowedHeaders: []string{"X-A, X-B"}}}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+
+	t.Run("Defaults", func(t *testing.T) {
+		var cfg HTTPConfig
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+		readHeader, read, write, idle := cfg.Timeouts()
+		if readHeader != defaultReadHeaderTimeout || read != defaultReadTimeout || write != defaultWriteTimeout || idle != defaultIdleTimeout {
+			t.Errorf("Timeouts: got %v %v %v %v, expected the defaults", readHeader, read, write, idle)
+		}
+		if cfg.HeaderLimit() != defaultMaxHeaderBytes || cfg.BodyLimit() != defaultMaxBodyBytes {
+			t.Errorf("limits: got %d %d, expected the defaults", cfg.HeaderLimit(), cfg.BodyLimit())
+		}
+		if tlsCfg := cfg.TLS.Config(); tlsCfg.MinVersion != tls.VersionTLS12 || tlsCfg.CipherSuites != nil {
+			t.Errorf("TLS: unexpected configuration: %+v", tlsCfg)
+		}
+		if hsts := cfg.SecurityHeaders.HSTS(); hsts != "max-age=31536000" {
+			t.Errorf("HSTS: got '%s'", hsts)
+		}
+		if opt := cfg.SecurityHeaders.FrameOptionsHeader(); opt != "DENY" {
+			t.Errorf("FrameOptionsHeader: got '%s'", opt)
+		}
+		if cfg.CORS.AllowOrigin("https://app.example.com") != "" || cfg.CORS.MaxAgeSeconds() != 600 {
+			t.Errorf("CORS: unexpected defaults")
+		}
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		cfg := HTTPConfig{
+			WriteTimeout: "1m",
+			MaxBodyBytes: 128,
+			TLS: TLSConfig{
+				MinVersion:   "1.3",
+				CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"},
+			},
+			SecurityHeaders: SecurityHeadersConfig{
+				HSTSMaxAge:   "1h",
+				FrameOptions: "none",
+			},
+			CORS: CORSConfig{
+				AllowedOrigins: []string{"https://app.example.com"},
+				AllowedHeaders: []string{"X-Request-ID"},
+				MaxAge:         "1h",
+			},
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+		if _, _, write, _ := cfg.Timeouts(); write != time.Minute || cfg.BodyLimit() != 128 {
+			t.Errorf("limits: got %v %d", write, cfg.BodyLimit())
+		}
+		tlsCfg := cfg.TLS.Config()
+		if tlsCfg.MinVersion != tls.VersionTLS13 || len(tlsCfg.CipherSuites) != 1 || tlsCfg.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+			t.Errorf("TLS: unexpected configuration: %+v", tlsCfg)
+		}
+		if hsts := cfg.SecurityHeaders.HSTS(); hsts != "max-age=3600" {
+			t.Errorf("HSTS: got '%s'", hsts)
+		}
+		cfg.SecurityHeaders.DisableHSTS = true
+		if hsts := cfg.SecurityHeaders.HSTS(); hsts != "" {
+			t.Errorf("HSTS disabled: got '%s'", hsts)
+		}
+		if opt := cfg.SecurityHeaders.FrameOptionsHeader(); opt != "" {
+			t.Errorf("FrameOptionsHeader: got '%s'", opt)
+		}
+
+		for origin, expected := range map[string]string{
+			"":                         "",
+			"https://app.example.com":  "https://app.example.com",
+			"https://APP.example.com/": "https://APP.example.com/",
+			"https://evil.example.com": "",
+		} {
+			if allowed := cfg.CORS.AllowOrigin(origin); allowed != expected {
+				t.Errorf("AllowOrigin(%s): got '%s', expected '%s'", origin, allowed, expected)
+			}
+		}
+		if headers := cfg.CORS.AllowHeaders(); headers != "Content-Type, X-API-Key, X-Request-ID" {
+			t.Errorf("AllowHeaders: got '%s'", headers)
+		}
+		if maxAge := cfg.CORS.MaxAgeSeconds(); maxAge != 3600 {
+			t.Errorf("MaxAgeSeconds: got %d", maxAge)
+		}
+	})
+}
+
+func TestACME(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(cfg *Config)
+		valid  bool
+	}{
+		{"Disabled", func(*Config) {}, true},
+		{"Enabled", func(cfg *Config) {
+			cfg.ACME = ACMEConfig{Domains: []string{"faucet.example.com"}, DirectoryURL: "https://localhost:14000/dir"}
+		}, true},
+		{"MalformedDomain", func(cfg *Config) { cfg.ACME.Domains = []string{"*.example.com"} }, false},
+		{"EmptyDomain", func(cfg *Config) { cfg.ACME.Domains = []string{""} }, false},
+		{"MalformedDirectoryURL", func(cfg *Config) { cfg.ACME.DirectoryURL = "ftp://localhost/dir" }, false},
+		{"WithCertificate", func(cfg *Config) {
+			cfg.ACME.Domains = []string{"faucet.example.com"}
+			cfg.TLSCertFile, cfg.TLSKeyFile = "cert.pem", "key.pem"
+		}, false},
+		{"Redirect", func(cfg *Config) {
+			cfg.ACME.Domains = []string{"faucet.example.com"}
+			cfg.RedirectListenAddr = ":80"
+		}, true},
+		{"RedirectWithoutTLS", func(cfg *Config) { cfg.RedirectListenAddr = ":80" }, false},
+		{"RedirectSameAddr", func(cfg *Config) {
+			cfg.ACME.Domains = []string{"faucet.example.com"}
+			cfg.Listeners = []ListenerConfig{{Addr: ":443", Routes: []string{RouteAPI}}}
+			cfg.ListenAddr, cfg.RedirectListenAddr = "", ":443"
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			tc.modify(cfg)
+			if err := cfg.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+			if tc.valid && cfg.TLSEnabled() != cfg.ACME.Enabled() {
+				t.Errorf("TLSEnabled: got %v, expected %v", cfg.TLSEnabled(), cfg.ACME.Enabled())
+			}
+		})
+	}
+}
+
+func TestListeners(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		listeners  []ListenerConfig
+		adminToken string
+		valid      bool
+	}{
+		{"Separate", []ListenerConfig{
+			{Addr: ":8080", Routes: []string{RouteAPI, RouteStatic}},
+			{Addr: "unix:/run/faucet/admin.sock", Routes: []string{RouteAdmin, RouteMetrics}},
+		}, "admin-token", true},
+		{"ListenerToken", []ListenerConfig{
+			{Addr: "unix:/run/faucet/admin.sock", Routes: []string{RouteAdmin}, BearerToken: "ops-token"},
+		}, "", true},
+		{"AdminWithoutToken", []ListenerConfig{
+			{Addr: "unix:/run/faucet/admin.sock", Routes: []string{RouteAdmin}},
+		}, "", false},
+		{"AdminOnPublic", []ListenerConfig{
+			{Addr: ":8080", Routes: []string{RouteAPI, RouteAdmin}},
+		}, "admin-token", false},
+		{"UnknownRoute", []ListenerConfig{
+			{Addr: ":8080", Routes: []string{"debug"}},
+		}, "admin-token", false},
+		{"NoRoutes", []ListenerConfig{
+			{Addr: ":8080"},
+		}, "admin-token", false},
+		{"EmptyAddr", []ListenerConfig{
+			{Addr: "unix:", Routes: []string{RouteMetrics}},
+		}, "admin-token", false},
+		{"DuplicateAddr", []ListenerConfig{
+			{Addr: ":8080", Routes: []string{RouteAPI}},
+			{Addr: ":8080", Routes: []string{RouteMetrics}},
+		}, "admin-token", false},
+		{"ListenerCert", []ListenerConfig{
+			{Addr: ":8443", Routes: []string{RouteAPI}, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+		}, "admin-token", true},
+		{"ListenerCertNoKey", []ListenerConfig{
+			{Addr: ":8443", Routes: []string{RouteAPI}, TLSCertFile: "cert.pem"},
+		}, "admin-token", false},
+		{"PlaintextCert", []ListenerConfig{
+			{Addr: ":8080", Routes: []string{RouteAPI}, Plaintext: true, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+		}, "admin-token", false},
+		{"UnixCert", []ListenerConfig{
+			{Addr: "unix:/run/faucet/api.sock", Routes: []string{RouteAPI}, TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"},
+		}, "admin-token", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.ListenAddr = ""
+			cfg.Listeners = tc.listeners
+			cfg.Access.AdminToken = tc.adminToken
+			if err := cfg.Validate(); (err == nil) != tc.valid {
+				t.Fatalf("Validate: got error %v, expected valid: %v", err, tc.valid)
+			}
+		})
+	}
+
+	t.Run("Combined", func(t *testing.T) {
+		// The listen and metrics addresses are replaced by the listeners.
+		cfg := newTestConfig(t)
+		cfg.Listeners = []ListenerConfig{{Addr: ":8081", Routes: []string{RouteAPI}}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate: listen addr accepted with listeners")
+		}
+		cfg.ListenAddr, cfg.MetricsPullAddr = "", ":7000"
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate: metrics addr accepted with listeners")
+		}
+
+		// The gRPC listener's address may not be reused either.
+		cfg.MetricsPullAddr, cfg.GRPCListenAddr = "", ":8081"
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate: gRPC listen addr reused")
+		}
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		cfg := newTestConfig(t)
+		listeners := cfg.HTTPListeners()
+		if len(listeners) != 2 {
+			t.Fatalf("HTTPListeners: unexpected listeners: %+v", listeners)
+		}
+		if l := listeners[0]; l.Addr != ":8080" || !l.HasRoute(RouteAPI) || !l.HasRoute(RouteStatic) || l.HasRoute(RouteAdmin) || l.Plaintext {
+			t.Errorf("HTTPListeners: unexpected public listener: %+v", l)
+		}
+		if l := listeners[1]; l.Addr != metrics.DefaultPullAddr || !l.HasRoute(RouteMetrics) || !l.Plaintext {
+			t.Errorf("HTTPListeners: unexpected metrics listener: %+v", l)
+		}
+		cfg.MetricsPullAddr = ":7001"
+		if l := cfg.HTTPListeners()[1]; l.Addr != ":7001" {
+			t.Errorf("HTTPListeners: unexpected metrics listener: %+v", l)
+		}
+		if addr := cfg.PublicListenAddr(); addr != ":8080" {
+			t.Errorf("PublicListenAddr: got '%s', expected ':8080'", addr)
+		}
+	})
+
+	t.Run("PublicListenAddr", func(t *testing.T) {
+		cfg := newTestConfig(t)
+		cfg.ListenAddr = ""
+		cfg.Listeners = []ListenerConfig{
+			{Addr: "unix:/run/faucet/api.sock", Routes: []string{RouteAPI}},
+			{Addr: ":8080", Routes: []string{RouteAPI}, Plaintext: true},
+			{Addr: ":8443", Routes: []string{RouteAPI, RouteStatic}},
+		}
+		if addr := cfg.PublicListenAddr(); addr != ":8443" {
+			t.Errorf("PublicListenAddr: got '%s', expected ':8443'", addr)
+		}
+	})
+
+	t.Run("Network", func(t *testing.T) {
+		for _, tc := range []struct {
+			addr    string
+			network string
+			path    string
+		}{
+			{":8080", "tcp", ":8080"},
+			{"unix:/run/faucet/admin.sock", "unix", "/run/faucet/admin.sock"},
+		} {
+			l := ListenerConfig{Addr: tc.addr}
+			if network, path := l.Network(); network != tc.network || path != tc.path || l.IsUnix() != (tc.network == "unix") {
+				t.Errorf("Network(%s): got %s %s", tc.addr, network, path)
+			}
+		}
+	})
+}
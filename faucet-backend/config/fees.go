@@ -0,0 +1,66 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+)
+
+// FeeConfig is the transaction fee policy.
+type FeeConfig struct {
+	// ConsensusGasPrice is the consensus gas price in base units.
+	ConsensusGasPrice quantity.Quantity `toml:"consensus_gas_price"`
+	// ParaTimeGasPrices are the per-paratime gas prices in base units
+	// of the native denomination, keyed by paratime name.
+	ParaTimeGasPrices map[string]quantity.Quantity `toml:"paratime_gas_prices"`
+	// QueryMinGasPrice enables using the paratime's minimum gas price
+	// as reported by the node, if it is higher than the configured one.
+	QueryMinGasPrice bool `toml:"query_min_gas_price"`
+	// GasMultiplier is applied to all gas estimates (Default: 1).
+	GasMultiplier float64 `toml:"gas_multiplier"`
+	// MaxRetries is the number of times a transaction that failed to be
+	// submitted or included is retried.
+	MaxRetries int `toml:"max_retries"`
+	// RetryFeeBump is the factor the gas price is multiplied by on each
+	// retry (Default: 1).
+	RetryFeeBump float64 `toml:"retry_fee_bump"`
+}
+
+// Validate validates the fee policy.
+func (cfg *FeeConfig) Validate() error {
+	if cfg.GasMultiplier != 0 && cfg.GasMultiplier < 1 {
+		return fmt.Errorf("gas multiplier must be at least 1")
+	}
+	if cfg.RetryFeeBump != 0 && cfg.RetryFeeBump < 1 {
+		return fmt.Errorf("retry fee bump must be at least 1")
+	}
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("max retries must not be negative")
+	}
+	return nil
+}
+
+// ScaleGas applies the gas multiplier to a gas estimate.
+func (cfg *FeeConfig) ScaleGas(gas uint64) uint64 {
+	if cfg.GasMultiplier <= 1 {
+		return gas
+	}
+	return uint64(math.Ceil(float64(gas) * cfg.GasMultiplier))
+}
+
+// BumpPrice applies the retry fee bump for the given attempt to a gas
+// price.
+func (cfg *FeeConfig) BumpPrice(price *quantity.Quantity, attempt int) *quantity.Quantity {
+	if cfg.RetryFeeBump <= 1 || attempt == 0 || price.IsZero() {
+		return price.Clone()
+	}
+
+	factor := new(big.Float).SetFloat64(math.Pow(cfg.RetryFeeBump, float64(attempt)))
+	bumped, _ := new(big.Float).Mul(new(big.Float).SetInt(price.ToBigInt()), factor).Int(nil)
+
+	var q quantity.Quantity
+	_ = q.FromBigInt(bumped)
+	return &q
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	defaultReadHeaderTimeout = 10 * time.Second
+	defaultReadTimeout       = 30 * time.Second
+	defaultWriteTimeout      = 30 * time.Second
+	defaultIdleTimeout       = 120 * time.Second
+	defaultMaxHeaderBytes    = 1 << 20
+	defaultMaxBodyBytes      = 64 * 1024
+
+	defaultHSTSMaxAge   = 365 * 24 * time.Hour
+	defaultFrameOptions = "DENY"
+	defaultCORSMaxAge   = 10 * time.Minute
+)
+
+// HTTPConfig is the configuration of the HTTP server.
+type HTTPConfig struct {
+	// ReadHeaderTimeout is the time allowed to read the request headers
+	// (Default: "10s").
+	ReadHeaderTimeout string `toml:"read_header_timeout"`
+	// ReadTimeout is the time allowed to read the entire request
+	// (Default: "30s").
+	ReadTimeout string `toml:"read_timeout"`
+	// WriteTimeout is the time allowed to write the response, which does
+	// not apply to Server-Sent Event streams (Default: "30s").
+	WriteTimeout string `toml:"write_timeout"`
+	// IdleTimeout is how long idle keep-alive connections are kept open
+	// (Default: "120s").
+	IdleTimeout string `toml:"idle_timeout"`
+	// MaxHeaderBytes is the maximum size of the request headers
+	// (Default: 1 MiB).
+	MaxHeaderBytes int `toml:"max_header_bytes"`
+	// MaxBodyBytes is the maximum size of request bodies (Default: 64 KiB).
+	MaxBodyBytes int64 `toml:"max_body_bytes"`
+
+	// HTTP2 is the HTTP/2 configuration.
+	HTTP2 HTTP2Config `toml:"http2"`
+	// TLS is the TLS configuration, used if a certificate is configured.
+	TLS TLSConfig `toml:"tls"`
+	// SecurityHeaders are the security headers sent with every response.
+	SecurityHeaders SecurityHeadersConfig `toml:"security_headers"`
+	// CORS is the cross-origin resource sharing configuration of the API.
+	CORS CORSConfig `toml:"cors"`
+
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+}
+
+// parseDuration parses an optional, positive duration.
+func parseDuration(what, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("malformed %s: %w", what, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", what)
+	}
+	return d, nil
+}
+
+// orDefault returns the duration, or the default if it is unset.
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
+
+// Validate validates the HTTP server configuration, and parses the
+// timeouts.
+func (cfg *HTTPConfig) Validate() error {
+	var err error
+	for _, v := range []struct {
+		what string
+		s    string
+		d    *time.Duration
+	}{
+		{"read header timeout", cfg.ReadHeaderTimeout, &cfg.readHeaderTimeout},
+		{"read timeout", cfg.ReadTimeout, &cfg.readTimeout},
+		{"write timeout", cfg.WriteTimeout, &cfg.writeTimeout},
+		{"idle timeout", cfg.IdleTimeout, &cfg.idleTimeout},
+	} {
+		if *v.d, err = parseDuration(v.what, v.s); err != nil {
+			return err
+		}
+	}
+	if cfg.MaxHeaderBytes < 0 || cfg.MaxBodyBytes < 0 {
+		return fmt.Errorf("size limits must be non-negative")
+	}
+	if err = cfg.TLS.Validate(); err != nil {
+		return fmt.Errorf("tls: %w", err)
+	}
+	if err = cfg.SecurityHeaders.Validate(); err != nil {
+		return fmt.Errorf("security headers: %w", err)
+	}
+	if err = cfg.CORS.Validate(); err != nil {
+		return fmt.Errorf("cors: %w", err)
+	}
+	return nil
+}
+
+// Timeouts returns the read header, read, write and idle timeouts.
+func (cfg *HTTPConfig) Timeouts() (time.Duration, time.Duration, time.Duration, time.Duration) {
+	return orDefault(cfg.readHeaderTimeout, defaultReadHeaderTimeout),
+		orDefault(cfg.readTimeout, defaultReadTimeout),
+		orDefault(cfg.writeTimeout, defaultWriteTimeout),
+		orDefault(cfg.idleTimeout, defaultIdleTimeout)
+}
+
+// HeaderLimit returns the maximum size of the request headers.
+func (cfg *HTTPConfig) HeaderLimit() int {
+	if cfg.MaxHeaderBytes == 0 {
+		return defaultMaxHeaderBytes
+	}
+	return cfg.MaxHeaderBytes
+}
+
+// BodyLimit returns the maximum size of request bodies.
+func (cfg *HTTPConfig) BodyLimit() int64 {
+	if cfg.MaxBodyBytes == 0 {
+		return defaultMaxBodyBytes
+	}
+	return cfg.MaxBodyBytes
+}
+
+// HTTP2Config is the HTTP/2 configuration.  HTTP/2 is only served over
+// TLS.
+type HTTP2Config struct {
+	// Disable disables HTTP/2.
+	Disable bool `toml:"disable"`
+	// MaxConcurrentStreams is the maximum number of concurrent streams
+	// per connection (Default: 250).
+	MaxConcurrentStreams uint32 `toml:"max_concurrent_streams"`
+	// MaxReadFrameSize is the maximum frame size that will be read
+	// (Default: 1 MiB).
+	MaxReadFrameSize uint32 `toml:"max_read_frame_size"`
+}
+
+// TLSConfig is the TLS configuration.
+type TLSConfig struct {
+	// MinVersion is the minimum TLS version, "1.2" or "1.3"
+	// (Default: "1.2").
+	MinVersion string `toml:"min_version"`
+	// CipherSuites are the names of the TLS 1.2 cipher suites, eg:
+	// "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256" (Default: Go's secure
+	// cipher suites).  TLS 1.3 cipher suites are not configurable.
+	CipherSuites []string `toml:"cipher_suites"`
+
+	minVersion   uint16
+	cipherSuites []uint16
+}
+
+// Validate validates the TLS configuration.
+func (cfg *TLSConfig) Validate() error {
+	switch cfg.MinVersion {
+	case "", "1.2":
+		cfg.minVersion = tls.VersionTLS12
+	case "1.3":
+		cfg.minVersion = tls.VersionTLS13
+	default:
+		return fmt.Errorf("unsupported minimum version: '%s'", cfg.MinVersion)
+	}
+
+	cfg.cipherSuites = nil
+	for _, name := range cfg.CipherSuites {
+		var id uint16
+		for _, suite := range tls.CipherSuites() {
+			if suite.Name == name {
+				id = suite.ID
+				break
+			}
+		}
+		if id == 0 {
+			return fmt.Errorf("unknown or insecure cipher suite: '%s'", name)
+		}
+		cfg.cipherSuites = append(cfg.cipherSuites, id)
+	}
+	return nil
+}
+
+// Config returns the TLS configuration, without certificates.
+func (cfg *TLSConfig) Config() *tls.Config {
+	minVersion := cfg.minVersion
+	if minVersion == 0 {
+		minVersion = tls.VersionTLS12
+	}
+	return &tls.Config{
+		MinVersion:   minVersion,
+		CipherSuites: cfg.cipherSuites,
+	}
+}
+
+// SecurityHeadersConfig is the configuration of the security headers.
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy is the Content-Security-Policy of the static
+	// site, which is not sent if empty.  API responses always carry a
+	// policy that forbids everything.
+	ContentSecurityPolicy string `toml:"content_security_policy"`
+	// HSTSMaxAge is the max-age of the Strict-Transport-Security header,
+	// sent over TLS only (Default: "8760h").
+	HSTSMaxAge string `toml:"hsts_max_age"`
+	// DisableHSTS disables the Strict-Transport-Security header.
+	DisableHSTS bool `toml:"disable_hsts"`
+	// FrameOptions is the X-Frame-Options header, "DENY" or "SAMEORIGIN",
+	// or "none" to omit it (Default: "DENY").
+	FrameOptions string `toml:"frame_options"`
+
+	hstsMaxAge time.Duration
+}
+
+// Validate validates the security headers configuration.
+func (cfg *SecurityHeadersConfig) Validate() error {
+	var err error
+	if cfg.hstsMaxAge, err = parseDuration("hsts max age", cfg.HSTSMaxAge); err != nil {
+		return err
+	}
+	switch strings.ToUpper(cfg.FrameOptions) {
+	case "", "DENY", "SAMEORIGIN", "NONE":
+	default:
+		return fmt.Errorf("unsupported frame options: '%s'", cfg.FrameOptions)
+	}
+	return nil
+}
+
+// HSTS returns the Strict-Transport-Security header, or empty if disabled.
+func (cfg *SecurityHeadersConfig) HSTS() string {
+	if cfg.DisableHSTS {
+		return ""
+	}
+	return fmt.Sprintf("max-age=%d", int64(orDefault(cfg.hstsMaxAge, defaultHSTSMaxAge).Seconds()))
+}
+
+// FrameOptionsHeader returns the X-Frame-Options header, or empty if
+// disabled.
+func (cfg *SecurityHeadersConfig) FrameOptionsHeader() string {
+	switch opt := strings.ToUpper(cfg.FrameOptions); opt {
+	case "":
+		return defaultFrameOptions
+	case "NONE":
+		return ""
+	default:
+		return opt
+	}
+}
+
+// CORSConfig is the cross-origin resource sharing configuration of the
+// API, which allows other dApp frontends to call the faucet from the
+// browser.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to call the API, eg:
+	// "https://app.example.com", or "*" for any.  CORS is disabled if
+	// empty.
+	AllowedOrigins []string `toml:"allowed_origins"`
+	// AllowedHeaders are the request headers allowed in addition to
+	// Content-Type and X-API-Key.
+	AllowedHeaders []string `toml:"allowed_headers"`
+	// MaxAge is how long preflight responses may be cached
+	// (Default: "10m").
+	MaxAge string `toml:"max_age"`
+
+	maxAge time.Duration
+}
+
+// Validate validates the CORS configuration.
+func (cfg *CORSConfig) Validate() error {
+	var err error
+	if cfg.maxAge, err = parseDuration("max age", cfg.MaxAge); err != nil {
+		return err
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("malformed origin: '%s'", origin)
+		}
+	}
+	for _, header := range cfg.AllowedHeaders {
+		if header == "" || strings.ContainsAny(header, " ,") {
+			return fmt.Errorf("malformed header: '%s'", header)
+		}
+	}
+	return nil
+}
+
+// AllowOrigin returns the Access-Control-Allow-Origin header for the
+// request's origin, or empty if the origin is not allowed.
+func (cfg *CORSConfig) AllowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		switch {
+		case allowed == "*":
+			return "*"
+		case strings.EqualFold(allowed, strings.TrimSuffix(origin, "/")):
+			return origin
+		}
+	}
+	return ""
+}
+
+// AllowHeaders returns the Access-Control-Allow-Headers header.
+func (cfg *CORSConfig) AllowHeaders() string {
+	headers := append([]string{"Content-Type", api.HeaderAPIKey}, cfg.AllowedHeaders...)
+	return strings.Join(headers, ", ")
+}
+
+// MaxAgeSeconds returns how long preflight responses may be cached.
+func (cfg *CORSConfig) MaxAgeSeconds() int64 {
+	return int64(orDefault(cfg.maxAge, defaultCORSMaxAge).Seconds())
+}
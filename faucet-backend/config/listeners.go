@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+const (
+	// RouteAPI is the public funding, status and info API.
+	RouteAPI = "api"
+	// RouteStatic is the static site in the web root.
+	RouteStatic = "static"
+	// RouteAdmin is the admin API, which manages the access lists.
+	RouteAdmin = "admin"
+	// RouteMetrics is the prometheus metrics.
+	RouteMetrics = "metrics"
+
+	// unixAddrPrefix is the prefix of Unix domain socket addresses.
+	unixAddrPrefix = "unix:"
+)
+
+// ListenerConfig is the configuration of an HTTP listener.
+type ListenerConfig struct {
+	// Addr is the TCP address, or the path of a Unix domain socket
+	// prefixed with `unix:`.
+	Addr string `toml:"addr"`
+	// Routes are the route sets served by the listener: `api`, `static`,
+	// `admin` and `metrics`.  The admin routes may not share a listener
+	// with the public ones.
+	Routes []string `toml:"routes"`
+	// Plaintext serves plain HTTP even if TLS is enabled.  Unix domain
+	// sockets are always plaintext.
+	Plaintext bool `toml:"plaintext"`
+	// TLSCertFile is the listener's own TLS certificate file (Default:
+	// the faucet's certificate, if TLS is enabled).
+	TLSCertFile string `toml:"tls_cert_file"`
+	// TLSKeyFile is the listener's own TLS certificate key file.
+	TLSKeyFile string `toml:"tls_key_file"`
+	// BearerToken is the bearer token that every request to the listener
+	// must carry.  On listeners serving the admin routes, it replaces the
+	// admin token.
+	BearerToken string `toml:"bearer_token"`
+}
+
+// IsUnix returns true iff the listener is a Unix domain socket.
+func (cfg *ListenerConfig) IsUnix() bool {
+	return strings.HasPrefix(cfg.Addr, unixAddrPrefix)
+}
+
+// Network returns the network and address to listen on.
+func (cfg *ListenerConfig) Network() (string, string) {
+	if cfg.IsUnix() {
+		return "unix", strings.TrimPrefix(cfg.Addr, unixAddrPrefix)
+	}
+	return "tcp", cfg.Addr
+}
+
+// HasRoute returns true iff the listener serves the route set.
+func (cfg *ListenerConfig) HasRoute(route string) bool {
+	for _, r := range cfg.Routes {
+		if r == route {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the listener configuration.
+func (cfg *ListenerConfig) Validate() error {
+	if cfg.Addr == "" || cfg.Addr == unixAddrPrefix {
+		return fmt.Errorf("empty address")
+	}
+	if len(cfg.Routes) == 0 {
+		return fmt.Errorf("no routes")
+	}
+	for _, route := range cfg.Routes {
+		switch route {
+		case RouteAPI, RouteStatic, RouteAdmin, RouteMetrics:
+		default:
+			return fmt.Errorf("unknown route: '%s'", route)
+		}
+	}
+	if cfg.HasRoute(RouteAdmin) && (cfg.HasRoute(RouteAPI) || cfg.HasRoute(RouteStatic)) {
+		return fmt.Errorf("the admin routes may not be served with the public routes")
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return fmt.Errorf("both the TLS certificate and key must be provided")
+	}
+	if cfg.TLSCertFile != "" && (cfg.Plaintext || cfg.IsUnix()) {
+		return fmt.Errorf("plaintext listeners can not have a TLS certificate")
+	}
+	return nil
+}
+
+// HTTPListeners returns the HTTP listeners.  Unless configured otherwise,
+// the public routes are served on the listen address, and the metrics in
+// plaintext on the metrics address.
+func (cfg *Config) HTTPListeners() []ListenerConfig {
+	if len(cfg.Listeners) > 0 {
+		return cfg.Listeners
+	}
+
+	metricsAddr := cfg.MetricsPullAddr
+	if metricsAddr == "" {
+		metricsAddr = metrics.DefaultPullAddr
+	}
+	return []ListenerConfig{
+		{
+			Addr:   cfg.ListenAddr,
+			Routes: []string{RouteAPI, RouteStatic},
+		},
+		{
+			Addr:      metricsAddr,
+			Routes:    []string{RouteMetrics},
+			Plaintext: true,
+		},
+	}
+}
+
+// PublicListenAddr returns the TCP address of the first listener serving
+// the public API over TLS, which HTTP requests are redirected to.
+func (cfg *Config) PublicListenAddr() string {
+	for _, l := range cfg.HTTPListeners() {
+		if l.HasRoute(RouteAPI) && !l.IsUnix() && !l.Plaintext {
+			return l.Addr
+		}
+	}
+	return cfg.ListenAddr
+}
+
+// validateListeners validates the listeners, and ensures that no address
+// is used twice.
+func (cfg *Config) validateListeners() error {
+	seen := make(map[string]bool)
+	addrs := []string{cfg.GRPCListenAddr, cfg.RedirectListenAddr}
+	for i := range cfg.Listeners {
+		l := &cfg.Listeners[i]
+		if err := l.Validate(); err != nil {
+			return fmt.Errorf("listener '%s': %w", l.Addr, err)
+		}
+		if l.HasRoute(RouteAdmin) && l.BearerToken == "" && cfg.Access.AdminToken == "" {
+			return fmt.Errorf("listener '%s': the admin routes require a token", l.Addr)
+		}
+		addrs = append(addrs, l.Addr)
+	}
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		if seen[addr] {
+			return fmt.Errorf("address '%s' is used more than once", addr)
+		}
+		seen[addr] = true
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// MockChainConfig is the configuration of the simulated chain that the
+// faucet runs against in the -mock-chain mode.
+type MockChainConfig struct {
+	// Balance is the faucet's balance on the consensus layer and on each
+	// paratime, in each of its denominations, in tokens (Default: 1000000).
+	Balance string `toml:"balance"`
+	// DepositDelay is the delay before paratime deposits are processed
+	// by the consensus layer (eg: "6s").
+	DepositDelay string `toml:"deposit_delay"`
+	// CheckTxFailureRate is the fraction of transactions that fail the
+	// transaction check.
+	CheckTxFailureRate float64 `toml:"check_tx_failure_rate"`
+	// TimeoutRate is the fraction of transactions that are never
+	// included in a block.
+	TimeoutRate float64 `toml:"timeout_rate"`
+	// EmptyAllowance makes the paratime allowances impossible to refill.
+	EmptyAllowance bool `toml:"empty_allowance"`
+
+	depositDelay time.Duration
+}
+
+// Validate validates the simulated chain configuration, and parses the
+// deposit delay.
+func (cfg *MockChainConfig) Validate() error {
+	if cfg.CheckTxFailureRate < 0 || cfg.TimeoutRate < 0 || cfg.CheckTxFailureRate+cfg.TimeoutRate > 1 {
+		return fmt.Errorf("failure rates must be between 0 and 1")
+	}
+	if cfg.DepositDelay != "" {
+		var err error
+		if cfg.depositDelay, err = time.ParseDuration(cfg.DepositDelay); err != nil {
+			return fmt.Errorf("malformed deposit delay: %w", err)
+		}
+		if cfg.depositDelay < 0 || cfg.depositDelay >= RequestTimeout {
+			return fmt.Errorf("deposit delay must be between 0 and %v", RequestTimeout)
+		}
+	}
+	return nil
+}
+
+// DepositDelayDuration returns the parsed deposit delay.  It is only valid
+// after the configuration has been validated.
+func (cfg *MockChainConfig) DepositDelayDuration() time.Duration {
+	return cfg.depositDelay
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const (
+	// BalancePolicyReject rejects requests from accounts whose balance is
+	// at or above the threshold.
+	BalancePolicyReject = "reject"
+	// BalancePolicyScale scales the payout of accounts whose balance is
+	// at or above the threshold by threshold/balance.
+	BalancePolicyScale = "scale"
+	// BalancePolicyTopUp only pays out enough to bring the balance up to
+	// the target.
+	BalancePolicyTopUp = "top_up"
+)
+
+// BalancePolicyConfig is the policy for funding accounts that already
+// hold plenty of tokens.
+type BalancePolicyConfig struct {
+	// Mode is the policy mode, one of `reject`, `scale` and `top_up`, or
+	// empty to fund accounts regardless of their balance.
+	Mode string `toml:"mode"`
+	// Threshold is the balance in tokens at or above which an account is
+	// considered well funded, for the `reject` and `scale` modes.
+	Threshold string `toml:"threshold"`
+	// Target is the balance in tokens that accounts are topped up to,
+	// for the `top_up` mode.
+	Target string `toml:"target"`
+}
+
+// Validate validates the balance policy.
+func (cfg *BalancePolicyConfig) Validate() error {
+	isTokens := func(s string) bool {
+		f, ok := new(big.Float).SetString(s)
+		return ok && f.Sign() > 0
+	}
+
+	switch cfg.Mode {
+	case "":
+	case BalancePolicyReject, BalancePolicyScale:
+		if !isTokens(cfg.Threshold) {
+			return fmt.Errorf("threshold must be a positive amount of tokens")
+		}
+	case BalancePolicyTopUp:
+		if !isTokens(cfg.Target) {
+			return fmt.Errorf("target must be a positive amount of tokens")
+		}
+	default:
+		return fmt.Errorf("unknown mode: '%s'", cfg.Mode)
+	}
+	return nil
+}
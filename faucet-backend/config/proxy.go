@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oasisprotocol/tools/faucet-backend/clientip"
+)
+
+// ProxyConfig is the configuration of the trusted reverse proxies and load
+// balancers in front of the faucet.
+type ProxyConfig struct {
+	// TrustedProxies are the addresses or CIDR ranges of the trusted
+	// proxies.  Client addresses are only derived from headers and PROXY
+	// protocol headers sent by trusted proxies.
+	TrustedProxies []string `toml:"trusted_proxies"`
+	// Headers are the headers that carry the client address, by order of
+	// precedence: `X-Forwarded-For`, `X-Real-IP` and `Forwarded`
+	// (Default: `X-Forwarded-For`).
+	Headers []string `toml:"headers"`
+	// TrustUnixSockets believes the client address headers of requests
+	// on Unix domain socket listeners, eg: from a local reverse proxy,
+	// which are otherwise ignored.
+	TrustUnixSockets bool `toml:"trust_unix_sockets"`
+	// ProxyProtocol enables the PROXY protocol (v1 and v2) on the HTTP and
+	// gRPC listeners.
+	ProxyProtocol bool `toml:"proxy_protocol"`
+	// HashSalt is the salt with which client addresses are hashed in the
+	// logs.  Client addresses are logged as is if empty.
+	HashSalt string `toml:"hash_salt"`
+
+	trustedProxies []*net.IPNet
+	headers        []string
+}
+
+// Validate validates the proxy configuration, and parses the trusted
+// proxies.
+func (cfg *ProxyConfig) Validate() error {
+	var err error
+	if cfg.trustedProxies, err = clientip.ParseCIDRs(cfg.TrustedProxies); err != nil {
+		return fmt.Errorf("trusted proxies: %w", err)
+	}
+
+	cfg.headers = []string{clientip.HeaderXForwardedFor}
+	if len(cfg.Headers) > 0 {
+		cfg.headers = nil
+		for _, name := range cfg.Headers {
+			header, err := clientip.CanonicalHeader(name)
+			if err != nil {
+				return err
+			}
+			cfg.headers = append(cfg.headers, header)
+		}
+	}
+	return nil
+}
+
+// NewResolver returns the client address resolver.  No proxies are
+// trusted if the configuration has not been validated.
+func (cfg *ProxyConfig) NewResolver() *clientip.Resolver {
+	return clientip.NewResolver(cfg.trustedProxies, cfg.headers, cfg.HashSalt)
+}
@@ -0,0 +1,108 @@
+package config
+
+import "fmt"
+
+// defaultMaxConcurrentCaptcha is the default maximum number of concurrent
+// CAPTCHA verifications.
+const defaultMaxConcurrentCaptcha = 16
+
+// defaultBalanceRate is the default limit on balance queries per client.
+var defaultBalanceRate = RateConfig{PerMinute: 30, Burst: 30}
+
+// RateLimitConfig is the configuration of the funding and balance
+// endpoints' rate limits.
+type RateLimitConfig struct {
+	// PerClient is the limit per client IP address.
+	PerClient RateConfig `toml:"per_client"`
+	// PerAllowlisted is the limit per allowlisted client IP address or
+	// API key (Default: the per client limit).
+	PerAllowlisted RateConfig `toml:"per_allowlisted"`
+	// Global is the limit across all clients.
+	Global RateConfig `toml:"global"`
+	// Balance is the limit on balance queries per client IP address or
+	// allowlisted API key (Default: 30 per minute, with a burst of 30).
+	Balance RateConfig `toml:"balance"`
+	// MaxConcurrentCaptcha is the maximum number of concurrent CAPTCHA
+	// verifications (Default: 16).
+	MaxConcurrentCaptcha int `toml:"max_concurrent_captcha"`
+}
+
+// Validate validates the rate limits.
+func (cfg *RateLimitConfig) Validate() error {
+	if err := cfg.PerClient.Validate(); err != nil {
+		return fmt.Errorf("per client: %w", err)
+	}
+	if err := cfg.PerAllowlisted.Validate(); err != nil {
+		return fmt.Errorf("per allowlisted: %w", err)
+	}
+	if err := cfg.Global.Validate(); err != nil {
+		return fmt.Errorf("global: %w", err)
+	}
+	if err := cfg.Balance.Validate(); err != nil {
+		return fmt.Errorf("balance: %w", err)
+	}
+	if cfg.MaxConcurrentCaptcha < 0 {
+		return fmt.Errorf("max concurrent captcha must be non-negative")
+	}
+	return nil
+}
+
+// AllowlistedRate returns the limit per allowlisted client.
+func (cfg *RateLimitConfig) AllowlistedRate() *RateConfig {
+	if !cfg.PerAllowlisted.Enabled() {
+		return &cfg.PerClient
+	}
+	return &cfg.PerAllowlisted
+}
+
+// BalanceRate returns the limit on balance queries per client.
+func (cfg *RateLimitConfig) BalanceRate() *RateConfig {
+	if !cfg.Balance.Enabled() {
+		rate := defaultBalanceRate
+		return &rate
+	}
+	return &cfg.Balance
+}
+
+// CaptchaConcurrency returns the maximum number of concurrent CAPTCHA
+// verifications.
+func (cfg *RateLimitConfig) CaptchaConcurrency() int {
+	if cfg.MaxConcurrentCaptcha == 0 {
+		return defaultMaxConcurrentCaptcha
+	}
+	return cfg.MaxConcurrentCaptcha
+}
+
+// RateConfig is a token bucket rate limit.
+type RateConfig struct {
+	// PerMinute is the sustained number of requests allowed per minute,
+	// 0 for unlimited.
+	PerMinute float64 `toml:"per_minute"`
+	// Burst is the number of requests that may be made at once
+	// (Default: 1).
+	Burst int `toml:"burst"`
+}
+
+// Validate validates the rate limit.
+func (cfg *RateConfig) Validate() error {
+	if cfg.PerMinute < 0 {
+		return fmt.Errorf("rate must be non-negative")
+	}
+	if cfg.Burst < 0 {
+		return fmt.Errorf("burst must be non-negative")
+	}
+	return nil
+}
+
+// Enabled returns true iff the rate is limited.
+func (cfg *RateConfig) Enabled() bool {
+	return cfg.PerMinute > 0
+}
+
+// BurstSize returns the number of requests that may be made at once.
+func (cfg *RateConfig) BurstSize() int {
+	if cfg.Burst == 0 {
+		return 1
+	}
+	return cfg.Burst
+}
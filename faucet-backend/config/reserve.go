@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// ReserveConfig is the reserve-aware payout policy, which shrinks the
+// per-request maximum as the faucet's reserves run low.
+type ReserveConfig struct {
+	// Tiers are the reserve tiers.  The per-request maximum is scaled by
+	// the factor of the lowest tier that the reserve is below.
+	Tiers []ReserveTierConfig `toml:"tiers"`
+	// Floor is the amount in tokens below which the per-request maximum
+	// is never scaled.
+	Floor string `toml:"floor"`
+}
+
+// ReserveTierConfig is a single reserve tier.
+type ReserveTierConfig struct {
+	// Below is the reserve in tokens below which the tier applies.  The
+	// reserve is the faucet's consensus balance for consensus requests,
+	// and the paratime allowance for paratime requests.
+	Below string `toml:"below"`
+	// Factor is the factor the per-request maximum is scaled by.
+	Factor float64 `toml:"factor"`
+}
+
+// Validate validates the reserve policy.
+func (cfg *ReserveConfig) Validate() error {
+	for _, tier := range cfg.Tiers {
+		if f, ok := new(big.Float).SetString(tier.Below); !ok || f.Sign() <= 0 {
+			return fmt.Errorf("tier threshold must be a positive amount of tokens")
+		}
+		if tier.Factor <= 0 || tier.Factor > 1 {
+			return fmt.Errorf("tier factor must be in (0, 1]")
+		}
+	}
+	if cfg.Floor != "" {
+		if f, ok := new(big.Float).SetString(cfg.Floor); !ok || f.Sign() < 0 {
+			return fmt.Errorf("floor must be an amount of tokens")
+		}
+	}
+	return nil
+}
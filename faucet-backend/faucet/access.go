@@ -0,0 +1,167 @@
+package faucet
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	// pathAdminAccess is the admin API endpoint managing the access lists.
+	pathAdminAccess = "/api/admin/access"
+
+	queryList = "list"
+	queryKey  = "key"
+)
+
+// clientInfoKey is the context key of the client info.
+type clientInfoKey struct{}
+
+// clientInfo is what is known about the client making a request.
+type clientInfo struct {
+	// IP is the client's IP address, if known.
+	IP net.IP
+	// RemoteAddr is the address of the connection's peer, which may be a
+	// proxy, or a Unix domain socket.
+	RemoteAddr string
+	// APIKey is the client's API key, if any.
+	APIKey string
+}
+
+func withClientInfo(ctx context.Context, client *clientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, client)
+}
+
+// clientInfoFrom returns the client info of the context, which is empty if
+// it is not set.
+func clientInfoFrom(ctx context.Context) *clientInfo {
+	if client, ok := ctx.Value(clientInfoKey{}).(*clientInfo); ok {
+		return client
+	}
+	return &clientInfo{}
+}
+
+// clientIP returns the IP address of the client that sent the request,
+// or nil if unknown.  The headers of requests from Unix domain sockets
+// are only believed if configured, as there is no peer address to check
+// against the trusted proxies.
+func (svc *Service) clientIP(req *http.Request) net.IP {
+	if isLocalConn(req.Context()) {
+		if !svc.cfg.Proxy.TrustUnixSockets {
+			return nil
+		}
+		return svc.clients.ResolveForwarded(req.Header)
+	}
+	return svc.clients.ResolveRequest(req)
+}
+
+// clientInfoOf returns the client info of an HTTP request, unless the
+// request's context already carries it.
+func (svc *Service) clientInfoOf(req *http.Request) *clientInfo {
+	if client, ok := req.Context().Value(clientInfoKey{}).(*clientInfo); ok {
+		return client
+	}
+	return &clientInfo{
+		IP:         svc.clientIP(req),
+		RemoteAddr: req.RemoteAddr,
+		APIKey:     req.Header.Get(api.HeaderAPIKey),
+	}
+}
+
+// grpcClientInfo returns the client info of a gRPC call.  Trusted proxies
+// may forward the client address as metadata.
+func (svc *Service) grpcClientInfo(ctx context.Context) *clientInfo {
+	var (
+		client     clientInfo
+		remoteAddr string
+		header     = make(http.Header)
+	)
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			for _, v := range values {
+				header.Add(key, v)
+			}
+		}
+	}
+	client.IP = svc.clients.Resolve(remoteAddr, header)
+	client.RemoteAddr = remoteAddr
+	client.APIKey = header.Get(api.HeaderAPIKey)
+	return &client
+}
+
+// checkAccess checks a request for the account against the access lists,
+// counting denied requests.  The returned error is suitable for displaying
+// to the user.
+func (svc *Service) checkAccess(ctx context.Context, account *types.Address) (access.Decision, error) {
+	client := clientInfoFrom(ctx)
+	decision := svc.access.Check(account, client.IP, client.APIKey)
+	if decision.Blocked {
+		svc.log.Printf("frontend: request denied: blocked %v: account: %v, client: %v", decision.Reason, account, svc.clients.Display(client.IP))
+		svc.metrics.DeniedRequests.WithLabelValues("blocked_" + string(decision.Reason)).Inc()
+		return decision, newAPIError(http.StatusForbidden, api.ErrCodeAccessDenied, "", "failed to fund account: access denied")
+	}
+	return decision, nil
+}
+
+// registerAdminHandlers registers the admin API endpoints, authenticated
+// by the bearer token, if enabled.
+func (svc *Service) registerAdminHandlers(mux *http.ServeMux, token string) {
+	if token == "" || svc.access == nil {
+		return
+	}
+	mux.Handle(pathAdminAccess, svc.withBearerToken(token, http.HandlerFunc(svc.OnAdminAccessRequest)))
+}
+
+// OnAdminAccessRequest handles the access lists admin API.  A GET returns
+// the lists, a POST of a JSON encoded entry to `?list=LIST` adds it, and a
+// DELETE of `?list=LIST&key=KEY` removes the entry for the address, CIDR
+// or API key.  The request must already be authenticated.
+func (svc *Service) OnAdminAccessRequest(w http.ResponseWriter, req *http.Request) {
+	list := access.List(req.URL.Query().Get(queryList))
+	switch req.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, svc.access.Lists())
+	case http.MethodPost:
+		var entry access.Entry
+		dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, svc.cfg.HTTP.BodyLimit()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&entry); err != nil {
+			writeError(w, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidRequest, "", "malformed entry: %v", err))
+			return
+		}
+		if err := svc.access.Add(list, &entry); err != nil {
+			writeError(w, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidRequest, "", "%v", err))
+			return
+		}
+		svc.log.Printf("frontend/admin: added %v entry: %+v", list, entry)
+		writeJSON(w, http.StatusOK, svc.access.Lists())
+	case http.MethodDelete:
+		key := req.URL.Query().Get(queryKey)
+		removed, err := svc.access.Remove(list, key)
+		switch {
+		case err != nil:
+			writeError(w, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidRequest, "", "%v", err))
+			return
+		case !removed:
+			writeError(w, newAPIError(http.StatusNotFound, api.ErrCodeNotFound, queryKey, "no such entry"))
+			return
+		}
+		svc.log.Printf("frontend/admin: removed %v entry: %v", list, key)
+		writeJSON(w, http.StatusOK, svc.access.Lists())
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeError(w, errMethodNotAllowed(req.Method))
+	}
+}
@@ -0,0 +1,166 @@
+package faucet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestAccessLists(t *testing.T) {
+	const adminToken = "admin-token"
+
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Access:                 faucetConfig.AccessConfig{AdminToken: adminToken},
+	})
+	store, err := access.NewStore(filepath.Join(t.TempDir(), "access.json"), nil)
+	if err != nil {
+		t.Fatalf("failed to create access store: %v", err)
+	}
+	svc.access = store
+	svc.captcha = testCaptchaVerifier("valid")
+	startBank(t, svc)
+
+	handler, adminHandler := svc.Handler(), svc.HandlerFor([]string{faucetConfig.RouteAdmin})
+	do := func(method, path, token, apiKey, body string, v interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if apiKey != "" {
+			req.Header.Set(api.HeaderAPIKey, apiKey)
+		}
+		switch strings.HasPrefix(path, pathPrefixAdmin) {
+		case true:
+			return serveJSON(t, adminHandler, req, v)
+		default:
+			return serveJSON(t, handler, req, v)
+		}
+	}
+
+	blocked, allowed := testAddress(t), testAddress(t)
+	expired := time.Now().Add(-time.Minute)
+	for _, tc := range []struct {
+		list  access.List
+		entry string
+	}{
+		{access.Blocked, `{"address":"` + blocked.String() + `"}`},
+		{access.Allowed, `{"api_key":"trusted"}`},
+		{access.Allowed, `{"api_key":"stale","expires":"` + expired.Format(time.RFC3339) + `"}`},
+	} {
+		if w := do(http.MethodPost, pathAdminAccess+"?list="+string(tc.list), adminToken, "", tc.entry, nil); w.Code != http.StatusOK {
+			t.Fatalf("admin: failed to add entry %s: %d: %s", tc.entry, w.Code, w.Body)
+		}
+	}
+	var lists access.Lists
+	if w := do(http.MethodGet, pathAdminAccess, "bogus", "", "", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("admin: unexpected status code for bad token: %d", w.Code)
+	}
+	if w := do(http.MethodGet, pathAdminAccess, adminToken, "", "", &lists); w.Code != http.StatusOK || len(lists.Blocked) != 1 || len(lists.Allowed) != 1 {
+		t.Fatalf("admin: unexpected lists %d: %+v", w.Code, lists)
+	}
+
+	fundPath := func(account staking.Address) string {
+		return api.PathFundV1 + "?" + queryAccount + "=" + account.String() + "&" + queryAmount + "=1"
+	}
+	for _, tc := range []struct {
+		name    string
+		account staking.Address
+		apiKey  string
+		status  int
+		code    string
+	}{
+		{"Blocked", blocked, "trusted", http.StatusForbidden, api.ErrCodeAccessDenied},
+		{"NoCaptcha", allowed, "", http.StatusForbidden, api.ErrCodeCaptchaFailed},
+		{"ExpiredKey", allowed, "stale", http.StatusForbidden, api.ErrCodeCaptchaFailed},
+		{"AllowedKey", allowed, "trusted", http.StatusOK, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp api.FundResponse
+			w := do(http.MethodPost, fundPath(tc.account), "", tc.apiKey, "", &resp)
+			if tc.status == http.StatusOK {
+				if w.Code != http.StatusOK {
+					t.Fatalf("fund: unexpected response %d: %+v", w.Code, resp.Error)
+				}
+				waitForRequest(t, svc, resp.RequestID)
+				return
+			}
+			if w.Code != tc.status || resp.Error == nil || resp.Error.Code != tc.code {
+				t.Fatalf("fund: unexpected response %d: %+v", w.Code, resp.Error)
+			}
+		})
+	}
+	if n := testutil.ToFloat64(svc.metrics.DeniedRequests.WithLabelValues("blocked_address")); n != 1 {
+		t.Errorf("denied requests: got %v, expected 1", n)
+	}
+
+	// Removing the entry unblocks the address.
+	if w := do(http.MethodDelete, pathAdminAccess+"?list=blocked&key="+blocked.String(), adminToken, "", "", nil); w.Code != http.StatusOK {
+		t.Fatalf("admin: failed to remove entry: %d", w.Code)
+	}
+	if w := do(http.MethodDelete, pathAdminAccess+"?list=blocked&key="+blocked.String(), adminToken, "", "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("admin: unexpected status code removing a missing entry: %d", w.Code)
+	}
+	var resp api.FundResponse
+	if w := do(http.MethodPost, fundPath(blocked), "", "trusted", "", &resp); w.Code != http.StatusOK {
+		t.Fatalf("fund: unexpected response after unblocking %d: %+v", w.Code, resp.Error)
+	}
+	waitForRequest(t, svc, resp.RequestID)
+}
+
+func TestClientIP(t *testing.T) {
+	// The resolution itself is covered by the clientip package, this only
+	// checks that the service uses the configured proxies and headers.
+	cfg := &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Proxy: faucetConfig.ProxyConfig{
+			TrustedProxies: []string{"10.0.0.0/8"},
+			Headers:        []string{"x-real-ip"},
+			HashSalt:       "salt",
+		},
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		t.Fatalf("failed to validate proxy configuration: %v", err)
+	}
+	svc, _ := newTestService(t, cfg)
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expected   string
+	}{
+		{"UntrustedPeer", "192.0.2.1:1234", http.Header{"X-Real-Ip": {"198.51.100.2"}}, "192.0.2.1"},
+		{"XRealIP", "10.0.0.1:1234", http.Header{"X-Real-Ip": {"198.51.100.2"}}, "198.51.100.2"},
+		{"UnconfiguredHeader", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"198.51.100.1"}}, "10.0.0.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for k, vs := range tc.header {
+				req.Header[k] = vs
+			}
+			if ip := svc.clientIP(req); ip.String() != tc.expected {
+				t.Fatalf("client IP: got %v, expected %v", ip, tc.expected)
+			}
+		})
+	}
+
+	if s := svc.clients.Display(net.ParseIP("192.0.2.1")); s == "192.0.2.1" {
+		t.Errorf("display: got %v, expected a hash", s)
+	}
+}
@@ -0,0 +1,175 @@
+package faucet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+
+	"github.com/oasisprotocol/oasis-core/go/common/encoding/bech32"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/rewards"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+const (
+	// evmDecimals is the number of decimals of the native denomination
+	// of EVM compatible paratimes.
+	evmDecimals = 18
+
+	ethAddressPrefix = "0x"
+	ethAddressLen    = 2 * len(ethCommon.Address{})
+)
+
+// reservedAddresses returns the addresses that must never be funded, with
+// a description of each: the faucet's own address, the consensus layer's
+// reserved addresses, the paratimes' runtime addresses, and the paratime
+// module addresses.
+func reservedAddresses(network *config.Network, faucet staking.Address) map[types.Address]string {
+	reserved := map[types.Address]string{
+		types.NewAddressFromConsensus(faucet):                            "the faucet's own address",
+		types.NewAddressFromConsensus(staking.CommonPoolAddress):         "the common pool address",
+		types.NewAddressFromConsensus(staking.FeeAccumulatorAddress):     "the fee accumulator address",
+		types.NewAddressFromConsensus(staking.GovernanceDepositsAddress): "the governance deposits address",
+		types.NewAddressFromConsensus(staking.BurnAddress):               "the burn address",
+		accounts.CommonPoolAddress:                                       "a module address",
+		accounts.FeeAccumulatorAddress:                                   "a module address",
+		consensusaccounts.PendingWithdrawalAddress:                       "a module address",
+		consensusaccounts.PendingDelegationAddress:                       "a module address",
+		rewards.RewardPoolAddress:                                        "a module address",
+	}
+	for name, pt := range network.ParaTimes.All {
+		reserved[types.NewAddressFromConsensus(staking.NewRuntimeAddress(pt.Namespace()))] = fmt.Sprintf("the %s runtime address", name)
+	}
+	return reserved
+}
+
+// defaultAddressKinds are the kinds of addresses that may be funded on the
+// well known paratimes, unless configured otherwise.
+var defaultAddressKinds = faucetConfig.AddressKindsConfig{
+	"emerald":  {faucetConfig.AddressKindEth},
+	"cipher":   {faucetConfig.AddressKindOasis},
+	"sapphire": {faucetConfig.AddressKindOasis, faucetConfig.AddressKindEth},
+}
+
+// addressKinds returns whether Oasis and Ethereum addresses may be funded
+// on the consensus layer (nil paratime) or the paratime.  Unless configured
+// otherwise, the well known paratimes use their default kinds, and other
+// paratimes accept Ethereum addresses if their consensus denomination has
+// the EVM's 18 decimals, and Oasis addresses otherwise.
+func (svc *Service) addressKinds(pt *config.ParaTime) (bool, bool) {
+	if pt == nil {
+		return true, false
+	}
+
+	name := svc.paratimeName(pt.ID)
+	kinds, ok := svc.cfg.AddressKinds[name]
+	if !ok {
+		kinds, ok = defaultAddressKinds[name]
+	}
+	if ok {
+		var oasis, eth bool
+		for _, kind := range kinds {
+			switch kind {
+			case faucetConfig.AddressKindOasis:
+				oasis = true
+			case faucetConfig.AddressKindEth:
+				eth = true
+			}
+		}
+		return oasis, eth
+	}
+
+	denom := pt.Denominations[pt.ConsensusDenomination]
+	if denom == nil {
+		denom = pt.Denominations[config.NativeDenominationKey]
+	}
+	isEVM := denom != nil && denom.Decimals == evmDecimals
+	return !isEVM, isEVM
+}
+
+// parseEthAddress parses a hex encoded Ethereum address, verifying the
+// EIP-55 checksum if the address is mixed case.
+func parseEthAddress(addrStr string) (*types.Address, *ethCommon.Address, error) {
+	hexStr := addrStr[len(ethAddressPrefix):]
+	if len(hexStr) != ethAddressLen {
+		return nil, nil, fmt.Errorf("malformed Ethereum address: expected %d hex digits", ethAddressLen)
+	}
+	if _, err := hex.DecodeString(hexStr); err != nil {
+		return nil, nil, fmt.Errorf("malformed Ethereum address: not hex")
+	}
+
+	ethAddr := ethCommon.HexToAddress(addrStr)
+	isMixedCase := hexStr != strings.ToLower(hexStr) && hexStr != strings.ToUpper(hexStr)
+	if isMixedCase && ethAddr.Hex()[len(ethAddressPrefix):] != hexStr {
+		return nil, nil, fmt.Errorf("malformed Ethereum address: invalid checksum")
+	}
+	if ethAddr == (ethCommon.Address{}) {
+		return nil, nil, fmt.Errorf("the zero address")
+	}
+
+	addr := types.NewAddressRaw(types.AddressV0Secp256k1EthContext, ethAddr[:])
+	return &addr, &ethAddr, nil
+}
+
+// parseOasisAddress parses a Bech32 encoded Oasis address.
+func parseOasisAddress(addrStr string) (*types.Address, error) {
+	hrp, _, err := bech32.Decode(addrStr)
+	if err != nil {
+		return nil, fmt.Errorf("malformed address")
+	}
+	if hrp != staking.AddressBech32HRP.String() {
+		return nil, fmt.Errorf("malformed address: unexpected prefix '%s'", hrp)
+	}
+
+	var addr types.Address
+	if err = addr.UnmarshalText([]byte(addrStr)); err != nil {
+		return nil, fmt.Errorf("malformed address: %w", err)
+	}
+	return &addr, nil
+}
+
+// parseAddress validates the user supplied account address for the
+// consensus layer (nil paratime) or the paratime.  The returned error is
+// suitable for displaying to the user.
+func (svc *Service) parseAddress(pt *config.ParaTime, addrStr string) (*types.Address, *ethCommon.Address, error) {
+	layer := "the consensus layer"
+	if pt != nil {
+		layer = svc.paratimeName(pt.ID)
+	}
+	allowOasis, allowEth := svc.addressKinds(pt)
+
+	var (
+		addr    *types.Address
+		ethAddr *ethCommon.Address
+		err     error
+	)
+	switch {
+	case strings.HasPrefix(strings.ToLower(addrStr), ethAddressPrefix):
+		if !allowEth {
+			return nil, nil, fmt.Errorf("Ethereum addresses can not be funded on %s", layer)
+		}
+		if addr, ethAddr, err = parseEthAddress(addrStr); err != nil {
+			return nil, nil, err
+		}
+	default:
+		if !allowOasis {
+			return nil, nil, fmt.Errorf("Oasis addresses can not be funded on %s", layer)
+		}
+		if addr, err = parseOasisAddress(addrStr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if what, ok := svc.reservedAddresses[*addr]; ok {
+		return nil, nil, fmt.Errorf("%s can not be funded", what)
+	}
+	return addr, ethAddr, nil
+}
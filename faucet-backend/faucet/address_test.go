@@ -0,0 +1,43 @@
+package faucet
+
+import (
+	"strings"
+	"testing"
+
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestParseAddress(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		AddressKinds: faucetConfig.AddressKindsConfig{
+			"cipher": {faucetConfig.AddressKindOasis, faucetConfig.AddressKindEth},
+		},
+	})
+
+	to := testAddress(t)
+	for _, tc := range []struct {
+		name     string
+		paraTime string
+		account  string
+		valid    bool
+	}{
+		{"Consensus", "", to.String(), true},
+		{"EthConsensus", "", testAccountSapphire, false},
+		{"EthLowerCase", "sapphire", strings.ToLower(testAccountSapphire), true},
+		{"OasisSapphire", "sapphire", to.String(), true},
+		{"EthUnlisted", "pontusx", testAccountSapphire, true},
+		{"OasisUnlisted", "pontusx", to.String(), false},
+		{"OasisEmerald", "emerald", to.String(), false},
+		{"EthEmerald", "emerald", testAccountSapphire, true},
+		{"OasisCipher", "cipher", to.String(), true},
+		{"EthCipher", "cipher", testAccountSapphire, true},
+		{"EthZero", "sapphire", "0x0000000000000000000000000000000000000000", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, _, err := svc.parseAccount(tc.paraTime, tc.account)
+			if valid := err == nil; valid != tc.valid {
+				t.Fatalf("parseAccount: got valid %v (%v), expected %v", valid, err, tc.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package faucet
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+// checkMinAmount ensures that the amount to be funded is not below the
+// configured minimum.  The returned error is suitable for displaying to
+// the user.
+func (svc *Service) checkMinAmount(paraTimeStr string, req *FundRequest) error {
+	minStr := svc.cfg.Amounts.ForParaTime(paraTimeStr).Min
+	if minStr == "" {
+		return nil
+	}
+
+	min, err := svc.parseTokens(req.ParaTime, minStr)
+	if err != nil {
+		svc.log.Printf("frontend: invalid minimum amount '%v': %v", minStr, err)
+		return fmt.Errorf("failed to fund account: minimum amount misconfigured")
+	}
+	if req.amount().Cmp(min) < 0 {
+		return newAPIError(
+			http.StatusBadRequest,
+			api.ErrCodeAmountTooSmall,
+			queryAmount,
+			"failed to fund account: amount below the minimum of %s", svc.formatBalance(req.ParaTime, min),
+		)
+	}
+	return nil
+}
+
+// validateAmounts ensures that the default and minimum amounts are only
+// configured for the network's paratimes, and do not exceed the
+// per-request maximum.
+func (svc *Service) validateAmounts() error {
+	if err := svc.validateAmount(nil, &svc.cfg.Amounts.Consensus); err != nil {
+		return fmt.Errorf("consensus: %w", err)
+	}
+	for name, amounts := range svc.cfg.Amounts.ParaTimes {
+		if amounts == nil {
+			continue
+		}
+		pt := svc.network.ParaTimes.All[name]
+		if pt == nil {
+			return fmt.Errorf("unknown paratime: '%s'", name)
+		}
+		if err := svc.validateAmount(pt, amounts); err != nil {
+			return fmt.Errorf("paratime '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// validateAmount ensures that the default and minimum amounts of the
+// consensus layer (nil paratime) or the paratime can be represented, and
+// do not exceed the per-request maximum.
+func (svc *Service) validateAmount(pt *config.ParaTime, amounts *faucetConfig.AmountConfig) error {
+	max, err := svc.maxAmount(pt)
+	if err != nil {
+		return fmt.Errorf("invalid maximum amount: %w", err)
+	}
+	for _, tokens := range []string{amounts.Default, amounts.Min} {
+		if tokens == "" {
+			continue
+		}
+		amount, err := svc.parseTokens(pt, tokens)
+		if err != nil {
+			return fmt.Errorf("invalid amount: '%s'", tokens)
+		}
+		if max != nil && amount.Cmp(max) > 0 {
+			return fmt.Errorf("amount above the maximum: '%s'", tokens)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package faucet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+func TestFundDefaultAmount(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		Amounts: faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Default: "10", Min: "1"},
+			ParaTimes: map[string]*faucetConfig.AmountConfig{
+				"sapphire": {Default: "2.5", Min: "0.5"},
+			},
+		},
+	}
+	svc, _ := newTestService(t, cfg)
+	startBank(t, svc)
+
+	for _, tc := range []struct {
+		paraTime string
+		account  string
+		expected string
+	}{
+		{"", testAddress(t).String(), "10.0 TEST"},
+		{"sapphire", testAccountSapphire, "2.5 TEST"},
+	} {
+		code, resp := fund(t, svc, tc.paraTime, tc.account, "")
+		if code != http.StatusOK || resp.Amount != tc.expected {
+			t.Fatalf("default amount (%q): unexpected response %d: %+v", tc.paraTime, code, resp)
+		}
+		if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+			t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+		}
+	}
+
+	if code, resp := fund(t, svc, "", testAddress(t).String(), "0.5"); code != http.StatusBadRequest {
+		t.Fatalf("below minimum: unexpected response %d: %+v", code, resp)
+	}
+	if code, resp := fund(t, svc, "sapphire", testAccountSapphire, "0.1"); code != http.StatusBadRequest {
+		t.Fatalf("below paratime minimum: unexpected response %d: %+v", code, resp)
+	}
+}
+
+func TestAmountValidation(t *testing.T) {
+	signer := testSigner(t)
+	network := config.DefaultNetworks.All["testnet"]
+
+	for _, tc := range []struct {
+		name    string
+		amounts faucetConfig.AmountsConfig
+		valid   bool
+	}{
+		{"AtMaximum", faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Default: "100", Min: "1"},
+			ParaTimes: map[string]*faucetConfig.AmountConfig{"sapphire": {Default: "10"}},
+		}, true},
+		{"ConsensusDefaultAboveMaximum", faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Default: "101"},
+		}, false},
+		{"ConsensusMinAboveMaximum", faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Min: "101"},
+		}, false},
+		{"UnknownParaTime", faucetConfig.AmountsConfig{
+			ParaTimes: map[string]*faucetConfig.AmountConfig{"nonexistent": {Default: "1"}},
+		}, false},
+	} {
+		cfg := &faucetConfig.Config{
+			MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+			MaxParatimeFundAmount:  "10",
+			Amounts:                tc.amounts,
+		}
+		_, err := New(cfg, network, signer, WithMetrics(metrics.New(prometheus.NewRegistry())))
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%s: got error %v, expected valid: %v", tc.name, err, tc.valid)
+		}
+	}
+}
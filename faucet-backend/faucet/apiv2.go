@@ -0,0 +1,243 @@
+package faucet
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	mediaTypeJSON        = "application/json"
+	mediaTypeEventStream = "text/event-stream"
+)
+
+// openAPISpec is the OpenAPI specification of the v2 API.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// accepts returns true iff the client accepts the media type, based on the
+// request's Accept header.
+func accepts(req *http.Request, mediaType string) bool {
+	accept := req.Header.Get("Accept")
+	if accept == "" {
+		return true
+	}
+
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	for _, v := range strings.Split(accept, ",") {
+		t, _, err := mime.ParseMediaType(strings.TrimSpace(v))
+		if err != nil {
+			continue
+		}
+		if t == mediaType || t == "*/*" || t == mainType+"/*" {
+			return true
+		}
+	}
+	return false
+}
+
+// v2Handler wraps a v2 API handler, enforcing the HTTP method, and that the
+// client accepts one of the media types the handler produces.
+func v2Handler(method string, h http.HandlerFunc, mediaTypes ...string) http.HandlerFunc {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{mediaTypeJSON}
+	}
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, errMethodNotAllowed(req.Method))
+			return
+		}
+		for _, mediaType := range mediaTypes {
+			if accepts(req, mediaType) {
+				h(w, req)
+				return
+			}
+		}
+		writeError(w, newAPIError(
+			http.StatusNotAcceptable,
+			api.ErrCodeNotAcceptable,
+			"",
+			"unsupported response media types: '%v'", req.Header.Get("Accept"),
+		))
+	}
+}
+
+// registerV2Handlers registers the v2 API endpoints.
+func (svc *Service) registerV2Handlers(mux *http.ServeMux) {
+	mux.HandleFunc(api.PathFundV2, v2Handler(http.MethodPost, svc.rateLimited(svc.OnFundRequestV2)))
+	mux.HandleFunc(api.PathBundleV2, v2Handler(http.MethodPost, svc.rateLimited(svc.OnBundleRequestV2)))
+	mux.HandleFunc(api.PathStatusV2+"{id}", v2Handler(http.MethodGet, svc.OnStatusRequestV2, mediaTypeJSON, mediaTypeEventStream))
+	mux.HandleFunc(api.PathBalanceV2, v2Handler(http.MethodGet, svc.OnBalanceRequest))
+	mux.HandleFunc(api.PathInfoV2, v2Handler(http.MethodGet, svc.OnInfoRequest))
+	mux.HandleFunc(api.PathOpenAPIV2, v2Handler(http.MethodGet, onOpenAPIRequest))
+}
+
+// decodeV2Body decodes the JSON encoded body of a v2 API request into v.
+// The returned error is suitable for displaying to the user.
+func (svc *Service) decodeV2Body(w http.ResponseWriter, req *http.Request, v interface{}) error {
+	if mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type")); err != nil || mediaType != mediaTypeJSON {
+		return newAPIError(
+			http.StatusUnsupportedMediaType,
+			api.ErrCodeUnsupportedMedia,
+			"",
+			"unsupported request media type: '%v'", req.Header.Get("Content-Type"),
+		)
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, req.Body, svc.cfg.HTTP.BodyLimit()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		svc.log.Printf("frontend/v2: invalid http request: %v", err)
+		return newAPIError(
+			http.StatusBadRequest,
+			api.ErrCodeInvalidRequest,
+			"",
+			"invalid http request, failed to parse body: %v", err,
+		)
+	}
+	return nil
+}
+
+// OnFundRequestV2 handles a funding request.  The expected request is a
+// POST to `https://host:port/api/v2/fund` with a JSON encoded FundParams body.
+func (svc *Service) OnFundRequestV2(w http.ResponseWriter, req *http.Request) {
+	var params api.FundParams
+	if err := svc.decodeV2Body(w, req, &params); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp, err := svc.SubmitFundRequest(withClientInfo(req.Context(), svc.clientInfoOf(req)), &params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// OnBundleRequestV2 handles a bundle request.  The expected request is a
+// POST to `https://host:port/api/v2/bundle` with a JSON encoded
+// BundleParams body.
+func (svc *Service) OnBundleRequestV2(w http.ResponseWriter, req *http.Request) {
+	var params api.BundleParams
+	if err := svc.decodeV2Body(w, req, &params); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	resp, err := svc.SubmitBundleRequest(withClientInfo(req.Context(), svc.clientInfoOf(req)), &params)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// OnStatusRequestV2 handles a request status query.  The expected request
+// is a GET of the form `https://host:port/api/v2/status/REQUEST_ID`.  If
+// the client accepts `text/event-stream`, but not JSON, the progress is
+// streamed as Server-Sent Events.
+func (svc *Service) OnStatusRequestV2(w http.ResponseWriter, req *http.Request) {
+	if !accepts(req, mediaTypeJSON) {
+		svc.OnStatusStream(w, req)
+		return
+	}
+	svc.OnStatusRequest(w, req)
+}
+
+// Info returns the faucet's funding information.  The returned error is
+// suitable for displaying to the user.
+func (svc *Service) Info(ctx context.Context) (*api.InfoResponse, error) {
+	fundingInfoOf := func(name string, pt *config.ParaTime) (*api.FundingInfo, error) {
+		denomination := svc.network.Denomination
+		if pt != nil {
+			denomination = *pt.Denominations[config.NativeDenominationKey]
+		}
+		info := &api.FundingInfo{
+			Symbol:   denomination.Symbol,
+			Decimals: denomination.Decimals,
+		}
+
+		if minStr := svc.cfg.Amounts.ForParaTime(name).Min; minStr != "" {
+			min, err := svc.parseTokens(pt, minStr)
+			if err != nil {
+				return nil, err
+			}
+			info.MinAmount = svc.formatBalance(pt, min)
+		}
+
+		// The default and maximum amounts are reported as reduced by the
+		// reserve policy.
+		max, factor, err := svc.EffectiveMaxAmount(ctx, pt)
+		if err != nil {
+			return nil, err
+		}
+		if max != nil {
+			info.MaxAmount = svc.formatBalance(pt, max)
+		}
+		info.PayoutFactor = factor
+
+		def, err := svc.effectiveDefaultAmount(pt, max, factor)
+		if err != nil {
+			return nil, err
+		}
+		if def != nil {
+			info.DefaultAmount = svc.formatBalance(pt, def)
+		}
+
+		return info, nil
+	}
+
+	resp := &api.InfoResponse{
+		ChainContext:    svc.network.ChainContext,
+		Address:         svc.address.String(),
+		DryRun:          svc.cfg.DryRun,
+		CaptchaRequired: svc.captcha != nil,
+		ParaTimes:       make(map[string]*api.FundingInfo),
+	}
+	var err error
+	if resp.Consensus, err = fundingInfoOf("", nil); err != nil {
+		svc.log.Printf("frontend: failed to determine consensus funding info: %v", err)
+		return nil, errTemporaryFailure()
+	}
+	for name, pt := range svc.network.ParaTimes.All {
+		if resp.ParaTimes[name], err = fundingInfoOf(name, pt); err != nil {
+			svc.log.Printf("frontend: failed to determine '%v' funding info: %v", name, err)
+			return nil, errTemporaryFailure()
+		}
+	}
+	for name := range svc.cfg.Bundles {
+		resp.Bundles = append(resp.Bundles, name)
+	}
+	sort.Strings(resp.Bundles)
+
+	return resp, nil
+}
+
+// OnInfoRequest returns the faucet's funding information.  The expected
+// request is a GET to `https://host:port/api/v2/info`.
+func (svc *Service) OnInfoRequest(w http.ResponseWriter, req *http.Request) {
+	resp, err := svc.Info(req.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// onOpenAPIRequest serves the OpenAPI specification of the v2 API.
+func onOpenAPIRequest(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", mediaTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(openAPISpec)
+}
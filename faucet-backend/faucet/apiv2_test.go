@@ -0,0 +1,107 @@
+package faucet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestAPIV2(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Amounts: faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Default: "10"},
+		},
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{Amount: "1"},
+				},
+			},
+		},
+	})
+	startBank(t, svc)
+
+	mux := http.NewServeMux()
+	svc.registerV2Handlers(mux)
+	do := func(method, path, contentType, accept, body string, v interface{}) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return serveJSON(t, mux, req, v)
+	}
+
+	var resp api.FundResponse
+	w := do(http.MethodPost, "/api/v2/fund", "application/json", "", `{"account":"`+testAddress(t).String()+`"}`, &resp)
+	if w.Code != http.StatusOK || resp.RequestID == "" || resp.Amount != "10.0 TEST" {
+		t.Fatalf("fund: unexpected response %d: %+v", w.Code, resp)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	resp = api.FundResponse{}
+	w = do(http.MethodPost, "/api/v2/bundle", "application/json", "", `{"bundle":"starter","account":"`+testAddress(t).String()+`"}`, &resp)
+	if w.Code != http.StatusOK || resp.RequestID == "" {
+		t.Fatalf("bundle: unexpected response %d: %+v", w.Code, resp)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("bundle request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	var status api.RequestStatus
+	if w = do(http.MethodGet, "/api/v2/status/"+resp.RequestID, "", "application/json", "", &status); w.Code != http.StatusOK || status.State != api.RequestConfirmed {
+		t.Fatalf("status: unexpected response %d: %+v", w.Code, status)
+	}
+
+	var info api.InfoResponse
+	if w = do(http.MethodGet, "/api/v2/info", "", "", "", &info); w.Code != http.StatusOK {
+		t.Fatalf("info: unexpected status code %d", w.Code)
+	}
+	if info.Consensus.DefaultAmount != "10.0 TEST" || info.Consensus.MaxAmount != "100.0 TEST" || info.ParaTimes["sapphire"] == nil {
+		t.Errorf("info: unexpected response: %+v", info)
+	}
+
+	var spec map[string]interface{}
+	if w = do(http.MethodGet, "/api/v2/openapi.json", "", "", "", &spec); w.Code != http.StatusOK || spec["openapi"] == nil {
+		t.Errorf("openapi: unexpected response %d", w.Code)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		accept      string
+		body        string
+		status      int
+		code        string
+	}{
+		{"FormBody", http.MethodPost, "/api/v2/fund", "application/x-www-form-urlencoded", "", "account=foo", http.StatusUnsupportedMediaType, api.ErrCodeUnsupportedMedia},
+		{"UnknownField", http.MethodPost, "/api/v2/fund", "application/json", "", `{"acount":"foo"}`, http.StatusBadRequest, api.ErrCodeInvalidRequest},
+		{"InvalidAccount", http.MethodPost, "/api/v2/fund", "application/json", "", `{"account":"foo"}`, http.StatusBadRequest, api.ErrCodeInvalidAccount},
+		{"UnknownBundle", http.MethodPost, "/api/v2/bundle", "application/json", "", `{"bundle":"bogus","account":"` + testAddress(t).String() + `"}`, http.StatusBadRequest, api.ErrCodeInvalidBundle},
+		{"BundleFormBody", http.MethodPost, "/api/v2/bundle", "application/x-www-form-urlencoded", "", "bundle=starter", http.StatusUnsupportedMediaType, api.ErrCodeUnsupportedMedia},
+		{"WrongMethod", http.MethodGet, "/api/v2/fund", "", "", "", http.StatusMethodNotAllowed, api.ErrCodeMethodNotAllowed},
+		{"NotAcceptable", http.MethodGet, "/api/v2/info", "", "text/html", "", http.StatusNotAcceptable, api.ErrCodeNotAcceptable},
+		{"UnknownRequest", http.MethodGet, "/api/v2/status/bogus", "", "", "", http.StatusNotFound, api.ErrCodeNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var resp api.FundResponse
+			w := do(tc.method, tc.path, tc.contentType, tc.accept, tc.body, &resp)
+			if w.Code != tc.status || resp.Error == nil || resp.Error.Code != tc.code {
+				t.Fatalf("unexpected response %d: %+v", w.Code, resp.Error)
+			}
+		})
+	}
+}
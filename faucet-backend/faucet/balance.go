@@ -0,0 +1,164 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	// balanceCacheTTL is how long queried balances are cached for.
+	balanceCacheTTL = 10 * time.Second
+	// maxBalanceCacheEntries is the number of cached balances above which
+	// expired entries are pruned.
+	maxBalanceCacheEntries = 10_000
+)
+
+type balanceCacheEntry struct {
+	balance quantity.Quantity
+	expires time.Time
+}
+
+// balanceCache is a short-lived cache of account balances.
+type balanceCache struct {
+	lock    sync.Mutex
+	entries map[string]*balanceCacheEntry
+}
+
+func newBalanceCache() *balanceCache {
+	return &balanceCache{
+		entries: make(map[string]*balanceCacheEntry),
+	}
+}
+
+func balanceCacheKey(pt *config.ParaTime, addr *types.Address) string {
+	if pt == nil {
+		return addr.String()
+	}
+	return pt.ID + "/" + addr.String()
+}
+
+func (c *balanceCache) get(key string) (*quantity.Quantity, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry := c.entries[key]
+	if entry == nil || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.balance.Clone(), true
+}
+
+func (c *balanceCache) put(key string, balance *quantity.Quantity) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxBalanceCacheEntries {
+		for k, v := range c.entries {
+			if now.After(v.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	c.entries[key] = &balanceCacheEntry{
+		balance: *balance.Clone(),
+		expires: now.Add(balanceCacheTTL),
+	}
+}
+
+func (c *balanceCache) invalidate(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.entries, key)
+}
+
+// QueryBalance returns the native denomination balance of a consensus
+// (nil paratime) or paratime account in base units.  Balances are cached
+// for balanceCacheTTL.
+func (svc *Service) QueryBalance(ctx context.Context, pt *config.ParaTime, addr *types.Address) (*quantity.Quantity, error) {
+	key := balanceCacheKey(pt, addr)
+	if balance, ok := svc.balances.get(key); ok {
+		return balance, nil
+	}
+
+	var balance *quantity.Quantity
+	switch pt {
+	case nil:
+		account, err := svc.chain.ConsensusAccount(ctx, addr.ConsensusAddress())
+		if err != nil {
+			return nil, err
+		}
+		balance = &account.General.Balance
+	default:
+		var err error
+		if balance, err = svc.chain.RuntimeAccountBalance(ctx, pt, *addr); err != nil {
+			return nil, err
+		}
+	}
+	svc.balances.put(key, balance)
+
+	return balance, nil
+}
+
+// formatBalance formats a consensus (nil paratime) or paratime balance.
+func (svc *Service) formatBalance(pt *config.ParaTime, balance *quantity.Quantity) string {
+	if pt == nil {
+		return helpers.FormatConsensusDenomination(svc.network, *balance)
+	}
+	return helpers.FormatParaTimeDenomination(pt, types.NewBaseUnits(*balance, types.NativeDenomination))
+}
+
+// OnBalanceRequest handles an account balance query.  The expected request
+// is a GET of the form
+// `https://host:port/api/v1/balance?account=ACCOUNT&paratime=PARATIME`.
+func (svc *Service) OnBalanceRequest(w http.ResponseWriter, req *http.Request) {
+	client := svc.clientInfoOf(req)
+	key, _ := svc.rateLimitKey(client)
+	if ok, retryAfter := svc.balanceLimiter.Allow(key); !ok {
+		svc.log.Printf("frontend/balance: request rate limited: client: %v", svc.clients.Display(client.IP))
+		svc.metrics.RateLimitedRequests.WithLabelValues(rateLimitBalance).Inc()
+		err := newAPIError(http.StatusTooManyRequests, api.ErrCodeRateLimited, "", "too many balance queries, try again later")
+		err.RetryAfter = retryAfter
+		writeError(w, err)
+		return
+	}
+
+	query := req.URL.Query()
+	paraTimeStr := strings.TrimSpace(query.Get(queryParaTime))
+	accountStr := strings.TrimSpace(query.Get(queryAccount))
+
+	pt, account, _, err := svc.parseAccount(paraTimeStr, accountStr)
+	if err != nil {
+		writeError(w, fmt.Errorf("failed to query balance: %w", err))
+		return
+	}
+
+	balance, err := svc.QueryBalance(req.Context(), pt, account)
+	if err != nil {
+		svc.log.Printf("frontend/balance: failed to query balance of '%v': %v", accountStr, err)
+		err := errTemporaryFailure()
+		err.Message = "failed to query balance"
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &api.BalanceResponse{
+		ParaTime:  paraTimeStr,
+		Account:   accountStr,
+		Balance:   svc.formatBalance(pt, balance),
+		BaseUnits: *balance,
+	})
+}
@@ -0,0 +1,83 @@
+package faucet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestBalance(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+	}
+	svc, _ := newTestService(t, cfg)
+	startBank(t, svc)
+
+	to := testAddress(t)
+	for _, tc := range []struct {
+		paraTime string
+		account  string
+		balance  string
+	}{
+		{"", to.String(), "10.0 TEST"},
+		{"sapphire", testAccountSapphire, "10.0 TEST"},
+	} {
+		code, resp := queryBalance(t, svc, tc.paraTime, tc.account)
+		if code != http.StatusOK || resp.Balance != "0.0 TEST" {
+			t.Fatalf("balance before funding: unexpected response %d: %+v", code, resp)
+		}
+
+		code, fundResp := fund(t, svc, tc.paraTime, tc.account, "10")
+		if code != http.StatusOK {
+			t.Fatalf("fund: unexpected status code %d: %s", code, fundResp.Result)
+		}
+		waitForRequest(t, svc, fundResp.RequestID)
+
+		// The cached balance is invalidated by the payout.
+		code, resp = queryBalance(t, svc, tc.paraTime, tc.account)
+		if code != http.StatusOK || resp.Balance != tc.balance {
+			t.Fatalf("balance after funding: unexpected response %d: %+v", code, resp)
+		}
+	}
+
+	if code, _ := queryBalance(t, svc, "sapphire", "oasis1bogus"); code != http.StatusBadRequest {
+		t.Errorf("invalid account: unexpected status code %d", code)
+	}
+}
+
+func TestBalanceRateLimit(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rate  faucetConfig.RateConfig
+		burst int
+	}{
+		{"Default", faucetConfig.RateConfig{}, 30},
+		{"Configured", faucetConfig.RateConfig{PerMinute: 1, Burst: 3}, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &faucetConfig.Config{
+				RateLimits: faucetConfig.RateLimitConfig{
+					Balance: tc.rate,
+				},
+			}
+			svc, _ := newTestService(t, cfg)
+			startBank(t, svc)
+
+			to := testAddress(t)
+			for i := 0; i < tc.burst; i++ {
+				if code, _ := queryBalance(t, svc, "", to.String()); code != http.StatusOK {
+					t.Fatalf("query %d: unexpected status code %d", i, code)
+				}
+			}
+			if code, _ := queryBalance(t, svc, "", to.String()); code != http.StatusTooManyRequests {
+				t.Fatalf("query over limit: unexpected status code %d", code)
+			}
+			if n := testutil.ToFloat64(svc.metrics.RateLimitedRequests.WithLabelValues(rateLimitBalance)); n != 1 {
+				t.Errorf("rate limited balance queries: got %v, expected 1", n)
+			}
+		})
+	}
+}
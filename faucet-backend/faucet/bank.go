@@ -0,0 +1,584 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/connection"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/accounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+)
+
+// Returns the name of the paratime corresponding to paratimeId.
+func (svc *Service) paratimeName(paratimeId string) string {
+	for name, paratime := range svc.network.ParaTimes.All {
+		if paratimeId == paratime.ID {
+			return name
+		}
+	}
+	// Should never happen since the input has been validated
+	// by the frontend.
+	svc.log.Printf("bank/paratime: unknown paratime id %s",
+		paratimeId,
+	)
+	return "unknown_paratime"
+}
+
+type FundRequest struct {
+	// ID is the request ID used for status tracking, if any.
+	ID string
+
+	ParaTime   *config.ParaTime
+	Account    *types.Address
+	EthAccount *ethCommon.Address
+
+	ConsensusAmount *types.Quantity
+	ParaTimeAmount  *types.BaseUnits
+
+	// Bundle is the bundle this request is part of, if any.
+	Bundle *BundleRequest
+
+	// Fee is the formatted transaction fee paid to fund the request.
+	Fee string
+
+	// defaultAmount is set if the request did not specify an amount.
+	defaultAmount bool
+}
+
+// finishFundRequest releases the resources held by a completed funding
+// request, reports the outcome, and accounts for the bundle the request
+// is part of, if any.
+func (svc *Service) finishFundRequest(req *FundRequest, failure error) {
+	if failure != nil {
+		svc.requests.Fail(req.ID, failure)
+	} else {
+		svc.requests.Update(req.ID, api.RequestConfirmed, nil)
+		svc.requests.AddPayout(svc.newPayout(req))
+		svc.balances.invalidate(balanceCacheKey(req.ParaTime, req.Account))
+	}
+
+	if req.Bundle == nil {
+		svc.ClearAddress(req.Account)
+		return
+	}
+
+	done, failed := req.Bundle.finish(failure == nil)
+	if !done {
+		return
+	}
+	defer svc.ClearAddress(req.Bundle.Account)
+
+	endpoint := bundleEndpoint(req.Bundle.Name)
+	if failed {
+		svc.log.Printf("bank/bundle: request failed: [%v]%v", req.Bundle.Name, req.Bundle.Account)
+		svc.requests.Fail(req.Bundle.ID, fmt.Errorf("one or more bundle items failed"))
+		svc.metrics.Requests.WithLabelValues(endpoint, "failure").Inc()
+		return
+	}
+
+	status := "success"
+	if svc.cfg.DryRun {
+		status = "dry_run"
+		svc.log.Printf("bank/bundle: DRY RUN: request successful: [%v]%v", req.Bundle.Name, req.Bundle.Account)
+	} else {
+		svc.log.Printf("bank/bundle: request successful: [%v]%v", req.Bundle.Name, req.Bundle.Account)
+	}
+	svc.requests.Update(req.Bundle.ID, api.RequestConfirmed, func(st *api.RequestStatus) {
+		st.DryRun = svc.cfg.DryRun
+	})
+	svc.metrics.RequestLatencies.WithLabelValues(endpoint).Observe(time.Since(req.Bundle.start).Seconds())
+	svc.metrics.Requests.WithLabelValues(endpoint, status).Inc()
+}
+
+// newPayout returns the anonymized payout record for a funding request.
+func (svc *Service) newPayout(req *FundRequest) api.Payout {
+	payout := api.Payout{
+		Time:   time.Now(),
+		Fee:    req.Fee,
+		DryRun: svc.cfg.DryRun,
+	}
+	switch req.ParaTime {
+	case nil:
+		payout.Account = anonymizeAccount(req.Account.String())
+		payout.Amount = helpers.FormatConsensusDenomination(svc.network, *req.ConsensusAmount)
+	default:
+		account := req.Account.String()
+		if req.EthAccount != nil {
+			account = req.EthAccount.Hex()
+		}
+		payout.ParaTime = svc.paratimeName(req.ParaTime.ID)
+		payout.Account = anonymizeAccount(account)
+		payout.Amount = helpers.FormatParaTimeDenomination(req.ParaTime, *req.ParaTimeAmount)
+	}
+	return payout
+}
+
+// connectChainBackend connects to the network's gRPC endpoint, retrying
+// until it succeeds.
+func (svc *Service) connectChainBackend(ctx context.Context) chain.Backend {
+	for {
+		svc.log.Printf("bank: attempting to connect to gRPC endpoint")
+		// XXX: Revert to Connect() when oasis-sdk updates to be compatible with oasis-core v23
+		conn, err := connection.ConnectNoVerify(ctx, svc.network)
+		if err != nil {
+			svc.log.Printf("bank: failed to connect to node: %v", err)
+			time.Sleep(15 * time.Second)
+			continue
+		}
+
+		svc.log.Printf("bank: connected to gRPC endpoint")
+
+		return chain.NewConnectionBackend(conn)
+	}
+}
+
+func (svc *Service) BankWorker() {
+	svc.log.Printf("bank: started")
+	if svc.cfg.DryRun {
+		svc.log.Printf("bank: DRY RUN: transactions will not be submitted")
+	}
+
+	// XXX: Wire into termination.
+	ctx := context.Background()
+
+	backend := svc.chain
+	if backend == nil {
+		backend = svc.connectChainBackend(ctx)
+	}
+
+	chainContext, err := backend.ChainContext(ctx)
+	svc.network.ChainContext = chainContext
+	if err != nil {
+		svc.log.Printf("bank: failed to retrieve remote node's chain context: %s", err)
+	}
+
+	// Refill the allowances.
+	svc.RefillAllowances(ctx, backend)
+
+	// The frontend only uses the chain backend once the bank is ready.
+	svc.chain = backend
+
+	// Mark as ready to accept requests.
+	close(svc.readyCh)
+
+	refillTicker := time.NewTicker(1 * time.Hour)
+	for {
+		select {
+		case req := <-svc.fundRequestCh:
+			// Note: Access control, validation, and non-debug logging is
+			// handled by the frontend.
+			if req.ParaTime == nil {
+				svc.FundConsensusRequest(ctx, backend, req)
+			} else {
+				svc.FundParaTimeRequest(ctx, backend, req)
+			}
+		case req := <-svc.bundleRequestCh:
+			svc.FundBundleRequest(ctx, backend, req)
+		case <-refillTicker.C:
+			svc.RefillAllowances(ctx, backend)
+		case <-svc.quitCh:
+			return
+		}
+	}
+}
+
+func (svc *Service) FundBundleRequest(ctx context.Context, backend chain.Backend, req *BundleRequest) {
+	svc.requests.Update(req.ID, api.RequestSubmitted, nil)
+
+	req.lock.Lock()
+	req.start = time.Now()
+	req.pending = len(req.Items)
+	req.lock.Unlock()
+
+	// The items are already ordered so that the consensus transfers
+	// happen before the paratime deposits.
+	for _, item := range req.Items {
+		switch {
+		case item.ParaTime == nil:
+			svc.FundConsensusRequest(ctx, backend, item)
+		case item.isToken():
+			svc.FundParaTimeTokenRequest(ctx, backend, item)
+		default:
+			svc.FundParaTimeRequest(ctx, backend, item)
+		}
+	}
+}
+
+func (svc *Service) FundConsensusRequest(ctx context.Context, backend chain.Backend, req *FundRequest) {
+	var failure error
+	defer func() {
+		svc.finishFundRequest(req, failure)
+	}()
+
+	var elapsed time.Duration
+	start := time.Now()
+
+	xfer := staking.Transfer{
+		To:     req.Account.ConsensusAddress(),
+		Amount: *req.ConsensusAmount,
+	}
+	tx := staking.NewTransferTx(0, new(consensusTx.Fee), &xfer)
+	var (
+		txResult *ConsensusTxResult
+		err      error
+	)
+	attempts := new(consensusTxAttempts)
+	defer attempts.close()
+	for attempt := 0; ; attempt++ {
+		if txResult, err = svc.SignAndSubmitConsensusTx(ctx, backend, tx, req.ID, attempts); !svc.shouldRetryTx(attempt, err) {
+			break
+		}
+		svc.log.Printf("bank/consensus: retrying tx (%v: %v): %v", xfer.To.String(), xfer.Amount.String(), err)
+	}
+	if err != nil {
+		svc.log.Printf("bank/consesus: failed to submit tx (%v: %v): %v",
+			xfer.To.String(),
+			xfer.Amount.String(),
+			err,
+		)
+		svc.metrics.Requests.WithLabelValues("consensus", "failure").Inc()
+		failure = err
+		return
+	}
+
+	if txResult.DryRun {
+		req.Fee = helpers.FormatConsensusDenomination(svc.network, txResult.Fee)
+		svc.log.Printf("bank/consensus: DRY RUN: request successful: %v: %v TEST (tx: %s fee: %s)",
+			xfer.To.String(),
+			xfer.Amount.String(),
+			txResult.Hash,
+			req.Fee,
+		)
+		svc.metrics.Requests.WithLabelValues("consensus", "dry_run").Inc()
+		return
+	}
+
+	// Ensure that the transfer actually happened.
+	if !hasTransferEvent(txResult.Result, svc.address, &xfer) {
+		svc.log.Printf("bank/consensus: tx %s at height %d missing transfer event (%v: %v)",
+			txResult.Hash,
+			txResult.Height,
+			xfer.To.String(),
+			xfer.Amount.String(),
+		)
+		svc.metrics.Requests.WithLabelValues("consensus", "failure").Inc()
+		failure = fmt.Errorf("transfer not executed")
+		return
+	}
+
+	req.Fee = helpers.FormatConsensusDenomination(svc.network, txResult.Fee)
+	svc.log.Printf("bank/consensus: request successful: %v: %v TEST (tx: %s height: %d fee: %s)",
+		xfer.To.String(),
+		xfer.Amount.String(),
+		txResult.Hash,
+		txResult.Height,
+		req.Fee,
+	)
+
+	elapsed = time.Since(start)
+	svc.metrics.RequestLatencies.WithLabelValues("consensus").Observe(elapsed.Seconds())
+	svc.metrics.RequestStageLatencies.WithLabelValues("consensus", "submit").Observe(txResult.SubmitLatency.Seconds())
+	svc.metrics.RequestStageLatencies.WithLabelValues("consensus", "inclusion").Observe(txResult.InclusionLatency.Seconds())
+	svc.metrics.Requests.WithLabelValues("consensus", "success").Inc()
+}
+
+// hasTransferEvent returns true iff the transaction result contains the
+// staking transfer event corresponding to xfer.
+func hasTransferEvent(result *results.Result, from staking.Address, xfer *staking.Transfer) bool {
+	for _, ev := range result.Events {
+		if ev.Staking == nil || ev.Staking.Transfer == nil {
+			continue
+		}
+		te := ev.Staking.Transfer
+		if te.From.Equal(from) && te.To.Equal(xfer.To) && te.Amount.Cmp(&xfer.Amount) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (svc *Service) FundParaTimeRequest(ctx context.Context, backend chain.Backend, req *FundRequest) {
+	var (
+		submitOk bool
+		failure  error
+	)
+	defer func() {
+		if !submitOk {
+			svc.finishFundRequest(req, failure)
+		}
+	}()
+
+	var elapsed time.Duration
+	start := time.Now()
+	reqParatimeName := svc.paratimeName(req.ParaTime.ID)
+
+	// Just asssume that there is sufficient allowance, and that the periodic
+	// refill adequately handles keeping the allowance topped off.
+
+	depositBody := &consensusaccounts.Deposit{
+		To:     req.Account,
+		Amount: *req.ParaTimeAmount,
+	}
+	tx := consensusaccounts.NewDepositTx(nil, depositBody)
+	var (
+		txResult *RuntimeTxResult
+		err      error
+	)
+	attempts := new(runtimeTxAttempts)
+	for attempt := 0; ; attempt++ {
+		if txResult, err = svc.SignAndSubmitRuntimeTx(ctx, backend, req.ParaTime, tx, req.ID, attempts); !svc.shouldRetryTx(attempt, err) {
+			break
+		}
+		svc.log.Printf("bank/paratime: retrying tx (%v: %v): %v", depositBody.To.String(), depositBody.Amount.String(), err)
+	}
+	if err != nil {
+		svc.log.Printf("bank/paratime: failed to submit tx (%v: %v): %v",
+			depositBody.To.String(),
+			depositBody.Amount.String(),
+			err,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+		failure = err
+		return
+	}
+
+	if txResult.DryRun {
+		req.Fee = helpers.FormatParaTimeDenomination(req.ParaTime, txResult.Fee)
+		svc.log.Printf("bank/paratime: DRY RUN: request successful: %v: %v TEST (tx: %s fee: %s)",
+			depositBody.To.String(),
+			depositBody.Amount.String(),
+			txResult.Hash,
+			req.Fee,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "dry_run").Inc()
+		return
+	}
+
+	// The deposit only completes once the consensus layer processes the
+	// resulting message, which is signaled by a deposit event.
+	expectedFrom := types.NewAddressFromConsensus(svc.address)
+	expectedNonce := tx.AuthInfo.SignerInfo[0].Nonce
+	watcher, err := svc.WatchRuntimeEvent(
+		ctx,
+		backend,
+		req.ParaTime,
+		txResult.Round,
+		[]client.EventDecoder{chain.EventDecoderFunc(consensusaccounts.DecodeEvent)},
+		func(ev client.DecodedEvent) bool {
+			ce, ok := ev.(*consensusaccounts.Event)
+			if !ok || ce.Deposit == nil {
+				return false
+			}
+			return ce.Deposit.From.Equal(expectedFrom) && ce.Deposit.Nonce == expectedNonce
+		},
+	)
+	if err != nil {
+		svc.log.Printf("bank/paratime: failed to watch for deposit (%v: %v): %v",
+			depositBody.To.String(),
+			depositBody.Amount.String(),
+			err,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+		failure = err
+		return
+	}
+
+	req.Fee = helpers.FormatParaTimeDenomination(req.ParaTime, txResult.Fee)
+
+	submitOk = true
+	go func() {
+		var failure error
+		defer func() {
+			svc.finishFundRequest(req, failure)
+		}()
+
+		rawEv, evOk := <-watcher.ResultCh
+		if !evOk {
+			svc.log.Printf("bank/paratime: failed to wait for event: %v", watcher.Context.Err())
+			svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+			failure = fmt.Errorf("failed to wait for deposit")
+			return
+		}
+		ev := rawEv.(*consensusaccounts.Event).Deposit
+
+		if !ev.IsSuccess() {
+			svc.log.Printf("bank/paratime: tx failed with error: module: %s code: %d",
+				ev.Error.Module,
+				ev.Error.Code,
+			)
+			svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+			failure = fmt.Errorf("deposit failed")
+			return
+		}
+
+		svc.log.Printf("bank/paratime: request successful: %v: %v TEST (fee: %s)",
+			depositBody.To.String(),
+			depositBody.Amount.String(),
+			req.Fee,
+		)
+
+		elapsed = time.Since(start)
+		svc.metrics.RequestLatencies.WithLabelValues(reqParatimeName).Observe(elapsed.Seconds())
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "success").Inc()
+	}()
+}
+
+// FundParaTimeTokenRequest funds a paratime account with a test token.
+// Unlike the native denomination, test tokens can not be deposited from
+// the consensus layer, so they are transferred from the faucet's paratime
+// account instead.
+func (svc *Service) FundParaTimeTokenRequest(ctx context.Context, backend chain.Backend, req *FundRequest) {
+	var failure error
+	defer func() {
+		svc.finishFundRequest(req, failure)
+	}()
+
+	start := time.Now()
+	reqParatimeName := svc.paratimeName(req.ParaTime.ID)
+
+	xfer := accounts.Transfer{
+		To:     *req.Account,
+		Amount: *req.ParaTimeAmount,
+	}
+	tx := accounts.NewTransferTx(nil, &xfer)
+	var (
+		txResult *RuntimeTxResult
+		err      error
+	)
+	attempts := new(runtimeTxAttempts)
+	for attempt := 0; ; attempt++ {
+		if txResult, err = svc.SignAndSubmitRuntimeTx(ctx, backend, req.ParaTime, tx, req.ID, attempts); !svc.shouldRetryTx(attempt, err) {
+			break
+		}
+		svc.log.Printf("bank/paratime: retrying tx (%v: %v): %v", xfer.To.String(), xfer.Amount.String(), err)
+	}
+	if err != nil {
+		svc.log.Printf("bank/paratime: failed to submit tx (%v: %v): %v",
+			xfer.To.String(),
+			xfer.Amount.String(),
+			err,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+		failure = err
+		return
+	}
+
+	req.Fee = helpers.FormatParaTimeDenomination(req.ParaTime, txResult.Fee)
+	if txResult.DryRun {
+		svc.log.Printf("bank/paratime: DRY RUN: request successful: %v: %v (tx: %s fee: %s)",
+			xfer.To.String(),
+			xfer.Amount.String(),
+			txResult.Hash,
+			req.Fee,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "dry_run").Inc()
+		return
+	}
+
+	// Ensure that the transfer actually happened.  The transfer event is
+	// emitted in the round that includes the transaction, but if only an
+	// earlier attempt was included, the round is not known.
+	expectedFrom := types.NewAddressFromConsensus(svc.address)
+	watcher, err := svc.WatchRuntimeEvent(
+		ctx,
+		backend,
+		req.ParaTime,
+		txResult.Round,
+		[]client.EventDecoder{chain.EventDecoderFunc(accounts.DecodeEvent)},
+		func(ev client.DecodedEvent) bool {
+			ae, ok := ev.(*accounts.Event)
+			if !ok || ae.Transfer == nil {
+				return false
+			}
+			te := ae.Transfer
+			return te.From.Equal(expectedFrom) && te.To.Equal(xfer.To) &&
+				te.Amount.Denomination == xfer.Amount.Denomination && te.Amount.Amount.Cmp(&xfer.Amount.Amount) == 0
+		},
+	)
+	if err != nil {
+		svc.log.Printf("bank/paratime: failed to watch for transfer (%v: %v): %v",
+			xfer.To.String(),
+			xfer.Amount.String(),
+			err,
+		)
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+		failure = err
+		return
+	}
+	if _, ok := <-watcher.ResultCh; !ok {
+		svc.log.Printf("bank/paratime: failed to wait for event: %v", watcher.Context.Err())
+		svc.metrics.Requests.WithLabelValues(reqParatimeName, "failure").Inc()
+		failure = fmt.Errorf("transfer not executed")
+		return
+	}
+
+	svc.log.Printf("bank/paratime: request successful: %v: %v (round: %d fee: %s)",
+		xfer.To.String(),
+		xfer.Amount.String(),
+		txResult.Round,
+		req.Fee,
+	)
+
+	svc.metrics.RequestLatencies.WithLabelValues(reqParatimeName).Observe(time.Since(start).Seconds())
+	svc.metrics.Requests.WithLabelValues(reqParatimeName, "success").Inc()
+}
+
+func (svc *Service) RefillAllowances(ctx context.Context, backend chain.Backend) {
+	// Failures are ignored under the assumption that there is sufficient allowance
+	// already.
+	svc.log.Printf("bank: refilling allowances")
+
+	// Query the existing allowances.
+	consensusAccount, err := backend.ConsensusAccount(ctx, svc.address)
+	if err != nil {
+		svc.log.Printf("bank: failed to query funding account: %v", err)
+		return
+	}
+	svc.metrics.Balances.WithLabelValues("consensus").Set(metricValue(&consensusAccount.General.Balance))
+	svc.refreshLimits(nil, consensusAccount)
+
+	for ptName, pt := range svc.network.ParaTimes.All {
+		ptAddr := staking.NewRuntimeAddress(pt.Namespace())
+		allowance := consensusAccount.General.Allowances[ptAddr]
+
+		svc.metrics.Balances.WithLabelValues(ptName).Set(metricValue(&allowance))
+		svc.refreshLimits(pt, consensusAccount)
+
+		svc.log.Printf("refill: %v allowance: %v", ptName, allowance)
+
+		// Figure out if we need to increase.
+		toFund := svc.cfg.TargetAllowance.Clone()
+		if err = toFund.Sub(&allowance); err != nil || toFund.IsZero() {
+			svc.log.Printf("bank: paratime '%s' already has sufficient allowance: %v", ptName, allowance)
+			continue
+		}
+
+		// Build the staking transaction.
+		allow := staking.Allow{
+			Beneficiary:  ptAddr,
+			Negative:     false,
+			AmountChange: *toFund,
+		}
+		tx := staking.NewAllowTx(0, new(consensusTx.Fee), &allow)
+		attempts := new(consensusTxAttempts)
+		_, err := svc.SignAndSubmitConsensusTx(ctx, backend, tx, "", attempts)
+		attempts.close()
+		if err != nil {
+			svc.log.Printf("bank: failed to add allowance to paratime '%s': %v", ptName, err)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestRefillAllowances(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+	}
+	svc, chain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	for name, pt := range svc.network.ParaTimes.All {
+		allowance := chain.Allowance(svc.address, pt)
+		if allowance.Cmp(&cfg.TargetAllowance) != 0 {
+			t.Errorf("%s allowance: got %v, expected %v", name, allowance, cfg.TargetAllowance)
+		}
+	}
+
+	// Refilling an allowance that is already at the target is a no-op.
+	acct, _ := chain.ConsensusAccount(context.Background(), svc.address)
+	svc.RefillAllowances(context.Background(), chain)
+	acctAfter, _ := chain.ConsensusAccount(context.Background(), svc.address)
+	if acctAfter.General.Nonce != acct.General.Nonce {
+		t.Errorf("refill submitted transactions when allowances are at the target")
+	}
+}
+
+func TestFundConsensus(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		Fees: faucetConfig.FeeConfig{
+			ConsensusGasPrice: testQuantity(t, "1"),
+		},
+	}
+	svc, chain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	to := testAddress(t)
+	balanceBefore := chain.ConsensusBalance(svc.address)
+
+	code, resp := fund(t, svc, "", to.String(), "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	if status.TxHash == "" || status.Height == 0 {
+		t.Errorf("request status missing tx hash or height: %+v", status)
+	}
+
+	balance := chain.ConsensusBalance(to)
+	if expected := testQuantity(t, "10000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+
+	// The faucet pays for the transfer, and the fee.
+	spent := balanceBefore.Clone()
+	balanceAfter := chain.ConsensusBalance(svc.address)
+	_ = spent.Sub(&balanceAfter)
+	if expected := testQuantity(t, "10000001000"); spent.Cmp(&expected) != 0 {
+		t.Errorf("faucet spent: got %v, expected %v", spent, expected)
+	}
+
+	payouts := svc.requests.RecentPayouts()
+	if len(payouts) != 1 || payouts[0].Amount != "10.0 TEST" {
+		t.Errorf("unexpected payouts: %+v", payouts)
+	}
+}
+
+// lateChain is a memory chain that reports the failure of submitting the
+// next consensus transaction, but includes it along with the one after.
+// Similarly, it reports the failure of the next runtime transaction,
+// which is executed regardless.
+type lateChain struct {
+	*chain.MemoryChain
+
+	lock    sync.Mutex
+	delay   bool
+	delayed *consensusTx.SignedTransaction
+	lose    bool
+}
+
+func (c *lateChain) loseNext() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.lose = true
+}
+
+func (c *lateChain) SubmitRuntimeTx(ctx context.Context, pt *config.ParaTime, utx *types.UnverifiedTransaction) (*client.SubmitTxRawMeta, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	meta, err := c.MemoryChain.SubmitRuntimeTx(ctx, pt, utx)
+	if c.lose {
+		c.lose = false
+		return nil, fmt.Errorf("late chain: submission timed out")
+	}
+	return meta, err
+}
+
+func (c *lateChain) delayNext() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.delay = true
+}
+
+func (c *lateChain) SubmitConsensusTx(ctx context.Context, sigTx *consensusTx.SignedTransaction) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch {
+	case c.delay:
+		c.delay, c.delayed = false, sigTx
+		return fmt.Errorf("late chain: submission timed out")
+	case c.delayed != nil:
+		if err := c.MemoryChain.SubmitConsensusTx(ctx, c.delayed); err != nil {
+			return err
+		}
+		c.delayed = nil
+	}
+	return c.MemoryChain.SubmitConsensusTx(ctx, sigTx)
+}
+
+func TestFundConsensusLateInclusion(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		Fees: faucetConfig.FeeConfig{
+			ConsensusGasPrice: testQuantity(t, "1"),
+			MaxRetries:        2,
+			RetryFeeBump:      2,
+		},
+	}
+	svc, memChain := newTestService(t, cfg)
+	lateChain := &lateChain{MemoryChain: memChain}
+	svc.chain = lateChain
+	startBank(t, svc)
+
+	// The retry fails as the initial attempt is included first, which is
+	// then reported.
+	to := testAddress(t)
+	balanceBefore := memChain.ConsensusBalance(svc.address)
+	lateChain.delayNext()
+	code, resp := fund(t, svc, "", to.String(), "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	balance := memChain.ConsensusBalance(to)
+	if expected := testQuantity(t, "10000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+	spent := balanceBefore.Clone()
+	balanceAfter := memChain.ConsensusBalance(svc.address)
+	_ = spent.Sub(&balanceAfter)
+	if expected := testQuantity(t, "10000001000"); spent.Cmp(&expected) != 0 {
+		t.Errorf("faucet spent: got %v, expected %v", spent, expected)
+	}
+
+	payouts := svc.requests.RecentPayouts()
+	if len(payouts) != 1 || payouts[0].Fee != "0.000001 TEST" {
+		t.Errorf("unexpected payouts: %+v", payouts)
+	}
+}
+
+func TestFundParaTime(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+	}
+	svc, chain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	if status.TxHash == "" || status.Round == 0 {
+		t.Errorf("request status missing tx hash or round: %+v", status)
+	}
+
+	pt := svc.network.ParaTimes.All["sapphire"]
+	to, _, err := helpers.ResolveEthOrOasisAddress(testAccountSapphire)
+	if err != nil {
+		t.Fatalf("failed to resolve account: %v", err)
+	}
+	balance := chain.RuntimeBalance(pt, *to)
+	if expected := testQuantity(t, "10000000000000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+
+	// The deposit is paid for out of the allowance, in consensus units.
+	allowance := chain.Allowance(svc.address, pt)
+	if expected := testQuantity(t, "9990000000000"); allowance.Cmp(&expected) != 0 {
+		t.Errorf("allowance: got %v, expected %v", allowance, expected)
+	}
+}
+
+func TestFundParaTimeLateInclusion(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		Fees: faucetConfig.FeeConfig{
+			MaxRetries: 2,
+		},
+	}
+	svc, memChain := newTestService(t, cfg)
+	lateChain := &lateChain{MemoryChain: memChain}
+	svc.chain = lateChain
+	startBank(t, svc)
+
+	// The retry fails the transaction check as the initial attempt was
+	// executed, the deposit of which is then waited for.
+	lateChain.loseNext()
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	pt := svc.network.ParaTimes.All["sapphire"]
+	to, _, err := helpers.ResolveEthOrOasisAddress(testAccountSapphire)
+	if err != nil {
+		t.Fatalf("failed to resolve account: %v", err)
+	}
+	balance := memChain.RuntimeBalance(pt, *to)
+	if expected := testQuantity(t, "10000000000000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+}
+
+func TestFundInsufficientBalance(t *testing.T) {
+	svc, chain := newTestService(t, &faucetConfig.Config{})
+	chain.SetConsensusBalance(svc.address, testQuantity(t, "1000000000"))
+	startBank(t, svc)
+
+	to := testAddress(t)
+	code, resp := fund(t, svc, "", to.String(), "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestFailed {
+		t.Fatalf("request state: got %v, expected %v", status.State, api.RequestFailed)
+	}
+	if balance := chain.ConsensusBalance(to); !balance.IsZero() {
+		t.Errorf("recipient balance: got %v, expected 0", balance)
+	}
+
+	// A failed request releases the account for further requests.
+	code, resp = fund(t, svc, "", to.String(), "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status = waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+}
+
+func TestFundInsufficientAllowance(t *testing.T) {
+	// Without a target allowance, the paratimes are never allowed to
+	// withdraw from the faucet.
+	svc, _ := newTestService(t, &faucetConfig.Config{})
+	startBank(t, svc)
+
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "10")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	status := waitForRequest(t, svc, resp.RequestID)
+	if status.State != api.RequestFailed || status.Reason != "deposit failed" {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestFailed)
+	}
+}
+
+func TestFundDryRun(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		DryRun:          true,
+	}
+	svc, chain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	to := testAddress(t)
+	acctBefore, _ := chain.ConsensusAccount(context.Background(), svc.address)
+
+	for _, tc := range []struct {
+		paraTime string
+		account  string
+	}{
+		{"", to.String()},
+		{"sapphire", testAccountSapphire},
+	} {
+		code, resp := fund(t, svc, tc.paraTime, tc.account, "10")
+		if code != http.StatusOK || !resp.DryRun {
+			t.Fatalf("fund: unexpected response %d: %+v", code, resp)
+		}
+		status := waitForRequest(t, svc, resp.RequestID)
+		if status.State != api.RequestConfirmed {
+			t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+		}
+		if !status.DryRun || status.TxHash == "" || len(status.SignedTx) == 0 {
+			t.Errorf("request status missing dry-run transaction: %+v", status)
+		}
+	}
+
+	// Nothing was submitted, including the allowance refills.
+	acctAfter, _ := chain.ConsensusAccount(context.Background(), svc.address)
+	if acctAfter.General.Nonce != acctBefore.General.Nonce || acctAfter.General.Nonce != 0 {
+		t.Errorf("dry run submitted transactions")
+	}
+	if balance := chain.ConsensusBalance(to); !balance.IsZero() {
+		t.Errorf("recipient balance: got %v, expected 0", balance)
+	}
+	for _, payout := range svc.requests.RecentPayouts() {
+		if !payout.DryRun {
+			t.Errorf("payout not labeled as dry run: %+v", payout)
+		}
+	}
+}
+
+func TestMockChain(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		MockChain: &faucetConfig.MockChainConfig{
+			Balance:      "1000",
+			DepositDelay: "100ms",
+		},
+	}
+	if err := cfg.MockChain.Validate(); err != nil {
+		t.Fatalf("invalid mock memChain config: %v", err)
+	}
+	svc, _ := newTestService(t, cfg)
+	memChain, err := chain.NewMockChain(svc.network, cfg.MockChain, svc.address)
+	if err != nil {
+		t.Fatalf("failed to create mock memChain: %v", err)
+	}
+	svc.chain = memChain
+	startBank(t, svc)
+
+	balance := memChain.ConsensusBalance(svc.address)
+	if expected := testQuantity(t, "1000000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("faucet balance: got %v, expected %v", balance, expected)
+	}
+
+	// Deposits complete once the delayed deposit event is emitted.
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	// Injected transaction check failures fail the request.
+	memChain.SetFailures(chain.MemoryChainFailures{
+		CheckTxRate: 1,
+	})
+	for _, tc := range []struct {
+		paraTime string
+		account  string
+	}{
+		{"", testAddress(t).String()},
+		{"sapphire", testAccountSapphire},
+	} {
+		code, resp = fund(t, svc, tc.paraTime, tc.account, "1")
+		if code != http.StatusOK {
+			t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+		}
+		if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestFailed {
+			t.Fatalf("request state: got %v, expected %v", status.State, api.RequestFailed)
+		}
+	}
+}
+
+func TestMockChainEmptyAllowance(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+	}
+	svc, memChain := newTestService(t, cfg)
+	memChain.SetFailures(chain.MemoryChainFailures{
+		EmptyAllowance: true,
+	})
+	startBank(t, svc)
+
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestFailed {
+		t.Fatalf("request state: got %v, expected %v", status.State, api.RequestFailed)
+	}
+}
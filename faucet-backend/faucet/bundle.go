@@ -0,0 +1,273 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+const queryBundle = "bundle"
+
+// BundleRequest is a set of funding requests to a single account that is
+// processed and tracked as one unit.
+type BundleRequest struct {
+	ID      string
+	Name    string
+	Account *types.Address
+
+	// Items are the individual funding requests, with the consensus
+	// requests ordered before the paratime requests.
+	Items []*FundRequest
+
+	lock    sync.Mutex
+	start   time.Time
+	pending int
+	failed  bool
+}
+
+// finish marks one of the bundle's funding requests as complete, and
+// returns true iff it was the last outstanding request.
+func (b *BundleRequest) finish(ok bool) (bool, bool) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.pending--
+	if !ok {
+		b.failed = true
+	}
+	return b.pending == 0, b.failed
+}
+
+// bundleQuota tracks how many times a bundle was claimed during the
+// current day.
+type bundleQuota struct {
+	day    time.Time
+	claims uint64
+}
+
+func bundleEndpoint(name string) string {
+	return "bundle_" + name
+}
+
+// validateBundles ensures that the bundles only fund the network's
+// paratimes and their denominations, with amounts that can be represented
+// and do not exceed the per-request maximum, and that a single account can
+// be funded by every item of a bundle.
+func (svc *Service) validateBundles() error {
+	for name, bundleCfg := range svc.cfg.Bundles {
+		allowOasis, allowEth := true, true
+		for _, item := range bundleCfg.Items {
+			var pt *config.ParaTime
+			if item.ParaTime != "" {
+				if pt = svc.network.ParaTimes.All[item.ParaTime]; pt == nil {
+					return fmt.Errorf("bundle '%s': unknown paratime: '%s'", name, item.ParaTime)
+				}
+			}
+			if item.Denomination != "" {
+				if pt == nil || pt.Denominations[item.Denomination] == nil || item.Denomination == config.NativeDenominationKey {
+					return fmt.Errorf("bundle '%s': unknown denomination on paratime '%s': '%s'", name, item.ParaTime, item.Denomination)
+				}
+			}
+			if err := svc.validateBundleAmount(pt, &item); err != nil {
+				return fmt.Errorf("bundle '%s': %w", name, err)
+			}
+			oasis, eth := svc.addressKinds(pt)
+			allowOasis, allowEth = allowOasis && oasis, allowEth && eth
+		}
+		if !allowOasis && !allowEth {
+			return fmt.Errorf("bundle '%s': no kind of address can be funded by every item", name)
+		}
+	}
+	return nil
+}
+
+// validateBundleAmount ensures that the amount of a bundle item can be
+// funded to the consensus layer (nil paratime) or the paratime.
+func (svc *Service) validateBundleAmount(pt *config.ParaTime, item *faucetConfig.BundleItemConfig) error {
+	if item.Denomination != "" {
+		amount, err := helpers.ParseParaTimeDenomination(pt, item.Amount, types.Denomination(item.Denomination))
+		if err != nil || amount.Amount.IsZero() {
+			return fmt.Errorf("invalid amount: '%s'", item.Amount)
+		}
+		return nil
+	}
+
+	amount, err := svc.parseTokens(pt, item.Amount)
+	if err != nil || amount.IsZero() {
+		return fmt.Errorf("invalid amount: '%s'", item.Amount)
+	}
+	max, err := svc.maxAmount(pt)
+	if err != nil {
+		return fmt.Errorf("invalid maximum amount: %w", err)
+	}
+	if max != nil && amount.Cmp(max) > 0 {
+		return fmt.Errorf("amount above the maximum: '%s'", item.Amount)
+	}
+	return nil
+}
+
+// TryClaimBundle attempts to claim a bundle against its daily quota.
+func (svc *Service) TryClaimBundle(name string) bool {
+	max := svc.cfg.Bundles[name].MaxClaimsPerDay
+	if max == 0 {
+		return true
+	}
+
+	svc.bundleQuotaLock.Lock()
+	defer svc.bundleQuotaLock.Unlock()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	quota := svc.bundleQuotas[name]
+	if quota == nil || !quota.day.Equal(today) {
+		quota = &bundleQuota{
+			day: today,
+		}
+		svc.bundleQuotas[name] = quota
+	}
+	if quota.claims >= max {
+		return false
+	}
+	quota.claims++
+	return true
+}
+
+// UnclaimBundle returns a claim obtained via TryClaimBundle to the quota.
+func (svc *Service) UnclaimBundle(name string) {
+	svc.bundleQuotaLock.Lock()
+	defer svc.bundleQuotaLock.Unlock()
+
+	if quota := svc.bundleQuotas[name]; quota != nil && quota.claims > 0 {
+		quota.claims--
+	}
+}
+
+// SubmitBundleRequest validates and enqueues a bundle request.  The
+// returned error is suitable for displaying to the user.
+func (svc *Service) SubmitBundleRequest(ctx context.Context, params *api.BundleParams) (*api.FundResponse, error) {
+	name := strings.TrimSpace(params.Bundle)
+	accountStr := strings.TrimSpace(params.Account)
+
+	bundleCfg := svc.cfg.Bundles[name]
+	if bundleCfg == nil {
+		svc.log.Printf("frontend/bundle: invalid bundle: '%v'", name)
+		return nil, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidBundle, queryBundle, "failed to fund account: invalid bundle: '%v'", name)
+	}
+	items := make([]fundItem, 0, len(bundleCfg.Items))
+	for _, item := range bundleCfg.Items {
+		items = append(items, fundItem{
+			paraTime:     item.ParaTime,
+			amount:       item.Amount,
+			denomination: types.Denomination(item.Denomination),
+		})
+	}
+
+	// The reCAPTCHA is checked once for the entire bundle.
+	adm, err := svc.admitFundRequests(ctx, accountStr, params.CaptchaResponse, items)
+	if err != nil {
+		return nil, err
+	}
+	bundleReq := &BundleRequest{
+		Name:    name,
+		Account: adm.requests[0].Account,
+		Items:   adm.requests,
+	}
+	for _, fundReq := range bundleReq.Items {
+		fundReq.Bundle = bundleReq
+	}
+
+	// Consensus transfers complete once included in a block, while
+	// paratime deposits complete only once the paratime has processed
+	// them, so pay out the consensus portion first.
+	sort.SliceStable(bundleReq.Items, func(i, j int) bool {
+		return bundleReq.Items[i].ParaTime == nil && bundleReq.Items[j].ParaTime != nil
+	})
+
+	// Allowlisted clients are not subject to the daily quota.
+	claimed := !adm.decision.Allowed
+	if claimed && !svc.TryClaimBundle(name) {
+		svc.ClearAddress(bundleReq.Account)
+		svc.log.Printf("frontend/bundle: bundle '%v' daily quota exhausted", name)
+		quotaErr := newAPIError(
+			http.StatusTooManyRequests,
+			api.ErrCodeQuotaExceeded,
+			queryBundle,
+			"bundle '%v' is exhausted for today, try again later", name,
+		)
+		quotaErr.RetryAfter = time.Until(time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour))
+		return nil, quotaErr
+	}
+
+	// Attempt to fund the address.
+	bundleReq.ID = svc.requests.New()
+	select {
+	case svc.bundleRequestCh <- bundleReq:
+	default:
+		// Queue backlog full, fail early.
+		err = errTemporaryFailure()
+		svc.requests.Fail(bundleReq.ID, err)
+		if claimed {
+			svc.UnclaimBundle(name)
+		}
+		svc.ClearAddress(bundleReq.Account)
+		return nil, err
+	}
+
+	svc.log.Printf("frontend/bundle: request enqueued: %v: [%v]%v: client: %v", bundleReq.ID, name, accountStr, svc.clients.Display(clientInfoFrom(ctx).IP))
+
+	return &api.FundResponse{
+		Result:       "funding request submitted",
+		RequestID:    bundleReq.ID,
+		DryRun:       svc.cfg.DryRun,
+		AmountReason: adm.amountReason(),
+	}, nil
+}
+
+// OnBundleRequest handles a bundle funding request.  The expected request
+// is a POST to `https://host:port/api/v1/bundle` with the `bundle` and
+// `account` form values.
+func (svc *Service) OnBundleRequest(w http.ResponseWriter, req *http.Request) {
+	// Ensure the user is POSTing, if auth is enabled.
+	authEnabled := svc.captcha != nil
+	if authEnabled {
+		if req.Method != http.MethodPost {
+			svc.log.Printf("frontend/bundle: invalid http method: '%v'", req.Method)
+			writeError(w, errMethodNotAllowed(req.Method))
+			return
+		}
+	}
+
+	// Parse the query and POST form (combined).
+	if err := req.ParseForm(); err != nil {
+		svc.log.Printf("frontend/bundle: invalid http request: %v", err)
+		writeError(w, newAPIError(
+			http.StatusBadRequest,
+			api.ErrCodeInvalidRequest,
+			"",
+			"invalid http request, failed to parse query/form",
+		))
+		return
+	}
+
+	ctx := withClientInfo(req.Context(), svc.clientInfoOf(req))
+	resp, err := svc.SubmitBundleRequest(ctx, &api.BundleParams{
+		Bundle:          req.Form.Get(queryBundle),
+		Account:         req.Form.Get(queryAccount),
+		CaptchaResponse: req.Form.Get(queryRecaptchaResponse),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
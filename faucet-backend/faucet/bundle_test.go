@@ -0,0 +1,281 @@
+package faucet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+func TestBundles(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{ParaTime: "sapphire", Amount: "2"},
+					{ParaTime: "", Amount: "1"},
+				},
+				MaxClaimsPerDay: 1,
+			},
+		},
+	}
+	svc, memChain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	// Every item is funded to the same account.
+	to := testAddress(t)
+	code, resp := bundle(t, svc, "starter", to.String())
+	if code != http.StatusOK {
+		t.Fatalf("bundle: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	balance := memChain.ConsensusBalance(to)
+	if expected := testQuantity(t, "1000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient consensus balance: got %v, expected %v", balance, expected)
+	}
+	pt := svc.network.ParaTimes.All["sapphire"]
+	balance = memChain.RuntimeBalance(pt, types.NewAddressFromConsensus(to))
+	if expected := testQuantity(t, "2000000000000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient paratime balance: got %v, expected %v", balance, expected)
+	}
+
+	// The daily quota is exhausted by the first claim.
+	code, resp = bundle(t, svc, "starter", testAddress(t).String())
+	if code != http.StatusTooManyRequests || resp.Error == nil || resp.Error.Code != api.ErrCodeQuotaExceeded {
+		t.Errorf("bundle: got status code %d (%+v), expected %d", code, resp.Error, http.StatusTooManyRequests)
+	}
+
+	// Unknown bundles are rejected.
+	code, resp = bundle(t, svc, "nonexistent", testAddress(t).String())
+	if code != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != api.ErrCodeInvalidBundle {
+		t.Errorf("bundle: got status code %d (%+v), expected %d", code, resp.Error, http.StatusBadRequest)
+	}
+}
+
+func TestBundleUnclaim(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{Amount: "1"},
+				},
+				MaxClaimsPerDay: 100,
+			},
+		},
+	}
+	svc, _ := newTestService(t, cfg)
+
+	// Without a bank, requests back up in the queue, and the claims of
+	// the requests that do not fit are returned to the quota.
+	queued := cap(svc.bundleRequestCh)
+	for i := 0; i < queued+2; i++ {
+		code, resp := bundle(t, svc, "starter", testAddress(t).String())
+		switch {
+		case i < queued && code != http.StatusOK:
+			t.Fatalf("bundle %d: unexpected status code %d: %s", i, code, resp.Result)
+		case i >= queued && code != http.StatusServiceUnavailable:
+			t.Fatalf("bundle %d: got status code %d, expected %d", i, code, http.StatusServiceUnavailable)
+		}
+	}
+	if claims := svc.bundleQuotas["starter"].claims; claims != uint64(queued) {
+		t.Errorf("bundle claims: got %d, expected %d", claims, queued)
+	}
+}
+
+func TestBundlePending(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{Amount: "1"},
+				},
+				MaxClaimsPerDay: 1,
+			},
+		},
+	}
+	svc, _ := newTestService(t, cfg)
+
+	// Bundles share the pending request check with plain funding
+	// requests, which rejects them before the quota is claimed.
+	to := testAddress(t)
+	if code, resp := fund(t, svc, "", to.String(), "1"); code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	code, resp := bundle(t, svc, "starter", to.String())
+	if code != http.StatusConflict || resp.Error == nil || resp.Error.Code != api.ErrCodeRequestPending {
+		t.Fatalf("bundle: got status code %d (%+v), expected %d", code, resp.Error, http.StatusConflict)
+	}
+	if quota := svc.bundleQuotas["starter"]; quota != nil && quota.claims != 0 {
+		t.Errorf("bundle claims: got %d, expected 0", quota.claims)
+	}
+}
+
+func TestBundleReserve(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Amounts: faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Min: "1"},
+		},
+		Reserve: faucetConfig.ReserveConfig{
+			Tiers: []faucetConfig.ReserveTierConfig{
+				{Below: "2000000", Factor: 0.1},
+			},
+		},
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"large": {
+				Items: []faucetConfig.BundleItemConfig{{Amount: "100"}},
+			},
+			"small": {
+				Items: []faucetConfig.BundleItemConfig{{Amount: "0.5"}},
+			},
+		},
+	}
+	svc, memChain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	// Items are reduced to the effective maximum.
+	to := testAddress(t)
+	code, resp := bundle(t, svc, "large", to.String())
+	if code != http.StatusOK || resp.AmountReason == "" {
+		t.Fatalf("bundle: unexpected response %d: %+v", code, resp)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	balance := memChain.ConsensusBalance(to)
+	if expected := testQuantity(t, "10000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+
+	// Items below the minimum are rejected.
+	code, resp = bundle(t, svc, "small", testAddress(t).String())
+	if code != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != api.ErrCodeAmountTooSmall {
+		t.Errorf("bundle: got status code %d (%+v), expected %d", code, resp.Error, http.StatusBadRequest)
+	}
+}
+
+func TestBundleTokens(t *testing.T) {
+	// Add a test token to a copy of Sapphire.
+	network := *config.DefaultNetworks.All["testnet"]
+	sapphire := *network.ParaTimes.All["sapphire"]
+	sapphire.Denominations = map[string]*config.DenominationInfo{
+		"FOO": {Symbol: "FOO", Decimals: 6},
+	}
+	for k, v := range network.ParaTimes.All["sapphire"].Denominations {
+		sapphire.Denominations[k] = v
+	}
+	network.ParaTimes.All = map[string]*config.ParaTime{
+		"sapphire": &sapphire,
+	}
+
+	cfg := &faucetConfig.Config{
+		TargetAllowance:       testQuantity(t, "10000000000000"),
+		MaxParatimeFundAmount: "1",
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{ParaTime: "sapphire", Amount: "1"},
+					// Test tokens are not subject to the native maximum.
+					{ParaTime: "sapphire", Amount: "5", Denomination: "FOO"},
+				},
+			},
+			"unfunded": {
+				Items: []faucetConfig.BundleItemConfig{
+					{ParaTime: "sapphire", Amount: "20", Denomination: "FOO"},
+				},
+			},
+		},
+	}
+	svc, memChain := newTestServiceWithNetwork(t, cfg, &network)
+	faucetAddr := types.NewAddressFromConsensus(svc.address)
+	memChain.SetRuntimeTokenBalance(&sapphire, faucetAddr, "FOO", testQuantity(t, "10000000"))
+	startBank(t, svc)
+
+	to := testAddress(t)
+	code, resp := bundle(t, svc, "starter", to.String())
+	if code != http.StatusOK {
+		t.Fatalf("bundle: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	recipient := types.NewAddressFromConsensus(to)
+	for _, tc := range []struct {
+		name     string
+		balance  quantity.Quantity
+		expected string
+	}{
+		{"recipient native", memChain.RuntimeBalance(&sapphire, recipient), "1000000000000000000"},
+		{"recipient token", memChain.RuntimeTokenBalance(&sapphire, recipient, "FOO"), "5000000"},
+		{"faucet token", memChain.RuntimeTokenBalance(&sapphire, faucetAddr, "FOO"), "5000000"},
+	} {
+		if expected := testQuantity(t, tc.expected); tc.balance.Cmp(&expected) != 0 {
+			t.Errorf("%s balance: got %v, expected %v", tc.name, tc.balance, expected)
+		}
+	}
+	var tokenPayout bool
+	for _, payout := range svc.requests.RecentPayouts() {
+		tokenPayout = tokenPayout || payout.Amount == "5.0 FOO"
+	}
+	if !tokenPayout {
+		t.Errorf("payouts: missing test token payout: %+v", svc.requests.RecentPayouts())
+	}
+
+	// Transfers exceeding the faucet's paratime balance fail.
+	code, resp = bundle(t, svc, "unfunded", testAddress(t).String())
+	if code != http.StatusOK {
+		t.Fatalf("bundle: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestFailed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestFailed)
+	}
+}
+
+func TestBundleValidation(t *testing.T) {
+	signer := testSigner(t)
+	network := config.DefaultNetworks.All["testnet"]
+
+	for _, tc := range []struct {
+		name  string
+		items []faucetConfig.BundleItemConfig
+		valid bool
+	}{
+		{"Consensus and Sapphire", []faucetConfig.BundleItemConfig{{ParaTime: "", Amount: "1"}, {ParaTime: "sapphire", Amount: "1"}}, true},
+		{"Emerald and Sapphire", []faucetConfig.BundleItemConfig{{ParaTime: "emerald", Amount: "1"}, {ParaTime: "sapphire", Amount: "1"}}, true},
+		{"Consensus and Emerald", []faucetConfig.BundleItemConfig{{ParaTime: "", Amount: "1"}, {ParaTime: "emerald", Amount: "1"}}, false},
+		{"Unknown paratime", []faucetConfig.BundleItemConfig{{ParaTime: "nonexistent", Amount: "1"}}, false},
+		{"Pontus-X token", []faucetConfig.BundleItemConfig{{ParaTime: "pontusx", Amount: "1"}, {ParaTime: "pontusx", Amount: "1", Denomination: "TEST"}}, true},
+		{"Unknown denomination", []faucetConfig.BundleItemConfig{{ParaTime: "sapphire", Amount: "1", Denomination: "FOO"}}, false},
+		{"Native denomination key", []faucetConfig.BundleItemConfig{{ParaTime: "sapphire", Amount: "1", Denomination: config.NativeDenominationKey}}, false},
+		{"Unparseable amount", []faucetConfig.BundleItemConfig{{ParaTime: "sapphire", Amount: "bogus"}}, false},
+		{"Amount below base unit", []faucetConfig.BundleItemConfig{{ParaTime: "", Amount: "0.0000000001"}}, false},
+		{"Consensus amount at maximum", []faucetConfig.BundleItemConfig{{ParaTime: "", Amount: "100"}}, true},
+		{"Consensus amount above maximum", []faucetConfig.BundleItemConfig{{ParaTime: "", Amount: "101"}}, false},
+		{"Paratime amount above maximum", []faucetConfig.BundleItemConfig{{ParaTime: "sapphire", Amount: "11"}}, false},
+		{"Token amount above maximum", []faucetConfig.BundleItemConfig{{ParaTime: "pontusx", Amount: "11", Denomination: "TEST"}}, true},
+	} {
+		cfg := &faucetConfig.Config{
+			MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+			MaxParatimeFundAmount:  "10",
+			Bundles: map[string]*faucetConfig.BundleConfig{
+				"starter": {Items: tc.items},
+			},
+		}
+		_, err := New(cfg, network, signer, WithMetrics(metrics.New(prometheus.NewRegistry())))
+		if valid := err == nil; valid != tc.valid {
+			t.Errorf("%s: got error %v, expected valid: %v", tc.name, err, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package faucet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	// retryAfterUnavailable is the retry hint for temporary failures.
+	retryAfterUnavailable = 10 * time.Second
+	// retryAfterPending is the retry hint for accounts with a request
+	// in-flight.
+	retryAfterPending = 10 * time.Second
+)
+
+// APIError is an error that is reported to API clients.
+type APIError struct {
+	// Status is the HTTP status code.
+	Status int
+	// Code is the machine readable error code.
+	Code string
+	// Field is the name of the request field that caused the error, if
+	// any.
+	Field string
+	// Message is the human readable error message.
+	Message string
+	// RetryAfter is how long the client should wait before retrying, if
+	// retrying may succeed.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// newAPIError creates a new API error with a formatted message.
+func newAPIError(status int, code, field, format string, args ...interface{}) *APIError {
+	return &APIError{
+		Status:  status,
+		Code:    code,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// errTemporaryFailure returns the error reported for transient failures.
+func errTemporaryFailure() *APIError {
+	err := newAPIError(http.StatusServiceUnavailable, api.ErrCodeUnavailable, "", "temporary failure, try again later")
+	err.RetryAfter = retryAfterUnavailable
+	return err
+}
+
+// errMethodNotAllowed returns the error reported for unsupported HTTP
+// methods.
+func errMethodNotAllowed(method string) *APIError {
+	return newAPIError(http.StatusMethodNotAllowed, api.ErrCodeMethodNotAllowed, "", "invalid http method: '%v'", method)
+}
+
+// errRequestPending returns the error reported for accounts with a request
+// in-flight.
+func errRequestPending() *APIError {
+	err := newAPIError(http.StatusConflict, api.ErrCodeRequestPending, queryAccount, "funding request already pending, try again later")
+	err.RetryAfter = retryAfterPending
+	return err
+}
+
+// writeError writes the JSON encoded response for an error.  Errors that
+// are not (or do not wrap) an APIError are reported as internal errors.
+// The message of the outermost error is reported to the client.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := &APIError{
+		Status: http.StatusInternalServerError,
+		Code:   api.ErrCodeInternal,
+	}
+	_ = errors.As(err, &apiErr)
+
+	resp := &api.Error{
+		Code:    apiErr.Code,
+		Field:   apiErr.Field,
+		Message: err.Error(),
+	}
+	if apiErr.RetryAfter > 0 {
+		resp.RetryAfter = uint64(math.Ceil(apiErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatUint(resp.RetryAfter, 10))
+	}
+
+	writeJSON(w, apiErr.Status, &api.FundResponse{
+		Result: resp.Message,
+		Error:  resp,
+	})
+}
@@ -0,0 +1,74 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+)
+
+var (
+	// errTxSubmitFailed is the error returned when a transaction could
+	// not be submitted.
+	errTxSubmitFailed = errors.New("failed to submit transaction")
+	// errTxNotIncluded is the error returned when a submitted transaction
+	// was not included in a block in time.
+	errTxNotIncluded = errors.New("failed to wait for transaction inclusion")
+)
+
+// totalFee returns the fee for the given gas limit and gas price.
+func totalFee(gas uint64, price *quantity.Quantity) *quantity.Quantity {
+	fee := price.Clone()
+	_ = fee.Mul(quantity.NewFromUint64(gas))
+	return fee
+}
+
+// shouldRetryTx returns true iff a transaction that failed with err on
+// the given attempt should be retried.
+func (svc *Service) shouldRetryTx(attempt int, err error) bool {
+	if attempt >= svc.cfg.Fees.MaxRetries {
+		return false
+	}
+	return errors.Is(err, errTxSubmitFailed) || errors.Is(err, errTxNotIncluded)
+}
+
+// consensusGasPrice returns the consensus gas price for the given attempt.
+//
+// Note: Unlike the paratimes, the consensus layer's minimum gas price is
+// local node configuration that can not be queried.
+func (svc *Service) consensusGasPrice(attempt int) *quantity.Quantity {
+	return svc.cfg.Fees.BumpPrice(&svc.cfg.Fees.ConsensusGasPrice, attempt)
+}
+
+// paraTimeGasPrice returns the paratime gas price for the given attempt.
+func (svc *Service) paraTimeGasPrice(
+	ctx context.Context,
+	backend chain.Backend,
+	pt *config.ParaTime,
+	attempt int,
+) (*quantity.Quantity, error) {
+	price := svc.cfg.Fees.ParaTimeGasPrices[svc.paratimeName(pt.ID)]
+
+	if svc.cfg.Fees.QueryMinGasPrice {
+		minGasPrices, err := backend.RuntimeMinGasPrice(ctx, pt)
+		if err != nil {
+			return nil, err
+		}
+		if minPrice, ok := minGasPrices[types.NativeDenomination]; ok && minPrice.Cmp(&price) > 0 {
+			price = minPrice
+		}
+	}
+
+	return svc.cfg.Fees.BumpPrice(&price, attempt), nil
+}
+
+// consensusTxFee returns the fee for a consensus transaction.
+func (svc *Service) consensusTxFee(gas consensusTx.Gas, attempt int) *quantity.Quantity {
+	return totalFee(uint64(gas), svc.consensusGasPrice(attempt))
+}
@@ -0,0 +1,48 @@
+package faucet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestFundParaTimeFees(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+		Fees: faucetConfig.FeeConfig{
+			QueryMinGasPrice: true,
+		},
+	}
+	svc, chain := newTestService(t, cfg)
+
+	pt := svc.network.ParaTimes.All["sapphire"]
+	faucet := types.NewAddressFromConsensus(svc.address)
+	chain.SetRuntimeMinGasPrice(pt, testQuantity(t, "100"))
+	chain.SetRuntimeBalance(pt, faucet, testQuantity(t, "1500000"))
+	startBank(t, svc)
+
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+	balance := chain.RuntimeBalance(pt, faucet)
+	if expected := testQuantity(t, "500000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("faucet paratime balance: got %v, expected %v", balance, expected)
+	}
+
+	// The remaining balance does not cover the fee of another request.
+	code, resp = fund(t, svc, "sapphire", testAccountSapphire, "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestFailed {
+		t.Fatalf("request state: got %v, expected %v", status.State, api.RequestFailed)
+	}
+}
@@ -0,0 +1,470 @@
+package faucet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	ethCommon "github.com/ethereum/go-ethereum/common"
+	"google.golang.org/grpc"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/clientip"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+const (
+	queryParaTime          = api.QueryParaTime
+	queryAccount           = api.QueryAccount
+	queryAmount            = api.QueryAmount
+	queryRecaptchaResponse = "g-recaptcha-response"
+)
+
+func (svc *Service) TestAndSetAddress(addr *types.Address) bool {
+	svc.dedupLock.Lock()
+	defer svc.dedupLock.Unlock()
+
+	addrStr := addr.String()
+
+	ret := svc.dedupMap[addrStr]
+	svc.dedupMap[addrStr] = true
+	return ret
+}
+
+func (svc *Service) ClearAddress(addr *types.Address) {
+	svc.dedupLock.Lock()
+	defer svc.dedupLock.Unlock()
+
+	svc.dedupMap[addr.String()] = false
+}
+
+// Handler returns the HTTP handler serving the public API, and the static
+// site if a webroot is configured or the frontend is embedded.
+func (svc *Service) Handler() http.Handler {
+	return svc.HandlerFor([]string{faucetConfig.RouteAPI, faucetConfig.RouteStatic})
+}
+
+// registerAPIHandlers registers the public API endpoints.
+func (svc *Service) registerAPIHandlers(mux *http.ServeMux) {
+	mux.HandleFunc(api.PathFundV1, svc.rateLimited(svc.OnFundRequest))
+	mux.HandleFunc(api.PathBundleV1, svc.rateLimited(svc.OnBundleRequest))
+	mux.HandleFunc(api.PathStatusV1, svc.OnStatusRequest)
+	mux.HandleFunc(api.PathStatusStreamV1, svc.OnStatusStream)
+	mux.HandleFunc(api.PathPayoutsV1, svc.OnPayoutsRequest)
+	mux.HandleFunc(api.PathBalanceV1, svc.OnBalanceRequest)
+	svc.registerV2Handlers(mux)
+}
+
+// FrontendWorker serves the HTTP listeners and the gRPC API, until the
+// process is interrupted or the service is stopped.  The listeners serving
+// the public API, and the gRPC API, are only started once the bank is
+// ready.
+func (svc *Service) FrontendWorker() {
+	defer func() {
+		close(svc.doneCh)
+	}()
+
+	svc.log.Printf("frontend: started")
+
+	var (
+		listeners []*httpListener
+		grpcSrv   *grpc.Server
+		redirect  *http.Server
+	)
+	errCh := make(chan error, len(svc.cfg.HTTPListeners()))
+	shutdown := func() {
+		for _, hl := range listeners {
+			svc.shutdownHTTPServer(hl.srv)
+		}
+		if grpcSrv != nil {
+			svc.stopGRPCServer(grpcSrv)
+		}
+		if redirect != nil {
+			svc.shutdownHTTPServer(redirect)
+		}
+	}
+	start := func(public bool) bool {
+		cfgs := svc.cfg.HTTPListeners()
+		for i := range cfgs {
+			l := &cfgs[i]
+			if l.HasRoute(faucetConfig.RouteAPI) != public {
+				continue
+			}
+			hl, err := svc.newHTTPListener(l)
+			if err != nil {
+				svc.log.Printf("frontend: failed to start HTTP listener '%v': %v", l.Addr, err)
+				shutdown()
+				return false
+			}
+			listeners = append(listeners, hl)
+			go hl.serve(svc, errCh)
+		}
+		return true
+	}
+	if svc.cfg.Access.AdminToken != "" && !svc.hasAdminListener() {
+		svc.log.Printf("frontend: admin token configured, but no listener serves the admin routes")
+	}
+
+	if !start(false) {
+		return
+	}
+
+	// Wait till the part that does the actual heavy lifting is initialized.
+	<-svc.readyCh
+
+	svc.log.Printf("frontend: bank ready, starting HTTP server")
+
+	var err error
+	if grpcSrv, err = svc.startGRPCServer(); err != nil {
+		svc.log.Printf("frontend: failed to start gRPC server: %v", err)
+		shutdown()
+		return
+	}
+	if redirect, err = svc.startRedirectServer(); err != nil {
+		svc.log.Printf("frontend: failed to start redirect server: %v", err)
+		shutdown()
+		return
+	}
+	if !start(true) {
+		return
+	}
+
+	// Serve.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	select {
+	case <-sigCh:
+		svc.log.Printf("frontend: user requested termination")
+	case <-svc.stopCh:
+		svc.log.Printf("frontend: service stopped")
+	case err = <-errCh:
+		svc.log.Printf("frontend: %v", err)
+	}
+
+	// Ensure the event streams end, and wait till all pending requests
+	// have been serviced.
+	svc.Stop()
+	shutdown()
+	close(svc.quitCh)
+}
+
+// hasAdminListener returns true iff a listener serves the admin routes.
+func (svc *Service) hasAdminListener() bool {
+	for _, l := range svc.cfg.HTTPListeners() {
+		if l.HasRoute(faucetConfig.RouteAdmin) {
+			return true
+		}
+	}
+	return false
+}
+
+// listen listens on the TCP address, accepting PROXY protocol headers from
+// trusted proxies if enabled.
+func (svc *Service) listen(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if svc.cfg.Proxy.ProxyProtocol {
+		ln = clientip.NewProxyListener(ln, svc.clients)
+	}
+	return ln, nil
+}
+
+// writeJSON writes a JSON encoded response.
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	b, _ := json.Marshal(v)
+	_, _ = w.Write(b)
+}
+
+// parseAccount validates the user supplied paratime and account, and
+// returns the paratime (nil for consensus) and the resolved account.  The
+// returned error is suitable for displaying to the user.
+func (svc *Service) parseAccount(paraTimeStr, accountStr string) (*config.ParaTime, *types.Address, *ethCommon.Address, error) {
+	var paraTime *config.ParaTime
+	if paraTimeStr != "" {
+		// Paratime account
+		paraTime = svc.network.ParaTimes.All[paraTimeStr]
+		if paraTime == nil {
+			svc.log.Printf("frontend: invalid paratime: '%v'", paraTimeStr)
+			return nil, nil, nil, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidParaTime, queryParaTime, "invalid paratime: '%v'", paraTimeStr)
+		}
+	}
+
+	account, ethAccount, err := svc.parseAddress(paraTime, accountStr)
+	if err != nil {
+		svc.log.Printf("frontend: invalid account '%v': %v", accountStr, err)
+		return nil, nil, nil, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidAccount, queryAccount, "invalid account: %v", err)
+	}
+
+	return paraTime, account, ethAccount, nil
+}
+
+// parseFundRequest validates the user supplied paratime, account and
+// amount, and returns the corresponding funding request.  Paratime amounts
+// are in the given denomination, and only the native denomination is
+// subject to the per-paratime maximum.  The returned error is suitable
+// for displaying to the user.
+func (svc *Service) parseFundRequest(paraTimeStr, accountStr, amountStr string, denomination types.Denomination) (*FundRequest, error) {
+	var (
+		err     error
+		fundReq FundRequest
+	)
+
+	// ParaTime/Account
+	if fundReq.ParaTime, fundReq.Account, fundReq.EthAccount, err = svc.parseAccount(paraTimeStr, accountStr); err != nil {
+		return nil, fmt.Errorf("failed to fund account: %w", err)
+	}
+
+	// Amount
+	switch fundReq.ParaTime {
+	case nil:
+		if fundReq.ConsensusAmount, err = helpers.ParseConsensusDenomination(
+			svc.network,
+			amountStr,
+		); err != nil {
+			svc.log.Printf("frontend: invalid amount '%v': %v", amountStr, err)
+			return nil, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidAmount, queryAmount, "failed to fund account: invalid amount: '%v'", amountStr)
+		}
+		if !svc.cfg.MaxConsensusFundAmount.IsZero() {
+			max := svc.cfg.MaxConsensusFundAmount.Clone()
+			if err = max.Sub(fundReq.ConsensusAmount); err != nil {
+				svc.log.Printf("frontend: excessive consensus amount: %v", fundReq.ConsensusAmount)
+				return nil, newAPIError(http.StatusBadRequest, api.ErrCodeAmountTooLarge, queryAmount, "failed to fund account: excessive consensus amount: '%v'", amountStr)
+			}
+		}
+	default:
+		if fundReq.ParaTimeAmount, err = helpers.ParseParaTimeDenomination(
+			fundReq.ParaTime,
+			amountStr,
+			denomination,
+		); err != nil {
+			svc.log.Printf("frontend: invalid amount '%v': %v", amountStr, err)
+			return nil, newAPIError(http.StatusBadRequest, api.ErrCodeInvalidAmount, queryAmount, "failed to fund account: invalid amount: '%v'", amountStr)
+		}
+		if maxStr := svc.cfg.MaxParatimeFundAmount; maxStr != "" && !fundReq.isToken() {
+			max, err := helpers.ParseParaTimeDenomination(
+				fundReq.ParaTime,
+				maxStr,
+				types.NativeDenomination,
+			)
+			if err != nil {
+				svc.log.Printf("frontend: invalid maximum amount '%v': %v", maxStr, err)
+				return nil, fmt.Errorf("failed to fund account: per-paratime max misconfigured")
+			}
+			if err = max.Amount.Sub(&fundReq.ParaTimeAmount.Amount); err != nil {
+				svc.log.Printf("frontend: excessive paratime amount: %v", fundReq.ParaTimeAmount)
+				return nil, newAPIError(http.StatusBadRequest, api.ErrCodeAmountTooLarge, queryAmount, "failed to fund account: excessive paratime amount: '%v'", amountStr)
+			}
+		}
+	}
+
+	return &fundReq, nil
+}
+
+// fundItem is the user supplied paratime and amount of one of the funding
+// requests of a submission.
+type fundItem struct {
+	paraTime string
+	// amount is the amount in tokens, or empty for the default.
+	amount string
+	// denomination is the paratime denomination of the amount.
+	denomination types.Denomination
+}
+
+// admission is the outcome of admitting the funding requests of a
+// submission.
+type admission struct {
+	requests []*FundRequest
+	decision access.Decision
+
+	// amountReasons are the reasons any of the amounts were reduced.
+	amountReasons []string
+}
+
+// amountReason returns the reasons the amounts were reduced, if any.
+func (a *admission) amountReason() string {
+	return strings.Join(a.amountReasons, "; ")
+}
+
+// admitFundRequests validates the funding requests of a submission to a
+// single account, which is either a plain funding request, or the items of
+// a bundle.  The client is checked against the access lists and reCAPTCHA
+// once, the amounts are checked and adjusted by the policies one by one,
+// and the account is then marked as having a request in-flight.  The
+// returned error is suitable for displaying to the user.
+func (svc *Service) admitFundRequests(ctx context.Context, accountStr, captchaResponse string, items []fundItem) (*admission, error) {
+	adm := &admission{
+		requests: make([]*FundRequest, 0, len(items)),
+	}
+	for _, item := range items {
+		amountStr := item.amount
+		defaultAmount := amountStr == ""
+		if defaultAmount {
+			if amountStr = svc.cfg.Amounts.ForParaTime(item.paraTime).Default; amountStr == "" {
+				return nil, newAPIError(
+					http.StatusBadRequest,
+					api.ErrCodeMissingAmount,
+					queryAmount,
+					"failed to fund account: missing amount",
+				)
+			}
+		}
+
+		fundReq, err := svc.parseFundRequest(item.paraTime, accountStr, amountStr, item.denomination)
+		if err != nil {
+			return nil, err
+		}
+		fundReq.defaultAmount = defaultAmount
+		if fundReq.isToken() {
+			adm.requests = append(adm.requests, fundReq)
+			continue
+		}
+		if err = svc.checkMinAmount(item.paraTime, fundReq); err != nil {
+			return nil, err
+		}
+		adm.requests = append(adm.requests, fundReq)
+	}
+	account := adm.requests[0].Account
+
+	var err error
+	if adm.decision, err = svc.checkAccess(ctx, account); err != nil {
+		return nil, err
+	}
+
+	// Handle reCAPTCHA integration, if enabled, unless the client is
+	// allowlisted.
+	if err = svc.verifyCaptcha(ctx, captchaResponse, adm.decision); err != nil {
+		return nil, err
+	}
+
+	for _, fundReq := range adm.requests {
+		if fundReq.isToken() {
+			continue
+		}
+
+		// Reduce the amount if the faucet is running low.
+		amountReason, err := svc.ApplyReservePolicy(ctx, fundReq)
+		if err != nil {
+			return nil, err
+		}
+
+		// Reduce or refuse funding for accounts that already have plenty.
+		balanceReason, err := svc.ApplyBalancePolicy(ctx, fundReq)
+		if err != nil {
+			return nil, err
+		}
+		if balanceReason != "" {
+			amountReason = balanceReason
+		}
+		if amountReason != "" {
+			adm.amountReasons = append(adm.amountReasons, amountReason)
+		}
+	}
+
+	// Ensure the address does not have a request in-flight already.
+	if svc.TestAndSetAddress(account) {
+		// User is being a greedy asshole, fail.
+		return nil, errRequestPending()
+	}
+
+	return adm, nil
+}
+
+// SubmitFundRequest validates and enqueues a funding request.  The
+// returned error is suitable for displaying to the user.
+func (svc *Service) SubmitFundRequest(ctx context.Context, params *api.FundParams) (*api.FundResponse, error) {
+	paraTimeStr := strings.TrimSpace(params.ParaTime)
+	accountStr := strings.TrimSpace(params.Account)
+
+	adm, err := svc.admitFundRequests(ctx, accountStr, params.CaptchaResponse, []fundItem{
+		{
+			paraTime:     paraTimeStr,
+			amount:       strings.TrimSpace(params.Amount),
+			denomination: types.NativeDenomination,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	fundReq := adm.requests[0]
+
+	// Attempt to fund the address.
+	fundReq.ID = svc.requests.New()
+	select {
+	case svc.fundRequestCh <- fundReq:
+	default:
+		// Queue backlog full, fail early.
+		err = errTemporaryFailure()
+		svc.requests.Fail(fundReq.ID, err)
+		svc.ClearAddress(fundReq.Account)
+		return nil, err
+	}
+
+	svc.log.Printf("frontend: request enqueued: %v: [%v]%v: %v: client: %v", fundReq.ID, paraTimeStr, accountStr, svc.formatAmount(fundReq), svc.clients.Display(clientInfoFrom(ctx).IP))
+
+	return &api.FundResponse{
+		Result:       "funding request submitted",
+		RequestID:    fundReq.ID,
+		DryRun:       svc.cfg.DryRun,
+		Amount:       svc.formatAmount(fundReq),
+		AmountReason: adm.amountReason(),
+	}, nil
+}
+
+// OnFundRequest handles a funding request.  The expected request is a POST
+// of the form `https://host:port/api/v1/fund?account=ACCOUNT&amount=TOKENS`,
+// or the equivalent POST form.  The amount may be omitted if a default is
+// configured.
+func (svc *Service) OnFundRequest(w http.ResponseWriter, req *http.Request) {
+	// Ensure the user is POSTing, if auth is enabled.
+	authEnabled := svc.captcha != nil
+	if authEnabled {
+		if req.Method != http.MethodPost {
+			svc.log.Printf("frontend: invalid http method: '%v'", req.Method)
+			writeError(w, errMethodNotAllowed(req.Method))
+			return
+		}
+	}
+
+	// Parse the query and POST form (combined).
+	if err := req.ParseForm(); err != nil {
+		svc.log.Printf("frontend: invalid http request: %v", err)
+		writeError(w, newAPIError(
+			http.StatusBadRequest,
+			api.ErrCodeInvalidRequest,
+			"",
+			"invalid http request, failed to parse query/form",
+		))
+		return
+	}
+
+	// Technically the reCAPTCHA response is not a query, but the server
+	// has a unified view of POST form and query fields.
+	ctx := withClientInfo(req.Context(), svc.clientInfoOf(req))
+	resp, err := svc.SubmitFundRequest(ctx, &api.FundParams{
+		ParaTime:        req.Form.Get(queryParaTime),
+		Account:         req.Form.Get(queryAccount),
+		Amount:          req.Form.Get(queryAmount),
+		CaptchaResponse: req.Form.Get(queryRecaptchaResponse),
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,121 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/oasisprotocol/oasis-core/go/common/encoding/bech32"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestFundInvalidRequest(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+	})
+	startBank(t, svc)
+
+	to := testAddress(t)
+	foreign, err := bech32.Encode("cosmos", to[:])
+	if err != nil {
+		t.Fatalf("failed to encode address: %v", err)
+	}
+	runtime := staking.NewRuntimeAddress(svc.network.ParaTimes.All["sapphire"].Namespace())
+	for _, tc := range []struct {
+		name     string
+		paraTime string
+		account  string
+		amount   string
+		code     string
+		field    string
+	}{
+		{"UnknownParaTime", "bogus", to.String(), "1", api.ErrCodeInvalidParaTime, queryParaTime},
+		{"ParaTimeAddressForConsensus", "", testAccountSapphire, "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"InvalidAccount", "", "oasis1bogus", "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"ForeignAccount", "", foreign, "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"FaucetAccount", "", svc.address.String(), "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"RuntimeAccount", "", runtime.String(), "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"EthAccountChecksum", "sapphire", strings.Replace(testAccountSapphire, "adE", "ade", 1), "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"EthAccountLength", "sapphire", testAccountSapphire[:40], "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"EthAccountNonEVM", "cipher", testAccountSapphire, "1", api.ErrCodeInvalidAccount, queryAccount},
+		{"InvalidAmount", "", to.String(), "lots", api.ErrCodeInvalidAmount, queryAmount},
+		{"ExcessiveAmount", "", to.String(), "101", api.ErrCodeAmountTooLarge, queryAmount},
+		{"MissingAmount", "", to.String(), "", api.ErrCodeMissingAmount, queryAmount},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			code, resp := fund(t, svc, tc.paraTime, tc.account, tc.amount)
+			if code != http.StatusBadRequest || resp.RequestID != "" {
+				t.Fatalf("invalid request accepted: %d %+v", code, resp)
+			}
+			if resp.Error == nil || resp.Error.Code != tc.code || resp.Error.Field != tc.field || resp.Error.Message != resp.Result {
+				t.Fatalf("unexpected error: %+v", resp.Error)
+			}
+		})
+	}
+}
+
+func TestFundPending(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{})
+
+	// The bank is not running, so the request remains pending.
+	to := testAddress(t)
+	if code, resp := fund(t, svc, "", to.String(), "1"); code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+
+	form := url.Values{
+		queryAccount: {to.String()},
+		queryAmount:  {"1"},
+	}
+	var resp api.FundResponse
+	w := serveJSON(t, http.HandlerFunc(svc.OnFundRequest), newFormRequest("/api/v1/fund", form), &resp)
+	if w.Code != http.StatusConflict || resp.Error == nil || resp.Error.Code != api.ErrCodeRequestPending {
+		t.Fatalf("pending request: unexpected response %d: %+v", w.Code, resp.Error)
+	}
+	if resp.Error.RetryAfter == 0 || w.Header().Get("Retry-After") == "" {
+		t.Errorf("pending request: missing retry hint")
+	}
+}
+
+func TestCaptcha(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+	})
+	svc.captcha = testCaptchaVerifier("valid")
+	startBank(t, svc)
+
+	for _, tc := range []struct {
+		name     string
+		response string
+		code     int
+	}{
+		{"Missing", "", http.StatusForbidden},
+		{"Invalid", "invalid", http.StatusForbidden},
+		{"Valid", "valid", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := svc.SubmitFundRequest(context.Background(), &api.FundParams{
+				Account:         testAddress(t).String(),
+				Amount:          "1",
+				CaptchaResponse: tc.response,
+			})
+			if tc.code == http.StatusOK {
+				if err != nil {
+					t.Fatalf("fund: unexpected error: %v", err)
+				}
+				waitForRequest(t, svc, resp.RequestID)
+				return
+			}
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.Status != tc.code || apiErr.Code != api.ErrCodeCaptchaFailed {
+				t.Fatalf("fund: unexpected error: %v", err)
+			}
+		})
+	}
+}
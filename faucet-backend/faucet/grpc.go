@@ -0,0 +1,265 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/faucetpb"
+)
+
+// grpcErrorDomain is the ErrorInfo domain of the gRPC API errors.
+const grpcErrorDomain = "oasis.faucet.v1"
+
+var grpcRequestStates = map[api.RequestState]faucetpb.RequestState{
+	api.RequestQueued:    faucetpb.RequestState_REQUEST_STATE_QUEUED,
+	api.RequestSigned:    faucetpb.RequestState_REQUEST_STATE_SIGNED,
+	api.RequestSubmitted: faucetpb.RequestState_REQUEST_STATE_SUBMITTED,
+	api.RequestIncluded:  faucetpb.RequestState_REQUEST_STATE_INCLUDED,
+	api.RequestConfirmed: faucetpb.RequestState_REQUEST_STATE_CONFIRMED,
+	api.RequestFailed:    faucetpb.RequestState_REQUEST_STATE_FAILED,
+}
+
+// grpcServer implements the faucet gRPC API on top of the same validation
+// and enqueue path as the HTTP API.
+type grpcServer struct {
+	faucetpb.UnimplementedFaucetServer
+
+	svc *Service
+}
+
+// grpcError converts an error to a gRPC status error, mapping the HTTP
+// status of API errors to the closest canonical code.
+func grpcError(err error) error {
+	apiErr := &APIError{
+		Status: http.StatusInternalServerError,
+		Code:   api.ErrCodeInternal,
+	}
+	_ = errors.As(err, &apiErr)
+
+	var code codes.Code
+	switch apiErr.Status {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	default:
+		code = codes.Internal
+	}
+
+	errorInfo := &errdetails.ErrorInfo{
+		Reason: apiErr.Code,
+		Domain: grpcErrorDomain,
+	}
+	if apiErr.Field != "" {
+		errorInfo.Metadata = map[string]string{
+			"field": apiErr.Field,
+		}
+	}
+	st, _ := status.New(code, err.Error()).WithDetails(errorInfo)
+	if apiErr.RetryAfter > 0 {
+		st, _ = st.WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(apiErr.RetryAfter),
+		})
+	}
+	return st.Err()
+}
+
+func requestStatusToProto(s *api.RequestStatus) *faucetpb.RequestStatus {
+	return &faucetpb.RequestStatus{
+		Id:       s.ID,
+		State:    grpcRequestStates[s.State],
+		Reason:   s.Reason,
+		TxHash:   s.TxHash,
+		Height:   s.Height,
+		Round:    s.Round,
+		DryRun:   s.DryRun,
+		SignedTx: s.SignedTx,
+		Updated:  timestamppb.New(s.Updated),
+	}
+}
+
+func fundingInfoToProto(info *api.FundingInfo) *faucetpb.FundingInfo {
+	return &faucetpb.FundingInfo{
+		Symbol:        info.Symbol,
+		Decimals:      uint32(info.Decimals),
+		DefaultAmount: info.DefaultAmount,
+		MinAmount:     info.MinAmount,
+		MaxAmount:     info.MaxAmount,
+		PayoutFactor:  info.PayoutFactor,
+	}
+}
+
+func (s *grpcServer) Fund(ctx context.Context, req *faucetpb.FundRequest) (*faucetpb.FundResponse, error) {
+	client := s.svc.grpcClientInfo(ctx)
+	if err := s.svc.checkRateLimit(client); err != nil {
+		return nil, grpcError(err)
+	}
+	resp, err := s.svc.SubmitFundRequest(withClientInfo(ctx, client), &api.FundParams{
+		ParaTime:        req.GetParatime(),
+		Account:         req.GetAccount(),
+		Amount:          req.GetAmount(),
+		CaptchaResponse: req.GetCaptchaResponse(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &faucetpb.FundResponse{
+		RequestId:    resp.RequestID,
+		Amount:       resp.Amount,
+		AmountReason: resp.AmountReason,
+		DryRun:       resp.DryRun,
+	}, nil
+}
+
+func (s *grpcServer) FundBundle(ctx context.Context, req *faucetpb.FundBundleRequest) (*faucetpb.FundResponse, error) {
+	client := s.svc.grpcClientInfo(ctx)
+	if err := s.svc.checkRateLimit(client); err != nil {
+		return nil, grpcError(err)
+	}
+	resp, err := s.svc.SubmitBundleRequest(withClientInfo(ctx, client), &api.BundleParams{
+		Bundle:          req.GetBundle(),
+		Account:         req.GetAccount(),
+		CaptchaResponse: req.GetCaptchaResponse(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &faucetpb.FundResponse{
+		RequestId:    resp.RequestID,
+		AmountReason: resp.AmountReason,
+		DryRun:       resp.DryRun,
+	}, nil
+}
+
+func (s *grpcServer) GetRequest(ctx context.Context, req *faucetpb.GetRequestRequest) (*faucetpb.RequestStatus, error) {
+	st, ok := s.svc.requests.Get(req.GetId())
+	if !ok {
+		return nil, grpcError(newAPIError(http.StatusNotFound, api.ErrCodeNotFound, queryRequestID, "unknown request id: '%v'", req.GetId()))
+	}
+	return requestStatusToProto(&st), nil
+}
+
+func (s *grpcServer) WatchRequest(req *faucetpb.WatchRequestRequest, stream faucetpb.Faucet_WatchRequestServer) error {
+	id := req.GetId()
+	ch, unsubscribeFn, ok := s.svc.requests.Subscribe(id)
+	if !ok {
+		return grpcError(newAPIError(http.StatusNotFound, api.ErrCodeNotFound, queryRequestID, "unknown request id: '%v'", id))
+	}
+	defer unsubscribeFn()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case st, ok := <-ch:
+			if !ok {
+				// Updates may have been dropped, so always send the
+				// final status.
+				if st, ok = s.svc.requests.Get(id); ok {
+					return stream.Send(requestStatusToProto(&st))
+				}
+				return nil
+			}
+			if err := stream.Send(requestStatusToProto(&st)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *grpcServer) Info(ctx context.Context, req *faucetpb.InfoRequest) (*faucetpb.InfoResponse, error) {
+	info, err := s.svc.Info(ctx)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &faucetpb.InfoResponse{
+		ChainContext:    info.ChainContext,
+		Address:         info.Address,
+		DryRun:          info.DryRun,
+		CaptchaRequired: info.CaptchaRequired,
+		Consensus:       fundingInfoToProto(info.Consensus),
+		Paratimes:       make(map[string]*faucetpb.FundingInfo),
+		Bundles:         info.Bundles,
+	}
+	for name, ptInfo := range info.ParaTimes {
+		resp.Paratimes[name] = fundingInfoToProto(ptInfo)
+	}
+	return resp, nil
+}
+
+// newGRPCServer creates the gRPC server, using the same TLS certificate as
+// the HTTP server if configured.
+func (svc *Service) newGRPCServer() (*grpc.Server, error) {
+	var opts []grpc.ServerOption
+	if tlsCfg := svc.serverTLSConfig(false); tlsCfg != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+
+	srv := grpc.NewServer(opts...)
+	faucetpb.RegisterFaucetServer(srv, &grpcServer{
+		svc: svc,
+	})
+	return srv, nil
+}
+
+// startGRPCServer starts serving the gRPC API, if configured.
+func (svc *Service) startGRPCServer() (*grpc.Server, error) {
+	if svc.cfg.GRPCListenAddr == "" {
+		return nil, nil
+	}
+
+	srv, err := svc.newGRPCServer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC server: %w", err)
+	}
+	ln, err := svc.listen(svc.cfg.GRPCListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			svc.log.Printf("frontend/grpc: failed to serve: %v", err)
+		}
+	}()
+	svc.log.Printf("frontend/grpc: serving gRPC API on %v", ln.Addr())
+
+	return srv, nil
+}
+
+// stopGRPCServer gracefully stops the gRPC server, stopping it forcibly
+// if calls are still pending after the shutdown timeout.
+func (svc *Service) stopGRPCServer(srv *grpc.Server) {
+	stoppedCh := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stoppedCh)
+	}()
+
+	select {
+	case <-stoppedCh:
+	case <-time.After(shutdownTimeout):
+		svc.log.Printf("frontend: failed graceful gRPC server shutdown: timed out")
+		srv.Stop()
+	}
+}
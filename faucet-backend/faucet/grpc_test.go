@@ -0,0 +1,141 @@
+package faucet
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/faucetpb"
+)
+
+func TestGRPC(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{Amount: "1"},
+				},
+			},
+		},
+	})
+	startBank(t, svc)
+
+	srv, err := svc.newGRPCServer()
+	if err != nil {
+		t.Fatalf("failed to create gRPC server: %v", err)
+	}
+	ln := bufconn.Listen(1024 * 1024)
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+	client := faucetpb.NewFaucetClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), testRequestTimeout)
+	defer cancel()
+
+	resp, err := client.Fund(ctx, &faucetpb.FundRequest{
+		Account: testAddress(t).String(),
+		Amount:  "10",
+	})
+	if err != nil {
+		t.Fatalf("Fund: %v", err)
+	}
+	if resp.GetRequestId() == "" || resp.GetAmount() != "10.0 TEST" {
+		t.Fatalf("Fund: unexpected response: %v", resp)
+	}
+
+	stream, err := client.WatchRequest(ctx, &faucetpb.WatchRequestRequest{Id: resp.GetRequestId()})
+	if err != nil {
+		t.Fatalf("WatchRequest: %v", err)
+	}
+	var last *faucetpb.RequestStatus
+	for {
+		st, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("WatchRequest: %v", err)
+		}
+		last = st
+	}
+	if last.GetState() != faucetpb.RequestState_REQUEST_STATE_CONFIRMED {
+		t.Fatalf("WatchRequest: unexpected final status: %v", last)
+	}
+
+	st, err := client.GetRequest(ctx, &faucetpb.GetRequestRequest{Id: resp.GetRequestId()})
+	if err != nil || st.GetTxHash() != last.GetTxHash() {
+		t.Fatalf("GetRequest: unexpected response: %v (%v)", st, err)
+	}
+
+	info, err := client.Info(ctx, &faucetpb.InfoRequest{})
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.GetConsensus().GetMaxAmount() != "100.0 TEST" || info.GetParatimes()["sapphire"] == nil {
+		t.Errorf("Info: unexpected response: %v", info)
+	}
+
+	_, err = client.Fund(ctx, &faucetpb.FundRequest{
+		Account: testAddress(t).String(),
+		Amount:  "101",
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Fund excessive amount: unexpected error: %v", err)
+	}
+	var reason string
+	for _, detail := range status.Convert(err).Details() {
+		if errorInfo, ok := detail.(*errdetails.ErrorInfo); ok {
+			reason = errorInfo.GetReason()
+		}
+	}
+	if reason != api.ErrCodeAmountTooLarge {
+		t.Errorf("Fund excessive amount: unexpected error reason: '%v'", reason)
+	}
+
+	bundleResp, err := client.FundBundle(ctx, &faucetpb.FundBundleRequest{
+		Bundle:  "starter",
+		Account: testAddress(t).String(),
+	})
+	if err != nil || bundleResp.GetRequestId() == "" {
+		t.Fatalf("FundBundle: unexpected response: %v (%v)", bundleResp, err)
+	}
+	if st := waitForRequest(t, svc, bundleResp.GetRequestId()); st.State != api.RequestConfirmed {
+		t.Fatalf("FundBundle request state: got %v (%v), expected %v", st.State, st.Reason, api.RequestConfirmed)
+	}
+	_, err = client.FundBundle(ctx, &faucetpb.FundBundleRequest{
+		Bundle:  "bogus",
+		Account: testAddress(t).String(),
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("FundBundle unknown: unexpected error: %v", err)
+	}
+
+	if _, err = client.GetRequest(ctx, &faucetpb.GetRequestRequest{Id: "bogus"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetRequest unknown: unexpected error: %v", err)
+	}
+}
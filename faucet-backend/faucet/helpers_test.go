@@ -0,0 +1,208 @@
+package faucet
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+const (
+	testAccountSapphire = "0x90adE3B7065fa715c7a150313877dF1d33e777D5"
+
+	testRequestTimeout = 10 * time.Second
+)
+
+func testQuantity(t *testing.T, s string) quantity.Quantity {
+	t.Helper()
+
+	var q quantity.Quantity
+	if err := q.UnmarshalText([]byte(s)); err != nil {
+		t.Fatalf("failed to parse quantity '%s': %v", s, err)
+	}
+	return q
+}
+
+// testSigner generates a new in-memory entity signer.
+func testSigner(t *testing.T) signature.Signer {
+	t.Helper()
+
+	signer, err := memorySigner.NewFactory().Generate(signature.SignerEntity, rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate signer: %v", err)
+	}
+	return signer
+}
+
+func testAddress(t *testing.T) staking.Address {
+	t.Helper()
+
+	return staking.NewAddress(testSigner(t).Public())
+}
+
+// newTestService creates a service backed by a memory chain, with a
+// faucet account holding 1,000,000 TEST.
+func newTestService(t *testing.T, cfg *faucetConfig.Config) (*Service, *chain.MemoryChain) {
+	t.Helper()
+
+	// The bank updates the network's chain context, so use a copy.
+	network := *config.DefaultNetworks.All["testnet"]
+	return newTestServiceWithNetwork(t, cfg, &network)
+}
+
+// newTestServiceWithNetwork is newTestService for the given network.
+func newTestServiceWithNetwork(t *testing.T, cfg *faucetConfig.Config, network *config.Network) (*Service, *chain.MemoryChain) {
+	t.Helper()
+
+	memChain := chain.NewMemoryChain(network)
+	svc, err := New(
+		cfg,
+		network,
+		testSigner(t),
+		WithLogger(log.New(io.Discard, "", log.LstdFlags)),
+		WithMetrics(metrics.New(prometheus.NewRegistry())),
+		WithChainBackend(memChain),
+	)
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	memChain.SetConsensusBalance(svc.address, testQuantity(t, "1000000000000000"))
+
+	return svc, memChain
+}
+
+// startBank starts the bank, and waits for it to be ready.
+func startBank(t *testing.T, svc *Service) {
+	t.Helper()
+
+	go svc.BankWorker()
+	t.Cleanup(func() {
+		close(svc.quitCh)
+	})
+
+	select {
+	case <-svc.readyCh:
+	case <-time.After(testRequestTimeout):
+		t.Fatalf("bank failed to become ready")
+	}
+}
+
+// newFormRequest creates a form encoded POST request.
+func newFormRequest(path string, form url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// serveJSON serves the request with the handler, and decodes the JSON
+// response into v, unless v is nil.
+func serveJSON(t *testing.T, handler http.Handler, req *http.Request, v interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%s %s: failed to decode response: %v", req.Method, req.URL.Path, err)
+		}
+	}
+	return w
+}
+
+// fund submits a funding request via the frontend handler.
+func fund(t *testing.T, svc *Service, paraTime, account, amount string) (int, *api.FundResponse) {
+	t.Helper()
+
+	form := url.Values{
+		queryParaTime: {paraTime},
+		queryAccount:  {account},
+		queryAmount:   {amount},
+	}
+	var resp api.FundResponse
+	w := serveJSON(t, http.HandlerFunc(svc.OnFundRequest), newFormRequest("/api/v1/fund", form), &resp)
+	return w.Code, &resp
+}
+
+// waitForRequest waits for a request to reach a terminal state.
+func waitForRequest(t *testing.T, svc *Service, id string) api.RequestStatus {
+	t.Helper()
+
+	ch, unsubscribeFn, ok := svc.requests.Subscribe(id)
+	if !ok {
+		t.Fatalf("unknown request id: %v", id)
+	}
+	defer unsubscribeFn()
+
+	timeout := time.After(testRequestTimeout)
+	for {
+		select {
+		case <-timeout:
+			t.Fatalf("request %v timed out", id)
+		case _, ok := <-ch:
+			if !ok {
+				status, _ := svc.requests.Get(id)
+				return status
+			}
+		}
+	}
+}
+
+// bundle submits a bundle funding request via the frontend handler.
+func bundle(t *testing.T, svc *Service, name, account string) (int, *api.FundResponse) {
+	t.Helper()
+
+	form := url.Values{
+		queryBundle:  {name},
+		queryAccount: {account},
+	}
+	var resp api.FundResponse
+	w := serveJSON(t, http.HandlerFunc(svc.OnBundleRequest), newFormRequest("/api/v1/bundle", form), &resp)
+	return w.Code, &resp
+}
+
+// queryBalance queries a balance via the frontend handler.
+func queryBalance(t *testing.T, svc *Service, paraTime, account string) (int, *api.BalanceResponse) {
+	t.Helper()
+
+	query := url.Values{
+		queryParaTime: {paraTime},
+		queryAccount:  {account},
+	}
+	var resp api.BalanceResponse
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/balance?"+query.Encode(), nil)
+	w := serveJSON(t, http.HandlerFunc(svc.OnBalanceRequest), req, &resp)
+	return w.Code, &resp
+}
+
+// testCaptchaVerifier accepts a single CAPTCHA response.
+type testCaptchaVerifier string
+
+func (v testCaptchaVerifier) Verify(ctx context.Context, userResponse string, remoteIP net.IP) error {
+	if userResponse != string(v) {
+		return fmt.Errorf("recaptcha: verification failed")
+	}
+	return nil
+}
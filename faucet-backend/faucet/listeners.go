@@ -0,0 +1,168 @@
+package faucet
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+// pathMetrics is the path of the metrics, on listeners that also serve
+// other routes.
+const pathMetrics = "/metrics"
+
+// localConnKey is the context key marking connections accepted on Unix
+// domain sockets.
+type localConnKey struct{}
+
+// isLocalConn returns true iff the request came in over a Unix domain
+// socket.
+func isLocalConn(ctx context.Context) bool {
+	local, _ := ctx.Value(localConnKey{}).(bool)
+	return local
+}
+
+// httpListener is a running HTTP listener.
+type httpListener struct {
+	cfg    *faucetConfig.ListenerConfig
+	srv    *http.Server
+	ln     net.Listener
+	useTLS bool
+}
+
+// HandlerFor returns the HTTP handler serving the route sets.  The admin
+// routes are only served if requested, and never alongside the public
+// routes.
+func (svc *Service) HandlerFor(routes []string) http.Handler {
+	return svc.handlerFor(routes, svc.cfg.Access.AdminToken)
+}
+
+// handlerFor returns the HTTP handler serving the route sets, with the
+// admin routes authenticated by the admin token.
+func (svc *Service) handlerFor(routes []string, adminToken string) http.Handler {
+	l := faucetConfig.ListenerConfig{Routes: routes}
+	mux := http.NewServeMux()
+	if l.HasRoute(faucetConfig.RouteAPI) {
+		svc.registerAPIHandlers(mux)
+	}
+	if l.HasRoute(faucetConfig.RouteAdmin) && !l.HasRoute(faucetConfig.RouteAPI) && !l.HasRoute(faucetConfig.RouteStatic) {
+		svc.registerAdminHandlers(mux, adminToken)
+	}
+	if l.HasRoute(faucetConfig.RouteMetrics) {
+		switch len(routes) {
+		case 1:
+			mux.Handle("/", metrics.Handler())
+		default:
+			mux.Handle(pathMetrics, metrics.Handler())
+		}
+	}
+	if l.HasRoute(faucetConfig.RouteStatic) && svc.static != nil {
+		mux.Handle("/", svc.static)
+	}
+	return svc.withHTTPHardening(mux)
+}
+
+// withBearerToken wraps the handler, rejecting requests that do not carry
+// the bearer token.
+func (svc *Service) withBearerToken(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		reqToken, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(reqToken), []byte(token)) != 1 {
+			svc.log.Printf("frontend: unauthorized request from %v: %v", svc.clients.Display(svc.clientIP(req)), req.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, newAPIError(http.StatusUnauthorized, api.ErrCodeAccessDenied, "", "unauthorized"))
+			return
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// listenerTLSConfig returns the TLS configuration of the listener, or nil
+// if it serves plaintext.  Listeners without their own certificate use
+// the faucet's certificate, if TLS is enabled.
+func (svc *Service) listenerTLSConfig(l *faucetConfig.ListenerConfig) (*tls.Config, error) {
+	switch {
+	case l.Plaintext || l.IsUnix():
+		return nil, nil
+	case l.TLSCertFile != "":
+		r, err := newCertReloader(l.TLSCertFile, l.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg := svc.cfg.HTTP.TLS.Config()
+		tlsCfg.GetCertificate = r.GetCertificate
+		return tlsCfg, nil
+	default:
+		return svc.serverTLSConfig(true), nil
+	}
+}
+
+// newHTTPListener creates the server of the listener, and starts
+// listening.
+func (svc *Service) newHTTPListener(l *faucetConfig.ListenerConfig) (*httpListener, error) {
+	tlsCfg, err := svc.listenerTLSConfig(l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %w", err)
+	}
+	// The listener's bearer token replaces the admin token.
+	adminToken := svc.cfg.Access.AdminToken
+	if l.BearerToken != "" {
+		adminToken = l.BearerToken
+	}
+	handler := svc.handlerFor(l.Routes, adminToken)
+	if l.BearerToken != "" {
+		handler = svc.withBearerToken(l.BearerToken, handler)
+	}
+	srv, err := svc.newHTTPServer(handler, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var ln net.Listener
+	switch network, addr := l.Network(); network {
+	case "unix":
+		// Remove the socket left behind by a previous run, but nothing
+		// else.
+		if fi, err := os.Lstat(addr); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(addr)
+		}
+		if ln, err = net.Listen(network, addr); err != nil {
+			return nil, fmt.Errorf("failed to listen: %w", err)
+		}
+		srv.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, localConnKey{}, true)
+		}
+	default:
+		if ln, err = svc.listen(addr); err != nil {
+			return nil, fmt.Errorf("failed to listen: %w", err)
+		}
+	}
+	return &httpListener{
+		cfg:    l,
+		srv:    srv,
+		ln:     ln,
+		useTLS: tlsCfg != nil,
+	}, nil
+}
+
+// serve serves requests until the server is shut down, reporting any other
+// failure on the error channel.
+func (hl *httpListener) serve(svc *Service, errCh chan<- error) {
+	svc.log.Printf("frontend: serving %v on %v", strings.Join(hl.cfg.Routes, ", "), hl.ln.Addr())
+	serve := hl.srv.Serve
+	if hl.useTLS {
+		// The certificate comes from the TLS configuration.
+		serve = func(ln net.Listener) error { return hl.srv.ServeTLS(ln, "", "") }
+	}
+	if err := serve(hl.ln); err != http.ErrServerClosed {
+		errCh <- fmt.Errorf("failed to serve on %v: %w", hl.ln.Addr(), err)
+	}
+}
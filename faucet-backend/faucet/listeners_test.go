@@ -0,0 +1,123 @@
+package faucet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestListeners(t *testing.T) {
+	const (
+		adminToken  = "admin-token"
+		bearerToken = "ops-token"
+	)
+
+	cfg := &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Access:                 faucetConfig.AccessConfig{AdminToken: adminToken},
+	}
+	if err := cfg.Proxy.Validate(); err != nil {
+		t.Fatalf("failed to validate proxy configuration: %v", err)
+	}
+	svc, _ := newTestService(t, cfg)
+	store, err := access.NewStore(filepath.Join(t.TempDir(), "access.json"), nil)
+	if err != nil {
+		t.Fatalf("failed to create access store: %v", err)
+	}
+	svc.access = store
+
+	t.Run("Routes", func(t *testing.T) {
+		get := func(handler http.Handler, path string) int {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+		public := svc.Handler()
+		admin := svc.HandlerFor([]string{faucetConfig.RouteAdmin, faucetConfig.RouteMetrics})
+		if code := get(public, pathAdminAccess); code != http.StatusNotFound {
+			t.Errorf("public: unexpected status code for the admin API: %d", code)
+		}
+		if code := get(public, pathMetrics); code != http.StatusNotFound {
+			t.Errorf("public: unexpected status code for the metrics: %d", code)
+		}
+		if code := get(admin, api.PathStatusV1); code != http.StatusNotFound {
+			t.Errorf("admin: unexpected status code for the public API: %d", code)
+		}
+		if code := get(admin, pathAdminAccess); code != http.StatusOK {
+			t.Errorf("admin: unexpected status code for the admin API: %d", code)
+		}
+		if code := get(admin, pathMetrics); code != http.StatusOK {
+			t.Errorf("admin: unexpected status code for the metrics: %d", code)
+		}
+	})
+
+	t.Run("UnixSocket", func(t *testing.T) {
+		sockPath := filepath.Join(t.TempDir(), "admin.sock")
+		hl, err := svc.newHTTPListener(&faucetConfig.ListenerConfig{
+			Addr:        "unix:" + sockPath,
+			Routes:      []string{faucetConfig.RouteAdmin},
+			BearerToken: bearerToken,
+		})
+		if err != nil {
+			t.Fatalf("failed to create listener: %v", err)
+		}
+		errCh := make(chan error, 1)
+		go hl.serve(svc, errCh)
+		defer hl.srv.Close()
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", sockPath)
+				},
+			},
+		}
+		for _, tc := range []struct {
+			name   string
+			token  string
+			status int
+		}{
+			{"NoToken", "", http.StatusUnauthorized},
+			{"AdminToken", adminToken, http.StatusUnauthorized},
+			{"BearerToken", bearerToken, http.StatusOK},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				req, _ := http.NewRequest(http.MethodGet, "http://faucet"+pathAdminAccess, nil)
+				if tc.token != "" {
+					req.Header.Set("Authorization", "Bearer "+tc.token)
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Fatalf("failed to query: %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != tc.status {
+					t.Fatalf("unexpected status code %d", resp.StatusCode)
+				}
+			})
+		}
+
+		// Local proxies are only trusted to forward the client address if
+		// configured.
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), localConnKey{}, true))
+		req.RemoteAddr = "@"
+		req.Header.Set("X-Forwarded-For", "198.51.100.1")
+		if ip := svc.clientIP(req); ip != nil {
+			t.Errorf("client IP: got %v, expected unknown", ip)
+		}
+		svc.cfg.Proxy.TrustUnixSockets = true
+		if ip := svc.clientIP(req); ip.String() != "198.51.100.1" {
+			t.Errorf("client IP: got %v, expected the forwarded address", ip)
+		}
+	})
+}
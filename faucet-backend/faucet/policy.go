@@ -0,0 +1,141 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/helpers"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+// errAccountFunded is the error returned when an account is refused
+// funding due to already holding enough tokens.
+var errAccountFunded = &APIError{
+	Status:  http.StatusForbidden,
+	Code:    api.ErrCodeAccountFunded,
+	Field:   queryAccount,
+	Message: "failed to fund account: account already has sufficient funds",
+}
+
+// parseTokens converts an amount of tokens to the base units of the
+// consensus layer (nil paratime) or of the paratime.
+func (svc *Service) parseTokens(pt *config.ParaTime, tokens string) (*quantity.Quantity, error) {
+	if pt == nil {
+		return helpers.ParseConsensusDenomination(svc.network, tokens)
+	}
+	amount, err := helpers.ParseParaTimeDenomination(pt, tokens, types.NativeDenomination)
+	if err != nil {
+		return nil, err
+	}
+	return &amount.Amount, nil
+}
+
+// amount returns the amount to be funded in base units.
+func (req *FundRequest) amount() *quantity.Quantity {
+	if req.ParaTime == nil {
+		return req.ConsensusAmount
+	}
+	return &req.ParaTimeAmount.Amount
+}
+
+// isToken returns true iff the request funds a paratime denomination other
+// than the native one, ie: a test token.  Test tokens are paid out of the
+// faucet's paratime account rather than the consensus reserve, so the
+// amount limits and policies, which are in the native denomination, do not
+// apply to them.
+func (req *FundRequest) isToken() bool {
+	return req.ParaTime != nil && req.ParaTimeAmount.Denomination != types.NativeDenomination
+}
+
+// truncateAmount rounds an amount down so that it can be represented in
+// consensus base units, which is required for paratime deposits.
+func (svc *Service) truncateAmount(pt *config.ParaTime, amount *quantity.Quantity) {
+	if pt == nil {
+		return
+	}
+	scale := int64(pt.Denominations[config.NativeDenominationKey].Decimals) - int64(svc.network.Denomination.Decimals)
+	if scale <= 0 {
+		return
+	}
+
+	v := amount.ToBigInt()
+	v.Sub(v, new(big.Int).Mod(v, new(big.Int).Exp(big.NewInt(10), big.NewInt(scale), nil)))
+	_ = amount.FromBigInt(v)
+}
+
+// formatAmount returns the formatted amount to be funded.
+func (svc *Service) formatAmount(req *FundRequest) string {
+	return svc.formatBalance(req.ParaTime, req.amount())
+}
+
+// ApplyBalancePolicy applies the balance policy to a funding request,
+// reducing the amount to be funded if required.  A non-empty reason is
+// returned if the amount was reduced.  The returned error is suitable for
+// displaying to the user, and is errAccountFunded if the request should
+// be rejected.
+func (svc *Service) ApplyBalancePolicy(ctx context.Context, req *FundRequest) (string, error) {
+	policy := &svc.cfg.BalancePolicy
+	if policy.Mode == "" {
+		return "", nil
+	}
+
+	balance, err := svc.QueryBalance(ctx, req.ParaTime, req.Account)
+	if err != nil {
+		svc.log.Printf("frontend: failed to query balance of '%v': %v", req.Account, err)
+		return "", errTemporaryFailure()
+	}
+
+	limitStr := policy.Threshold
+	if policy.Mode == faucetConfig.BalancePolicyTopUp {
+		limitStr = policy.Target
+	}
+	limit, err := svc.parseTokens(req.ParaTime, limitStr)
+	if err != nil {
+		svc.log.Printf("frontend: invalid balance policy limit '%v': %v", limitStr, err)
+		return "", fmt.Errorf("failed to fund account: balance policy misconfigured")
+	}
+	if balance.Cmp(limit) < 0 && policy.Mode != faucetConfig.BalancePolicyTopUp {
+		return "", nil
+	}
+
+	amount := req.amount()
+	requested := *amount.Clone()
+	switch policy.Mode {
+	case faucetConfig.BalancePolicyReject:
+		return "", errAccountFunded
+	case faucetConfig.BalancePolicyScale:
+		scaled := new(big.Int).Mul(amount.ToBigInt(), limit.ToBigInt())
+		scaled.Quo(scaled, balance.ToBigInt())
+		_ = amount.FromBigInt(scaled)
+	case faucetConfig.BalancePolicyTopUp:
+		toFund := limit.Clone()
+		if err = toFund.Sub(balance); err != nil {
+			toFund = quantity.NewQuantity()
+		}
+		if toFund.Cmp(amount) < 0 {
+			*amount = *toFund
+		}
+	}
+	svc.truncateAmount(req.ParaTime, amount)
+	switch {
+	case amount.IsZero():
+		return "", errAccountFunded
+	case amount.Cmp(&requested) == 0:
+		return "", nil
+	}
+
+	svc.log.Printf("frontend: reduced amount for well funded account '%v' (balance: %v): %v",
+		req.Account,
+		svc.formatBalance(req.ParaTime, balance),
+		svc.formatAmount(req),
+	)
+	return fmt.Sprintf("account balance is %s", svc.formatBalance(req.ParaTime, balance)), nil
+}
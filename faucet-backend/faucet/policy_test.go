@@ -0,0 +1,74 @@
+package faucet
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestBalancePolicy(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		Bundles: map[string]*faucetConfig.BundleConfig{
+			"starter": {
+				Items: []faucetConfig.BundleItemConfig{
+					{ParaTime: "sapphire", Amount: "1"},
+					{Amount: "10"},
+				},
+			},
+		},
+	}
+	svc, chain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	to := testAddress(t)
+	fundAndWait := func(amount string) *api.FundResponse {
+		t.Helper()
+
+		code, resp := fund(t, svc, "", to.String(), amount)
+		if code != http.StatusOK {
+			t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+		}
+		if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+			t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+		}
+		return resp
+	}
+	fundAndWait("100")
+
+	cfg.BalancePolicy = faucetConfig.BalancePolicyConfig{
+		Mode:      faucetConfig.BalancePolicyReject,
+		Threshold: "50",
+	}
+	if code, resp := fund(t, svc, "", to.String(), "10"); code != http.StatusForbidden {
+		t.Fatalf("reject: unexpected response %d: %+v", code, resp)
+	}
+
+	// 10 * 50 / 100 = 5
+	cfg.BalancePolicy.Mode = faucetConfig.BalancePolicyScale
+	if resp := fundAndWait("10"); resp.Amount != "5.0 TEST" || resp.AmountReason == "" {
+		t.Fatalf("scale: unexpected response: %+v", resp)
+	}
+
+	// 110 - 105 = 5
+	cfg.BalancePolicy = faucetConfig.BalancePolicyConfig{
+		Mode:   faucetConfig.BalancePolicyTopUp,
+		Target: "110",
+	}
+	if resp := fundAndWait("10"); resp.Amount != "5.0 TEST" || resp.AmountReason == "" {
+		t.Fatalf("top up: unexpected response: %+v", resp)
+	}
+	balance := chain.ConsensusBalance(to)
+	if expected := testQuantity(t, "110000000000"); balance.Cmp(&expected) != 0 {
+		t.Errorf("recipient balance: got %v, expected %v", balance, expected)
+	}
+	if code, resp := fund(t, svc, "", to.String(), "10"); code != http.StatusForbidden {
+		t.Fatalf("top up at target: unexpected response %d: %+v", code, resp)
+	}
+
+	// Bundles are refused if any of the items is.
+	if code, resp := bundle(t, svc, "starter", to.String()); code != http.StatusForbidden {
+		t.Fatalf("bundle: unexpected response %d: %+v", code, resp)
+	}
+}
@@ -0,0 +1,193 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/captcha"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+const (
+	// maxRateLimitBuckets is the number of per-client buckets above which
+	// full buckets are pruned.
+	maxRateLimitBuckets = 10_000
+
+	// retryAfterCaptchaBusy is the Retry-After reported when the CAPTCHA
+	// verifications are at their concurrency limit.
+	retryAfterCaptchaBusy = 1 * time.Second
+
+	rateLimitClient      = "client"
+	rateLimitAllowlisted = "allowlisted"
+	rateLimitGlobal      = "global"
+	rateLimitCaptcha     = "captcha"
+	rateLimitBalance     = "balance"
+)
+
+// tokenBucket is a single client's token bucket.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// bucketLimiter is a token bucket rate limiter, with a bucket per key.
+type bucketLimiter struct {
+	lock sync.Mutex
+
+	rate  float64 // Tokens per second.
+	burst float64
+
+	buckets map[string]*tokenBucket
+}
+
+// newBucketLimiter creates a new token bucket rate limiter, or returns nil
+// if the rate is unlimited.
+func newBucketLimiter(cfg *faucetConfig.RateConfig) *bucketLimiter {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &bucketLimiter{
+		rate:    cfg.PerMinute / 60,
+		burst:   float64(cfg.BurstSize()),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// refill returns the number of tokens in the bucket at the time.
+func (l *bucketLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate
+	if tokens > l.burst {
+		tokens = l.burst
+	}
+	return tokens
+}
+
+// Allow takes a token from the bucket of the client identified by key, and
+// returns true iff there was one.  Otherwise, it returns how long until the
+// next token.  A nil limiter allows everything.
+func (l *bucketLimiter) Allow(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	b := l.buckets[key]
+	if b == nil {
+		if len(l.buckets) >= maxRateLimitBuckets {
+			for k, v := range l.buckets {
+				if l.refill(v, now) >= l.burst {
+					delete(l.buckets, k)
+				}
+			}
+		}
+		b = &tokenBucket{
+			tokens: l.burst,
+			last:   now,
+		}
+		l.buckets[key] = b
+	}
+
+	b.tokens, b.last = l.refill(b, now), now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// rateLimitKey returns the key of the client's per-client bucket, which is
+// the API key for allowlisted API keys, and the IP address otherwise, as
+// unknown API keys are free to make up.  Clients whose IP address is not
+// known, eg: behind an untrusted local proxy, share the bucket of the
+// connection's peer.  It also returns true iff the client is allowlisted,
+// either by API key or by IP address.
+func (svc *Service) rateLimitKey(client *clientInfo) (string, bool) {
+	if client.APIKey != "" {
+		if decision := svc.access.Check(nil, nil, client.APIKey); decision.Allowed {
+			return "api_key:" + client.APIKey, true
+		}
+	}
+	if client.IP == nil {
+		return "peer:" + client.RemoteAddr, false
+	}
+	decision := svc.access.Check(nil, client.IP, "")
+	return "ip:" + client.IP.String(), decision.Allowed
+}
+
+// checkRateLimit checks the client against the per-client, or the
+// allowlisted client, and the global funding rate limits, counting
+// rejections.  The returned error is suitable for displaying to the user.
+func (svc *Service) checkRateLimit(client *clientInfo) error {
+	clientLimit, clientLimiter := rateLimitClient, svc.clientLimiter
+	key, allowlisted := svc.rateLimitKey(client)
+	if allowlisted {
+		clientLimit, clientLimiter = rateLimitAllowlisted, svc.allowlistedLimiter
+	}
+
+	for _, limit := range []struct {
+		name    string
+		limiter *bucketLimiter
+		key     string
+	}{
+		{clientLimit, clientLimiter, key},
+		{rateLimitGlobal, svc.globalLimiter, ""},
+	} {
+		if ok, retryAfter := limit.limiter.Allow(limit.key); !ok {
+			svc.metrics.RateLimitedRequests.WithLabelValues(limit.name).Inc()
+			err := newAPIError(http.StatusTooManyRequests, api.ErrCodeRateLimited, "", "too many funding requests, try again later")
+			err.RetryAfter = retryAfter
+			return err
+		}
+	}
+	return nil
+}
+
+// rateLimited wraps a funding endpoint handler with the rate limits.
+func (svc *Service) rateLimited(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		client := svc.clientInfoOf(req)
+		if err := svc.checkRateLimit(client); err != nil {
+			svc.log.Printf("frontend: request rate limited: client: %v", svc.clients.Display(client.IP))
+			writeError(w, err)
+			return
+		}
+		handler(w, req.WithContext(withClientInfo(req.Context(), client)))
+	}
+}
+
+// verifyCaptcha verifies the client's CAPTCHA response, unless CAPTCHA
+// verification is disabled.  The returned error is suitable for displaying
+// to the user.
+func (svc *Service) verifyCaptcha(ctx context.Context, userResponse string, decision access.Decision) error {
+	if svc.captcha == nil || decision.Allowed {
+		return nil
+	}
+
+	err := svc.captcha.Verify(ctx, userResponse, clientInfoFrom(ctx).IP)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, captcha.ErrBusy):
+		svc.log.Printf("frontend: reCAPTCHA busy")
+		svc.metrics.RateLimitedRequests.WithLabelValues(rateLimitCaptcha).Inc()
+		apiErr := errTemporaryFailure()
+		apiErr.RetryAfter = retryAfterCaptchaBusy
+		return apiErr
+	default:
+		svc.log.Printf("frontend: reCAPTCHA failed: %v", err)
+		return newAPIError(
+			http.StatusForbidden,
+			api.ErrCodeCaptchaFailed,
+			queryRecaptchaResponse,
+			"failed to verify reCAPTCHA",
+		)
+	}
+}
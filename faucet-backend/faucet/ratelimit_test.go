@@ -0,0 +1,199 @@
+package faucet
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/captcha"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestRateLimits(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		RateLimits: faucetConfig.RateLimitConfig{
+			PerClient: faucetConfig.RateConfig{PerMinute: 1, Burst: 2},
+			Global:    faucetConfig.RateConfig{PerMinute: 1, Burst: 3},
+		},
+	})
+	startBank(t, svc)
+
+	handler := svc.Handler()
+	fund := func(remoteAddr string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		path := api.PathFundV1 + "?" + queryAccount + "=" + testAddress(t).String() + "&" + queryAmount + "=1"
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = remoteAddr
+		var resp api.FundResponse
+		w := serveJSON(t, handler, req, &resp)
+		if w.Code == http.StatusOK {
+			waitForRequest(t, svc, resp.RequestID)
+		}
+		return w
+	}
+
+	for i, tc := range []struct {
+		remoteAddr string
+		status     int
+		limit      string
+	}{
+		{"192.0.2.1:1234", http.StatusOK, ""},
+		{"192.0.2.1:1234", http.StatusOK, ""},
+		{"192.0.2.1:1234", http.StatusTooManyRequests, rateLimitClient},
+		{"192.0.2.2:1234", http.StatusOK, ""},
+		{"192.0.2.3:1234", http.StatusTooManyRequests, rateLimitGlobal},
+	} {
+		w := fund(tc.remoteAddr)
+		if w.Code != tc.status {
+			t.Fatalf("request %d: unexpected status code %d: %s", i, w.Code, w.Body)
+		}
+		if tc.status != http.StatusTooManyRequests {
+			continue
+		}
+		if retryAfter, _ := strconv.Atoi(w.Header().Get("Retry-After")); retryAfter <= 0 || retryAfter > 60 {
+			t.Errorf("request %d: unexpected Retry-After: '%s'", i, w.Header().Get("Retry-After"))
+		}
+		if n := testutil.ToFloat64(svc.metrics.RateLimitedRequests.WithLabelValues(tc.limit)); n != 1 {
+			t.Errorf("request %d: rate limited requests: got %v, expected 1", i, n)
+		}
+	}
+
+	t.Run("CaptchaBusy", func(t *testing.T) {
+		svc.captcha = captcha.NewLimited(testCaptchaVerifier("valid"), 0)
+		_, err := svc.SubmitFundRequest(context.Background(), &api.FundParams{
+			Account:         testAddress(t).String(),
+			Amount:          "1",
+			CaptchaResponse: "valid",
+		})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != api.ErrCodeUnavailable || apiErr.RetryAfter <= 0 {
+			t.Fatalf("fund: unexpected error: %v", err)
+		}
+		if n := testutil.ToFloat64(svc.metrics.RateLimitedRequests.WithLabelValues(rateLimitCaptcha)); n != 1 {
+			t.Errorf("rate limited requests: got %v, expected 1", n)
+		}
+	})
+}
+
+func TestAllowlistedRateLimits(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		RateLimits: faucetConfig.RateLimitConfig{
+			PerClient:      faucetConfig.RateConfig{PerMinute: 1, Burst: 1},
+			PerAllowlisted: faucetConfig.RateConfig{PerMinute: 1, Burst: 2},
+		},
+	})
+	store, err := access.NewStore(filepath.Join(t.TempDir(), "access.json"), nil)
+	if err != nil {
+		t.Fatalf("failed to create access store: %v", err)
+	}
+	for _, entry := range []*access.Entry{
+		{CIDR: "192.0.2.10"},
+		{APIKey: "partner"},
+	} {
+		if err = store.Add(access.Allowed, entry); err != nil {
+			t.Fatalf("failed to allowlist %+v: %v", entry, err)
+		}
+	}
+	svc.access = store
+	startBank(t, svc)
+
+	handler := svc.Handler()
+	fund := func(remoteAddr, apiKey string) int {
+		t.Helper()
+
+		path := api.PathFundV1 + "?" + queryAccount + "=" + testAddress(t).String() + "&" + queryAmount + "=1"
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			req.Header.Set(api.HeaderAPIKey, apiKey)
+		}
+		var resp api.FundResponse
+		w := serveJSON(t, handler, req, &resp)
+		if w.Code == http.StatusOK {
+			waitForRequest(t, svc, resp.RequestID)
+		}
+		return w.Code
+	}
+
+	// Allowlisted IP addresses and API keys get the higher quota, in
+	// buckets of their own.
+	for i, tc := range []struct {
+		remoteAddr string
+		apiKey     string
+		status     int
+	}{
+		{"192.0.2.1:1234", "", http.StatusOK},
+		{"192.0.2.1:1234", "", http.StatusTooManyRequests},
+		{"192.0.2.10:1234", "", http.StatusOK},
+		{"192.0.2.10:1234", "", http.StatusOK},
+		{"192.0.2.10:1234", "", http.StatusTooManyRequests},
+		{"192.0.2.1:1234", "partner", http.StatusOK},
+		{"192.0.2.1:1234", "partner", http.StatusOK},
+		{"192.0.2.1:1234", "partner", http.StatusTooManyRequests},
+		{"192.0.2.1:1234", "unknown", http.StatusTooManyRequests},
+	} {
+		if code := fund(tc.remoteAddr, tc.apiKey); code != tc.status {
+			t.Fatalf("request %d: got status code %d, expected %d", i, code, tc.status)
+		}
+	}
+	for _, tc := range []struct {
+		limit string
+		n     float64
+	}{
+		{rateLimitClient, 2},
+		{rateLimitAllowlisted, 2},
+	} {
+		if n := testutil.ToFloat64(svc.metrics.RateLimitedRequests.WithLabelValues(tc.limit)); n != tc.n {
+			t.Errorf("%s rate limited requests: got %v, expected %v", tc.limit, n, tc.n)
+		}
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	svc, _ := newTestService(t, &faucetConfig.Config{})
+	store, err := access.NewStore(filepath.Join(t.TempDir(), "access.json"), nil)
+	if err != nil {
+		t.Fatalf("failed to create access store: %v", err)
+	}
+	for _, entry := range []*access.Entry{
+		{CIDR: "192.0.2.10"},
+		{APIKey: "partner"},
+	} {
+		if err = store.Add(access.Allowed, entry); err != nil {
+			t.Fatalf("failed to allowlist %+v: %v", entry, err)
+		}
+	}
+	svc.access = store
+
+	for _, tc := range []struct {
+		name        string
+		client      clientInfo
+		key         string
+		allowlisted bool
+	}{
+		{"IP", clientInfo{IP: net.ParseIP("192.0.2.1"), RemoteAddr: "192.0.2.1:1234"}, "ip:192.0.2.1", false},
+		{"AllowlistedIP", clientInfo{IP: net.ParseIP("192.0.2.10"), RemoteAddr: "192.0.2.10:1234"}, "ip:192.0.2.10", true},
+		{"AllowlistedAPIKey", clientInfo{RemoteAddr: "@", APIKey: "partner"}, "api_key:partner", true},
+		{"UnknownAPIKey", clientInfo{IP: net.ParseIP("192.0.2.1"), APIKey: "unknown"}, "ip:192.0.2.1", false},
+		{"UnknownIP", clientInfo{RemoteAddr: "@", APIKey: "unknown"}, "peer:@", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			key, allowlisted := svc.rateLimitKey(&tc.client)
+			if key != tc.key || allowlisted != tc.allowlisted {
+				t.Errorf("rateLimitKey: got %q (allowlisted: %v), expected %q (allowlisted: %v)", key, allowlisted, tc.key, tc.allowlisted)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+)
+
+// reserveCacheKey is the balance cache key of the reserve backing
+// requests to the consensus layer (nil paratime) or the paratime.
+func reserveCacheKey(pt *config.ParaTime) string {
+	if pt == nil {
+		return "reserve"
+	}
+	return "reserve/" + pt.ID
+}
+
+// reserveOf returns the reserve backing requests to the consensus layer
+// (nil paratime) or the paratime, given the faucet's consensus account.
+func reserveOf(pt *config.ParaTime, account *staking.Account) *quantity.Quantity {
+	reserve := account.General.Balance.Clone()
+	if pt != nil {
+		allowance := account.General.Allowances[staking.NewRuntimeAddress(pt.Namespace())]
+		if allowance.Cmp(reserve) < 0 {
+			reserve = allowance.Clone()
+		}
+	}
+	return reserve
+}
+
+// queryReserve returns the reserve backing requests to the consensus layer
+// (nil paratime) or the paratime.  Reserves are cached for balanceCacheTTL.
+func (svc *Service) queryReserve(ctx context.Context, pt *config.ParaTime) (*quantity.Quantity, error) {
+	key := reserveCacheKey(pt)
+	if reserve, ok := svc.balances.get(key); ok {
+		return reserve, nil
+	}
+
+	account, err := svc.chain.ConsensusAccount(ctx, svc.address)
+	if err != nil {
+		return nil, err
+	}
+	reserve := reserveOf(pt, account)
+	svc.balances.put(key, reserve)
+
+	return reserve, nil
+}
+
+// maxAmount returns the configured per-request maximum in base units, or
+// nil if there is none.
+func (svc *Service) maxAmount(pt *config.ParaTime) (*quantity.Quantity, error) {
+	switch pt {
+	case nil:
+		if svc.cfg.MaxConsensusFundAmount.IsZero() {
+			return nil, nil
+		}
+		return svc.cfg.MaxConsensusFundAmount.Clone(), nil
+	default:
+		if svc.cfg.MaxParatimeFundAmount == "" {
+			return nil, nil
+		}
+		return svc.parseTokens(pt, svc.cfg.MaxParatimeFundAmount)
+	}
+}
+
+// reserveFactor returns the factor the per-request maximum is scaled by,
+// given the reserve.
+func (svc *Service) reserveFactor(reserve *quantity.Quantity) (float64, error) {
+	factor := 1.0
+	var lowest *quantity.Quantity
+	for _, tier := range svc.cfg.Reserve.Tiers {
+		below, err := svc.parseTokens(nil, tier.Below)
+		if err != nil {
+			return 0, err
+		}
+		if reserve.Cmp(below) >= 0 || (lowest != nil && below.Cmp(lowest) >= 0) {
+			continue
+		}
+		lowest = below
+		factor = tier.Factor
+	}
+	return factor, nil
+}
+
+// scaleAmount returns the amount in base units scaled by the factor, but
+// not below the configured floor, unless the amount itself is.
+func (svc *Service) scaleAmount(pt *config.ParaTime, amount *quantity.Quantity, factor float64) (*quantity.Quantity, error) {
+	if factor == 1 {
+		return amount.Clone(), nil
+	}
+
+	scaled, _ := new(big.Float).Mul(new(big.Float).SetInt(amount.ToBigInt()), big.NewFloat(factor)).Int(nil)
+	effective := quantity.NewQuantity()
+	_ = effective.FromBigInt(scaled)
+	if svc.cfg.Reserve.Floor != "" {
+		floor, err := svc.parseTokens(pt, svc.cfg.Reserve.Floor)
+		if err != nil {
+			return nil, err
+		}
+		if floor.Cmp(amount) > 0 {
+			floor = amount
+		}
+		if effective.Cmp(floor) < 0 {
+			effective = floor.Clone()
+		}
+	}
+	svc.truncateAmount(pt, effective)
+
+	return effective, nil
+}
+
+// effectiveMaxAmount returns the per-request maximum in base units given
+// the reserve, or nil if there is none, and the factor it was scaled by.
+func (svc *Service) effectiveMaxAmount(pt *config.ParaTime, reserve *quantity.Quantity) (*quantity.Quantity, float64, error) {
+	factor, err := svc.reserveFactor(reserve)
+	if err != nil {
+		return nil, 0, err
+	}
+	max, err := svc.maxAmount(pt)
+	if err != nil || max == nil {
+		return nil, factor, err
+	}
+	if max, err = svc.scaleAmount(pt, max, factor); err != nil {
+		return nil, 0, err
+	}
+	return max, factor, nil
+}
+
+// effectiveDefaultAmount returns the default amount in base units scaled
+// like the per-request maximum, and bounded by the effective maximum, or
+// nil if there is none.
+func (svc *Service) effectiveDefaultAmount(pt *config.ParaTime, max *quantity.Quantity, factor float64) (*quantity.Quantity, error) {
+	var name string
+	if pt != nil {
+		name = svc.paratimeName(pt.ID)
+	}
+	tokens := svc.cfg.Amounts.ForParaTime(name).Default
+	if tokens == "" {
+		return nil, nil
+	}
+
+	amount, err := svc.parseTokens(pt, tokens)
+	if err != nil {
+		return nil, err
+	}
+	if amount, err = svc.scaleAmount(pt, amount, factor); err != nil {
+		return nil, err
+	}
+	if max != nil && amount.Cmp(max) > 0 {
+		amount = max.Clone()
+	}
+	return amount, nil
+}
+
+// metricValue returns the quantity as a metric value.  Unlike going
+// through uint64, this does not wrap for quantities of 2^64 base units
+// and above, which are common for the 18 decimal paratimes.
+func metricValue(q *quantity.Quantity) float64 {
+	v, _ := new(big.Float).SetInt(q.ToBigInt()).Float64()
+	return v
+}
+
+// updateLimitMetrics updates the effective per-request maximum metrics.
+func (svc *Service) updateLimitMetrics(pt *config.ParaTime, max *quantity.Quantity, factor float64) {
+	name := "consensus"
+	if pt != nil {
+		name = svc.paratimeName(pt.ID)
+	}
+	if max != nil {
+		svc.metrics.EffectiveMaxAmounts.WithLabelValues(name).Set(metricValue(max))
+	}
+	svc.metrics.PayoutFactors.WithLabelValues(name).Set(factor)
+}
+
+// refreshLimits updates the effective per-request maximum metrics from the
+// faucet's consensus account.
+func (svc *Service) refreshLimits(pt *config.ParaTime, account *staking.Account) {
+	max, factor, err := svc.effectiveMaxAmount(pt, reserveOf(pt, account))
+	if err != nil {
+		svc.log.Printf("bank: failed to determine effective maximum amount: %v", err)
+		return
+	}
+	svc.updateLimitMetrics(pt, max, factor)
+}
+
+// EffectiveMaxAmount returns the current per-request maximum in base units
+// for the consensus layer (nil paratime) or the paratime, or nil if there
+// is none, and the factor it was scaled by due to low reserves.
+func (svc *Service) EffectiveMaxAmount(ctx context.Context, pt *config.ParaTime) (*quantity.Quantity, float64, error) {
+	if len(svc.cfg.Reserve.Tiers) == 0 {
+		max, err := svc.maxAmount(pt)
+		return max, 1, err
+	}
+
+	reserve, err := svc.queryReserve(ctx, pt)
+	if err != nil {
+		return nil, 0, err
+	}
+	max, factor, err := svc.effectiveMaxAmount(pt, reserve)
+	if err != nil {
+		return nil, 0, err
+	}
+	svc.updateLimitMetrics(pt, max, factor)
+
+	return max, factor, nil
+}
+
+// ApplyReservePolicy reduces the amount to be funded to the effective
+// per-request maximum, or to the effective default amount if the request
+// did not specify one.  A non-empty reason is returned if the amount was
+// reduced.  The returned error is suitable for displaying to the user.
+func (svc *Service) ApplyReservePolicy(ctx context.Context, req *FundRequest) (string, error) {
+	max, factor, err := svc.EffectiveMaxAmount(ctx, req.ParaTime)
+	if err != nil {
+		svc.log.Printf("frontend: failed to determine effective maximum amount: %v", err)
+		return "", errTemporaryFailure()
+	}
+	if factor == 1 {
+		return "", nil
+	}
+
+	limit, what := max, "maximum"
+	if req.defaultAmount {
+		if limit, err = svc.effectiveDefaultAmount(req.ParaTime, max, factor); err != nil {
+			svc.log.Printf("frontend: failed to determine effective default amount: %v", err)
+			return "", errTemporaryFailure()
+		}
+		what = "default"
+	}
+	if limit == nil || req.amount().Cmp(limit) <= 0 {
+		return "", nil
+	}
+
+	*req.amount() = *limit.Clone()
+	svc.log.Printf("frontend: reduced amount due to low reserves (factor: %v): %v", factor, svc.formatAmount(req))
+
+	return fmt.Sprintf("faucet reserves are low, the %s amount is %s", what, svc.formatBalance(req.ParaTime, limit)), nil
+}
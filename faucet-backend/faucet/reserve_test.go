@@ -0,0 +1,94 @@
+package faucet
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestReserveTiers(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		Amounts: faucetConfig.AmountsConfig{
+			Consensus: faucetConfig.AmountConfig{Default: "50"},
+		},
+		Reserve: faucetConfig.ReserveConfig{
+			Tiers: []faucetConfig.ReserveTierConfig{
+				{Below: "5000000", Factor: 0.5},
+				{Below: "2000000", Factor: 0.1},
+				{Below: "10", Factor: 0.01},
+			},
+			Floor: "2",
+		},
+	}
+	svc, _ := newTestService(t, cfg)
+	startBank(t, svc)
+
+	// The reserve of 1000000 is below both of the first two tiers, and
+	// the lowest applies.
+	to := testAddress(t)
+	code, resp := fund(t, svc, "", to.String(), "100")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if resp.Amount != "10.0 TEST" || resp.AmountReason == "" {
+		t.Fatalf("fund: unexpected response: %+v", resp)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	// Amounts within the effective maximum are left alone.
+	if code, resp = fund(t, svc, "", testAddress(t).String(), "5"); code != http.StatusOK || resp.AmountReason != "" {
+		t.Fatalf("fund: unexpected response %d: %+v", code, resp)
+	}
+
+	// The default amount is scaled by the same factor, and reported as such.
+	if code, resp = fund(t, svc, "", testAddress(t).String(), ""); code != http.StatusOK || resp.Amount != "5.0 TEST" || resp.AmountReason == "" {
+		t.Fatalf("fund: unexpected response %d: %+v", code, resp)
+	}
+	info, err := svc.Info(context.Background())
+	if err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+	if info.Consensus.DefaultAmount != "5.0 TEST" || info.Consensus.MaxAmount != "10.0 TEST" || info.Consensus.PayoutFactor != 0.1 {
+		t.Errorf("info: unexpected consensus funding info: %+v", info.Consensus)
+	}
+
+	max, factor, err := svc.EffectiveMaxAmount(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("EffectiveMaxAmount: %v", err)
+	}
+	if expected := testQuantity(t, "10000000000"); max.Cmp(&expected) != 0 || factor != 0.1 {
+		t.Errorf("effective max: got %v (factor %v), expected %v (factor 0.1)", max, factor, expected)
+	}
+
+	// The floor bounds the scaled maximum.
+	reserve := testQuantity(t, "1000000000")
+	max, factor, err = svc.effectiveMaxAmount(nil, &reserve)
+	if err != nil {
+		t.Fatalf("effectiveMaxAmount: %v", err)
+	}
+	if expected := testQuantity(t, "2000000000"); max.Cmp(&expected) != 0 || factor != 0.01 {
+		t.Errorf("floored max: got %v (factor %v), expected %v (factor 0.01)", max, factor, expected)
+	}
+	def, err := svc.effectiveDefaultAmount(nil, max, factor)
+	if err != nil {
+		t.Fatalf("effectiveDefaultAmount: %v", err)
+	}
+	if expected := testQuantity(t, "2000000000"); def.Cmp(&expected) != 0 {
+		t.Errorf("floored default: got %v, expected %v", def, expected)
+	}
+
+	// Paratime amounts beyond 2^64 base units are reported as is.
+	ptMax := testQuantity(t, "100000000000000000000")
+	svc.updateLimitMetrics(svc.network.ParaTimes.All["sapphire"], &ptMax, 1)
+	if v := testutil.ToFloat64(svc.metrics.EffectiveMaxAmounts.WithLabelValues("sapphire")); v != 1e20 {
+		t.Errorf("paratime max metric: got %v, expected %v", v, 1e20)
+	}
+}
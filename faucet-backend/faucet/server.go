@@ -0,0 +1,134 @@
+package faucet
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// apiCSP is the Content-Security-Policy of API responses, which are
+	// never rendered.
+	apiCSP = "default-src 'none'; frame-ancestors 'none'"
+
+	// pathPrefixAPI is the prefix of the API endpoints.
+	pathPrefixAPI = "/api/"
+	// pathPrefixAdmin is the prefix of the admin API endpoints, which are
+	// never available cross-origin.
+	pathPrefixAdmin = "/api/admin/"
+
+	// shutdownTimeout is how long pending requests are waited for when
+	// shutting down, before their connections are closed.
+	shutdownTimeout = 30 * time.Second
+)
+
+// newHTTPServer creates a new HTTP server for the handler with the
+// configured timeouts, limits, and HTTP/2 settings, serving TLS with the
+// TLS configuration unless it is nil.
+func (svc *Service) newHTTPServer(handler http.Handler, tlsCfg *tls.Config) (*http.Server, error) {
+	httpCfg := &svc.cfg.HTTP
+	readHeaderTimeout, readTimeout, writeTimeout, idleTimeout := httpCfg.Timeouts()
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    httpCfg.HeaderLimit(),
+		TLSConfig:         tlsCfg,
+		ErrorLog:          svc.log,
+	}
+	switch httpCfg.HTTP2.Disable {
+	case true:
+		// A non-nil empty map disables HTTP/2.
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	default:
+		if err := http2.ConfigureServer(srv, &http2.Server{
+			MaxConcurrentStreams: httpCfg.HTTP2.MaxConcurrentStreams,
+			MaxReadFrameSize:     httpCfg.HTTP2.MaxReadFrameSize,
+			IdleTimeout:          idleTimeout,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+		}
+	}
+	return srv, nil
+}
+
+// shutdownHTTPServer gracefully shuts down the server, closing the
+// connections that are still active after the shutdown timeout.
+func (svc *Service) shutdownHTTPServer(srv *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		svc.log.Printf("frontend: failed graceful HTTP server shutdown: %v", err)
+		_ = srv.Close()
+	}
+}
+
+// withHTTPHardening wraps the handler with the request body size limit, the
+// security headers, and the API's CORS headers.
+func (svc *Service) withHTTPHardening(handler http.Handler) http.Handler {
+	httpCfg := &svc.cfg.HTTP
+	headersCfg := &httpCfg.SecurityHeaders
+	corsCfg := &httpCfg.CORS
+	hsts, frameOptions := headersCfg.HSTS(), headersCfg.FrameOptionsHeader()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h := w.Header()
+		isAPI := strings.HasPrefix(req.URL.Path, pathPrefixAPI)
+
+		// Security headers.
+		h.Set("X-Content-Type-Options", "nosniff")
+		if frameOptions != "" {
+			h.Set("X-Frame-Options", frameOptions)
+		}
+		if hsts != "" && req.TLS != nil {
+			h.Set("Strict-Transport-Security", hsts)
+		}
+		switch {
+		case isAPI:
+			h.Set("Content-Security-Policy", apiCSP)
+		case headersCfg.ContentSecurityPolicy != "":
+			h.Set("Content-Security-Policy", headersCfg.ContentSecurityPolicy)
+		}
+
+		// CORS.
+		if isAPI && !strings.HasPrefix(req.URL.Path, pathPrefixAdmin) {
+			h.Add("Vary", "Origin")
+			if origin := corsCfg.AllowOrigin(req.Header.Get("Origin")); origin != "" {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Expose-Headers", "Retry-After")
+
+				// Answer preflight requests directly.
+				if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+					h.Set("Access-Control-Allow-Methods", "GET, POST")
+					h.Set("Access-Control-Allow-Headers", corsCfg.AllowHeaders())
+					h.Set("Access-Control-Max-Age", strconv.FormatInt(corsCfg.MaxAgeSeconds(), 10))
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+		}
+
+		if req.Body != nil {
+			req.Body = http.MaxBytesReader(w, req.Body, httpCfg.BodyLimit())
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// clearDeadlines removes the server's read and write deadlines from a
+// long-lived response, such as a Server-Sent Event stream.
+func clearDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,132 @@
+package faucet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestHTTPHardening(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		HTTP: faucetConfig.HTTPConfig{
+			WriteTimeout: "200ms",
+			MaxBodyBytes: 128,
+			CORS: faucetConfig.CORSConfig{
+				AllowedOrigins: []string{"https://dapp.example.com"},
+			},
+		},
+	}
+	if err := cfg.HTTP.Validate(); err != nil {
+		t.Fatalf("failed to validate HTTP configuration: %v", err)
+	}
+	svc, _ := newTestService(t, cfg)
+	startBank(t, svc)
+
+	handler := svc.Handler()
+	do := func(method, path, origin, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, api.PathInfoV2, "", "")
+	for header, expected := range map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": apiCSP,
+	} {
+		if v := w.Header().Get(header); v != expected {
+			t.Errorf("%s: got '%s', expected '%s'", header, v, expected)
+		}
+	}
+
+	t.Run("CORS", func(t *testing.T) {
+		w := do(http.MethodOptions, api.PathFundV2, "https://dapp.example.com", "")
+		if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "https://dapp.example.com" {
+			t.Fatalf("preflight: unexpected response %d: %v", w.Code, w.Header())
+		}
+		if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), api.HeaderAPIKey) {
+			t.Errorf("preflight: unexpected allowed headers: '%s'", w.Header().Get("Access-Control-Allow-Headers"))
+		}
+		if w = do(http.MethodOptions, api.PathFundV2, "https://evil.example.com", ""); w.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("preflight: unexpected allowed origin: %v", w.Header())
+		}
+	})
+
+	t.Run("BodyLimit", func(t *testing.T) {
+		body := `{"account":"` + strings.Repeat("x", 256) + `"}`
+		if w := do(http.MethodPost, api.PathFundV2, "", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("fund: unexpected status code %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("EventStream", func(t *testing.T) {
+		srv, err := svc.newHTTPServer(handler, nil)
+		if err != nil {
+			t.Fatalf("failed to create HTTP server: %v", err)
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		go func() { _ = srv.Serve(ln) }()
+		defer srv.Close()
+
+		req, _ := http.NewRequest(http.MethodGet, "http://"+ln.Addr().String()+api.PathPayoutsV1, nil)
+		req.Header.Set("Accept", "text/event-stream")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to open stream: %v", err)
+		}
+		defer resp.Body.Close()
+
+		// Outlive the write timeout before the first event.
+		time.Sleep(400 * time.Millisecond)
+		fundResp, err := svc.SubmitFundRequest(context.Background(), &api.FundParams{
+			Account: testAddress(t).String(),
+			Amount:  "1",
+		})
+		if err != nil {
+			t.Fatalf("fund: unexpected error: %v", err)
+		}
+		waitForRequest(t, svc, fundResp.RequestID)
+
+		buf := make([]byte, 256)
+		n, err := resp.Body.Read(buf)
+		if err != nil || !strings.Contains(string(buf[:n]), "event: payout") {
+			t.Fatalf("stream: unexpected event %q: %v", buf[:n], err)
+		}
+
+		// Stopping the service ends the stream, so shutting down does not
+		// wait for the client.
+		shutdownDoneCh := make(chan struct{})
+		go func() {
+			svc.Stop()
+			svc.shutdownHTTPServer(srv)
+			close(shutdownDoneCh)
+		}()
+		select {
+		case <-shutdownDoneCh:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("shutdown: waited for the open stream")
+		}
+	})
+}
@@ -0,0 +1,207 @@
+// Package faucet implements the faucet service: the bank that moves tokens
+// from a pre-funded testnet address to consensus and paratime accounts, and
+// the HTTP and gRPC frontends that accept funding requests.
+package faucet
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/captcha"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	"github.com/oasisprotocol/tools/faucet-backend/clientip"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
+)
+
+// Service is the faucet service.
+type Service struct {
+	cfg     *faucetConfig.Config
+	network *config.Network
+
+	address staking.Address
+	signer  signature.Signer
+
+	// chain is the chain backend, if not connecting to the network's
+	// gRPC endpoint.
+	chain chain.Backend
+	// reservedAddresses are the addresses that must never be funded.
+	reservedAddresses map[types.Address]string
+
+	// captcha is the CAPTCHA verifier, if funding requests must carry a
+	// CAPTCHA response.
+	captcha captcha.Verifier
+	// access is the block and allow lists, if any.
+	access *access.Store
+	// clients resolves the client addresses of requests.
+	clients *clientip.Resolver
+
+	// static serves the static site, if a webroot is configured or the
+	// frontend is embedded.
+	static http.Handler
+
+	// acme is the ACME certificate manager, if enabled.
+	acme *autocert.Manager
+	// getCertificate returns the TLS certificate, if TLS is enabled.
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	log      *log.Logger
+	metrics  *metrics.FaucetMetrics
+	requests *RequestTracker
+
+	readyCh chan struct{}
+	stopCh  chan struct{}
+	quitCh  chan struct{}
+	doneCh  chan struct{}
+
+	stopOnce sync.Once
+
+	fundRequestCh   chan *FundRequest
+	bundleRequestCh chan *BundleRequest
+
+	dedupMap  map[string]bool
+	dedupLock sync.Mutex
+
+	bundleQuotas    map[string]*bundleQuota
+	bundleQuotaLock sync.Mutex
+
+	balances       *balanceCache
+	balanceLimiter *bucketLimiter
+
+	clientLimiter      *bucketLimiter
+	allowlistedLimiter *bucketLimiter
+	globalLimiter      *bucketLimiter
+}
+
+// Option is a service option.
+type Option func(*Service)
+
+// WithLogger sets the logger (Default: discard all logs).
+func WithLogger(logger *log.Logger) Option {
+	return func(svc *Service) {
+		svc.log = logger
+	}
+}
+
+// WithMetrics sets the metrics (Default: registered with the default
+// prometheus registerer).
+func WithMetrics(m *metrics.FaucetMetrics) Option {
+	return func(svc *Service) {
+		svc.metrics = m
+	}
+}
+
+// WithChainBackend sets the chain backend (Default: connect to the
+// network's gRPC endpoint).
+func WithChainBackend(backend chain.Backend) Option {
+	return func(svc *Service) {
+		svc.chain = backend
+	}
+}
+
+// WithCaptchaVerifier sets the CAPTCHA verifier (Default: reCAPTCHA, if
+// the configuration has a reCAPTCHA shared secret).
+func WithCaptchaVerifier(verifier captcha.Verifier) Option {
+	return func(svc *Service) {
+		svc.captcha = verifier
+	}
+}
+
+// WithAccessStore sets the block and allow lists (Default: none).
+func WithAccessStore(store *access.Store) Option {
+	return func(svc *Service) {
+		svc.access = store
+	}
+}
+
+// New creates a new faucet service for the network, funding requests from
+// the signer's account.
+func New(cfg *faucetConfig.Config, network *config.Network, signer signature.Signer, opts ...Option) (*Service, error) {
+	if cfg == nil || network == nil || signer == nil {
+		return nil, fmt.Errorf("faucet: missing configuration, network or signer")
+	}
+
+	svc := &Service{
+		cfg:                cfg,
+		network:            network,
+		address:            staking.NewAddress(signer.Public()),
+		signer:             signer,
+		reservedAddresses:  reservedAddresses(network, staking.NewAddress(signer.Public())),
+		clients:            cfg.Proxy.NewResolver(),
+		requests:           NewRequestTracker(),
+		readyCh:            make(chan struct{}),
+		stopCh:             make(chan struct{}),
+		quitCh:             make(chan struct{}),
+		doneCh:             make(chan struct{}),
+		fundRequestCh:      make(chan *FundRequest, 10),
+		bundleRequestCh:    make(chan *BundleRequest, 10),
+		dedupMap:           make(map[string]bool),
+		bundleQuotas:       make(map[string]*bundleQuota),
+		balances:           newBalanceCache(),
+		balanceLimiter:     newBucketLimiter(cfg.RateLimits.BalanceRate()),
+		clientLimiter:      newBucketLimiter(&cfg.RateLimits.PerClient),
+		allowlistedLimiter: newBucketLimiter(cfg.RateLimits.AllowlistedRate()),
+		globalLimiter:      newBucketLimiter(&cfg.RateLimits.Global),
+	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+
+	if svc.log == nil {
+		svc.log = log.New(io.Discard, "", log.LstdFlags)
+	}
+	if svc.metrics == nil {
+		svc.metrics = metrics.NewDefault()
+	}
+	if svc.captcha == nil && cfg.RecaptchaSharedSecret != "" {
+		svc.captcha = captcha.NewRecaptcha(cfg.RecaptchaSharedSecret, nil)
+	}
+	if svc.captcha != nil {
+		svc.captcha = captcha.NewLimited(svc.captcha, cfg.RateLimits.CaptchaConcurrency())
+	}
+	if err := svc.validateAmounts(); err != nil {
+		return nil, fmt.Errorf("faucet: invalid amounts: %w", err)
+	}
+	if err := svc.validateBundles(); err != nil {
+		return nil, fmt.Errorf("faucet: invalid bundles: %w", err)
+	}
+	if err := svc.initTLS(); err != nil {
+		return nil, fmt.Errorf("faucet: failed to initialize TLS: %w", err)
+	}
+	if err := svc.initStatic(); err != nil {
+		return nil, fmt.Errorf("faucet: failed to initialize static site: %w", err)
+	}
+
+	return svc, nil
+}
+
+// Address returns the faucet's funding address.
+func (svc *Service) Address() staking.Address {
+	return svc.address
+}
+
+// Stop stops the frontend, and then the bank once all pending requests
+// have been serviced.
+func (svc *Service) Stop() {
+	svc.stopOnce.Do(func() {
+		close(svc.stopCh)
+	})
+}
+
+// Done returns a channel that is closed once the service has stopped.
+func (svc *Service) Done() <-chan struct{} {
+	return svc.doneCh
+}
@@ -0,0 +1,44 @@
+package faucet
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/webui"
+)
+
+// frontendConfig returns the runtime configuration of the frontend.
+func (svc *Service) frontendConfig() *api.FrontendConfig {
+	cfg := &api.FrontendConfig{
+		CaptchaSiteKey: svc.cfg.RecaptchaSiteKey,
+		ParaTimes:      make([]string, 0, len(svc.network.ParaTimes.All)),
+	}
+	for name := range svc.network.ParaTimes.All {
+		cfg.ParaTimes = append(cfg.ParaTimes, name)
+	}
+	sort.Strings(cfg.ParaTimes)
+	return cfg
+}
+
+// initStatic sets up the static site handler, serving the webroot if
+// configured, or else the embedded frontend if any.
+func (svc *Service) initStatic() error {
+	var assets fs.FS
+	switch {
+	case svc.cfg.WebRoot != "":
+		assets = os.DirFS(svc.cfg.WebRoot)
+	case webui.Assets != nil:
+		assets = webui.Assets
+	default:
+		return nil
+	}
+
+	h, err := webui.NewHandler(assets, svc.frontendConfig())
+	if err != nil {
+		return err
+	}
+	svc.static = h
+	return nil
+}
@@ -0,0 +1,84 @@
+package faucet
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestStaticSite(t *testing.T) {
+	// The serving itself is covered by the webui package, this only checks
+	// that the service serves the web root with its runtime configuration.
+	webRoot := t.TempDir()
+	for name, content := range map[string]string{
+		"index.html":       "<html><head><title>Faucet</title></head><body></body></html>",
+		"main.6f8e4f1a.js": "console.log('faucet');",
+	} {
+		path := filepath.Join(webRoot, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	svc, _ := newTestService(t, &faucetConfig.Config{
+		MaxConsensusFundAmount: testQuantity(t, "100000000000"),
+		WebRoot:                webRoot,
+		RecaptchaSiteKey:       "site-key",
+	})
+	handler := svc.Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Index", func(t *testing.T) {
+		for _, path := range []string{"/", "/request/sapphire"} {
+			if w := get(path); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<title>Faucet</title>") {
+				t.Fatalf("%s: unexpected response %d: %s", path, w.Code, w.Body)
+			}
+		}
+
+		w := get("/")
+		body := w.Body.String()
+		start := strings.Index(body, `<script id="faucet-config" type="application/json">`)
+		end := strings.Index(body, "</head>")
+		if start < 0 || end < start {
+			t.Fatalf("runtime config not injected: %s", body)
+		}
+		var cfg api.FrontendConfig
+		configJSON := strings.TrimSuffix(body[strings.Index(body[start:], ">")+start+1:end], "</script>")
+		if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+			t.Fatalf("failed to decode runtime config: %v", err)
+		}
+		if cfg.CaptchaSiteKey != "site-key" || len(cfg.ParaTimes) != len(svc.network.ParaTimes.All) {
+			t.Errorf("unexpected runtime config: %+v", cfg)
+		}
+	})
+
+	t.Run("Assets", func(t *testing.T) {
+		if w := get("/main.6f8e4f1a.js"); w.Code != http.StatusOK || w.Body.String() != "console.log('faucet');" {
+			t.Fatalf("unexpected response %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		for _, path := range []string{"/missing.js", "/api/v1/missing"} {
+			if w := get(path); w.Code != http.StatusNotFound {
+				t.Errorf("%s: unexpected status code %d", path, w.Code)
+			}
+		}
+	})
+}
@@ -0,0 +1,341 @@
+package faucet
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+)
+
+const (
+	queryRequestID = "id"
+
+	// requestStatusRetention is how long the status of completed requests
+	// is retained for.
+	requestStatusRetention = 1 * time.Hour
+	// maxRecentPayouts is the number of payouts retained for the public
+	// payout feed.
+	maxRecentPayouts = 50
+	// streamKeepAliveInterval is the interval at which keep-alive comments
+	// are sent on event streams.
+	streamKeepAliveInterval = 15 * time.Second
+)
+
+type trackedRequest struct {
+	status api.RequestStatus
+	subs   map[chan api.RequestStatus]struct{}
+}
+
+// RequestTracker keeps track of the progress of funding requests, and of
+// the recent payouts.
+type RequestTracker struct {
+	lock sync.Mutex
+
+	requests map[string]*trackedRequest
+
+	payouts    []api.Payout
+	payoutSubs map[chan api.Payout]struct{}
+}
+
+// NewRequestTracker creates a new request tracker.
+func NewRequestTracker() *RequestTracker {
+	return &RequestTracker{
+		requests:   make(map[string]*trackedRequest),
+		payoutSubs: make(map[chan api.Payout]struct{}),
+	}
+}
+
+// New starts tracking a new request, and returns its ID.
+func (t *RequestTracker) New() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Prune completed requests that nobody cares about anymore.
+	now := time.Now()
+	for k, v := range t.requests {
+		if v.status.State.IsTerminal() && now.Sub(v.status.Updated) > requestStatusRetention {
+			delete(t.requests, k)
+		}
+	}
+
+	t.requests[id] = &trackedRequest{
+		status: api.RequestStatus{
+			ID:      id,
+			State:   api.RequestQueued,
+			Updated: now,
+		},
+		subs: make(map[chan api.RequestStatus]struct{}),
+	}
+	return id
+}
+
+// Update transitions a request to a new state, and notifies subscribers.
+// The optional fn can be used to update the other status fields.
+func (t *RequestTracker) Update(id string, state api.RequestState, fn func(*api.RequestStatus)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tr := t.requests[id]
+	if tr == nil || tr.status.State.IsTerminal() {
+		return
+	}
+
+	tr.status.State = state
+	tr.status.Updated = time.Now()
+	if fn != nil {
+		fn(&tr.status)
+	}
+
+	for ch := range tr.subs {
+		select {
+		case ch <- tr.status:
+		default:
+			// Slow subscribers can always fetch the latest status.
+		}
+		if state.IsTerminal() {
+			close(ch)
+			delete(tr.subs, ch)
+		}
+	}
+}
+
+// Fail transitions a request to the failed state.
+func (t *RequestTracker) Fail(id string, reason error) {
+	t.Update(id, api.RequestFailed, func(st *api.RequestStatus) {
+		st.Reason = reason.Error()
+	})
+}
+
+// Get returns the current status of a request.
+func (t *RequestTracker) Get(id string) (api.RequestStatus, bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tr := t.requests[id]
+	if tr == nil {
+		return api.RequestStatus{}, false
+	}
+	return tr.status, true
+}
+
+// Subscribe subscribes to the status updates of a request.  The current
+// status is delivered first, and the channel is closed once the request
+// reaches a terminal state.
+func (t *RequestTracker) Subscribe(id string) (<-chan api.RequestStatus, func(), bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	tr := t.requests[id]
+	if tr == nil {
+		return nil, nil, false
+	}
+
+	ch := make(chan api.RequestStatus, 8)
+	ch <- tr.status
+	if tr.status.State.IsTerminal() {
+		close(ch)
+		return ch, func() {}, true
+	}
+	tr.subs[ch] = struct{}{}
+
+	return ch, func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		if _, ok := tr.subs[ch]; ok {
+			delete(tr.subs, ch)
+			close(ch)
+		}
+	}, true
+}
+
+// AddPayout records a successful payout, and notifies subscribers.
+func (t *RequestTracker) AddPayout(payout api.Payout) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.payouts = append(t.payouts, payout)
+	if len(t.payouts) > maxRecentPayouts {
+		t.payouts = t.payouts[len(t.payouts)-maxRecentPayouts:]
+	}
+
+	for ch := range t.payoutSubs {
+		select {
+		case ch <- payout:
+		default:
+		}
+	}
+}
+
+// RecentPayouts returns the recent payouts, most recent first.
+func (t *RequestTracker) RecentPayouts() []api.Payout {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	payouts := make([]api.Payout, 0, len(t.payouts))
+	for i := len(t.payouts) - 1; i >= 0; i-- {
+		payouts = append(payouts, t.payouts[i])
+	}
+	return payouts
+}
+
+// SubscribePayouts subscribes to new payouts.
+func (t *RequestTracker) SubscribePayouts() (<-chan api.Payout, func()) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ch := make(chan api.Payout, 8)
+	t.payoutSubs[ch] = struct{}{}
+
+	return ch, func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+
+		delete(t.payoutSubs, ch)
+	}
+}
+
+// anonymizeAccount shortens an account address so that it can be shown
+// publicly without identifying the recipient.
+func anonymizeAccount(account string) string {
+	if len(account) <= 12 {
+		return strings.Repeat("*", len(account))
+	}
+	return account[:8] + "..." + account[len(account)-4:]
+}
+
+// requestIDOf returns the request ID of a status query, taken from the
+// path if the endpoint has an `{id}` wildcard, or the query otherwise.
+func requestIDOf(req *http.Request) string {
+	if id := req.PathValue(queryRequestID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(req.URL.Query().Get(queryRequestID))
+}
+
+// startEventStream prepares the response for Server-Sent Events.
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	clearDeadlines(w)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return flusher, true
+}
+
+// writeEvent writes a single Server-Sent Event.
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event string, v interface{}) {
+	b, _ := json.Marshal(v)
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	flusher.Flush()
+}
+
+// OnStatusRequest handles a request status query.  The expected request is
+// a GET of the form `https://host:port/api/v1/status?id=REQUEST_ID`.
+func (svc *Service) OnStatusRequest(w http.ResponseWriter, req *http.Request) {
+	id := requestIDOf(req)
+	status, ok := svc.requests.Get(id)
+	if !ok {
+		writeError(w, newAPIError(http.StatusNotFound, api.ErrCodeNotFound, queryRequestID, "unknown request id: '%v'", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, &status)
+}
+
+// OnStatusStream streams the progress of a funding request via
+// Server-Sent Events.  The expected request is a GET of the form
+// `https://host:port/api/v1/status/stream?id=REQUEST_ID`.
+func (svc *Service) OnStatusStream(w http.ResponseWriter, req *http.Request) {
+	id := requestIDOf(req)
+	ch, unsubscribeFn, ok := svc.requests.Subscribe(id)
+	if !ok {
+		writeError(w, newAPIError(http.StatusNotFound, api.ErrCodeNotFound, queryRequestID, "unknown request id: '%v'", id))
+		return
+	}
+	defer unsubscribeFn()
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		writeError(w, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	keepAliveTicker := time.NewTicker(streamKeepAliveInterval)
+	defer keepAliveTicker.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-svc.stopCh:
+			// Let the server shut down without waiting for the client.
+			return
+		case <-keepAliveTicker.C:
+			_, _ = fmt.Fprintf(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case status, ok := <-ch:
+			if !ok {
+				// Updates may have been dropped, so always send the
+				// final status.
+				if status, ok = svc.requests.Get(id); ok {
+					writeEvent(w, flusher, "status", &status)
+				}
+				return
+			}
+			writeEvent(w, flusher, "status", &status)
+		}
+	}
+}
+
+// OnPayoutsRequest returns the recent anonymized payouts.  If the client
+// accepts `text/event-stream`, new payouts are streamed as they happen.
+func (svc *Service) OnPayoutsRequest(w http.ResponseWriter, req *http.Request) {
+	if !strings.Contains(req.Header.Get("Accept"), "text/event-stream") {
+		writeJSON(w, http.StatusOK, svc.requests.RecentPayouts())
+		return
+	}
+
+	ch, unsubscribeFn := svc.requests.SubscribePayouts()
+	defer unsubscribeFn()
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		writeError(w, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	keepAliveTicker := time.NewTicker(streamKeepAliveInterval)
+	defer keepAliveTicker.Stop()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-svc.stopCh:
+			// Let the server shut down without waiting for the client.
+			return
+		case <-keepAliveTicker.C:
+			_, _ = fmt.Fprintf(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case payout := <-ch:
+			writeEvent(w, flusher, "payout", &payout)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package faucet
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval is how often the certificate files are checked for
+// changes.
+const certCheckInterval = 10 * time.Second
+
+// certReloader serves a certificate from files, reloading it when the
+// files change.
+type certReloader struct {
+	lock sync.Mutex
+
+	certFile string
+	keyFile  string
+
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+	interval  time.Duration
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: certCheckInterval,
+	}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// filesModTime returns the latest modification time of the files.
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// reload reloads the certificate if the files have changed.  The caller
+// must hold the lock, unless the reloader is being created.
+func (r *certReloader) reload() error {
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	if r.cert != nil && modTime.Equal(r.modTime) {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// GetCertificate returns the current certificate, checking the files for
+// changes at most once per interval.  If reloading fails (eg: as the files
+// are being replaced), the previous certificate is served.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastCheck) >= r.interval {
+		r.lastCheck = now
+		_ = r.reload()
+	}
+	return r.cert, nil
+}
+
+// newACMEManager creates the ACME certificate manager.
+func (svc *Service) newACMEManager() (*autocert.Manager, error) {
+	acmeCfg := &svc.cfg.ACME
+
+	client := &acme.Client{
+		DirectoryURL: acmeCfg.DirectoryURL,
+	}
+	if client.DirectoryURL == "" {
+		client.DirectoryURL = autocert.DefaultACMEDirectory
+	}
+	if acmeCfg.DirectoryCAFile != "" {
+		pem, err := os.ReadFile(acmeCfg.DirectoryCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ACME directory CA: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("malformed ACME directory CA")
+		}
+		client.HTTPClient = &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots},
+			},
+		}
+	}
+
+	cacheDir := acmeCfg.CacheDir
+	if cacheDir == "" {
+		cacheDir = filepath.Join(svc.cfg.DataDir, "acme")
+	}
+
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(acmeCfg.Domains...),
+		Client:     client,
+		Email:      acmeCfg.Email,
+	}, nil
+}
+
+// initTLS sets up the certificate source, either the ACME manager or the
+// certificate files, if TLS is enabled.
+func (svc *Service) initTLS() error {
+	switch {
+	case svc.cfg.ACME.Enabled():
+		m, err := svc.newACMEManager()
+		if err != nil {
+			return err
+		}
+		svc.acme = m
+		svc.getCertificate = m.GetCertificate
+	case svc.cfg.TLSCertFile != "":
+		r, err := newCertReloader(svc.cfg.TLSCertFile, svc.cfg.TLSKeyFile)
+		if err != nil {
+			return err
+		}
+		svc.getCertificate = r.GetCertificate
+	}
+	return nil
+}
+
+// serverTLSConfig returns the TLS configuration of the API servers, or nil
+// if TLS is disabled.  The HTTP server additionally answers ACME
+// TLS-ALPN-01 challenges.
+func (svc *Service) serverTLSConfig(isHTTP bool) *tls.Config {
+	if svc.getCertificate == nil {
+		return nil
+	}
+
+	tlsCfg := svc.cfg.HTTP.TLS.Config()
+	tlsCfg.GetCertificate = svc.getCertificate
+	if isHTTP && svc.acme != nil {
+		tlsCfg.NextProtos = append(tlsCfg.NextProtos, acme.ALPNProto)
+	}
+	return tlsCfg
+}
+
+// redirectHandler redirects all requests to HTTPS, on the port of the
+// public API listener.
+func (svc *Service) redirectHandler() http.Handler {
+	_, port, _ := net.SplitHostPort(svc.cfg.PublicListenAddr())
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// startRedirectServer starts the plaintext listener that redirects to
+// HTTPS and answers ACME HTTP-01 challenges, if configured.
+func (svc *Service) startRedirectServer() (*http.Server, error) {
+	if svc.cfg.RedirectListenAddr == "" {
+		return nil, nil
+	}
+
+	handler := svc.redirectHandler()
+	if svc.acme != nil {
+		handler = svc.acme.HTTPHandler(handler)
+	}
+	readHeaderTimeout, readTimeout, writeTimeout, idleTimeout := svc.cfg.HTTP.Timeouts()
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		MaxHeaderBytes:    svc.cfg.HTTP.HeaderLimit(),
+		ErrorLog:          svc.log,
+	}
+	ln, err := svc.listen(svc.cfg.RedirectListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen: %w", err)
+	}
+	go func() {
+		if err := srv.Serve(ln); err != http.ErrServerClosed {
+			svc.log.Printf("frontend/redirect: failed to serve: %v", err)
+		}
+	}()
+	svc.log.Printf("frontend/redirect: redirecting to HTTPS on %v", ln.Addr())
+
+	return srv, nil
+}
@@ -0,0 +1,137 @@
+package faucet
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+// writeTestCert writes a self-signed certificate for the common name, and
+// its key, to the directory.
+func writeTestCert(t *testing.T, dir, commonName string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for path, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: der},
+		keyFile:  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	} {
+		if err = os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+	return certFile, keyFile
+}
+
+func TestTLS(t *testing.T) {
+	t.Run("Reload", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := writeTestCert(t, dir, "old.example.com")
+		r, err := newCertReloader(certFile, keyFile)
+		if err != nil {
+			t.Fatalf("failed to load certificate: %v", err)
+		}
+		r.interval = 0
+
+		commonName := func() string {
+			cert, err := r.GetCertificate(nil)
+			if err != nil {
+				t.Fatalf("failed to get certificate: %v", err)
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatalf("failed to parse certificate: %v", err)
+			}
+			return leaf.Subject.CommonName
+		}
+		if cn := commonName(); cn != "old.example.com" {
+			t.Fatalf("certificate: got %v, expected old.example.com", cn)
+		}
+
+		writeTestCert(t, dir, "new.example.com")
+		later := time.Now().Add(time.Minute)
+		for _, path := range []string{certFile, keyFile} {
+			if err = os.Chtimes(path, later, later); err != nil {
+				t.Fatalf("failed to touch %s: %v", path, err)
+			}
+		}
+		if cn := commonName(); cn != "new.example.com" {
+			t.Fatalf("certificate: got %v, expected new.example.com", cn)
+		}
+
+		// A broken certificate keeps the previous one in service.
+		if err = os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+			t.Fatalf("failed to write certificate: %v", err)
+		}
+		if cn := commonName(); cn != "new.example.com" {
+			t.Fatalf("certificate: got %v, expected new.example.com", cn)
+		}
+	})
+
+	t.Run("ACME", func(t *testing.T) {
+		svc, _ := newTestService(t, &faucetConfig.Config{
+			DataDir:            t.TempDir(),
+			ListenAddr:         ":8443",
+			RedirectListenAddr: ":8080",
+			ACME: faucetConfig.ACMEConfig{
+				Domains:      []string{"faucet.example.com"},
+				DirectoryURL: "https://localhost:14000/dir",
+			},
+		})
+		if svc.acme == nil || svc.acme.Client.DirectoryURL != "https://localhost:14000/dir" {
+			t.Fatalf("acme: unexpected manager: %+v", svc.acme)
+		}
+		hasALPN := func(tlsCfg *tls.Config) bool {
+			for _, proto := range tlsCfg.NextProtos {
+				if proto == acme.ALPNProto {
+					return true
+				}
+			}
+			return false
+		}
+		if !hasALPN(svc.serverTLSConfig(true)) || hasALPN(svc.serverTLSConfig(false)) {
+			t.Errorf("acme: TLS-ALPN-01 must only be answered by the HTTP server")
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "http://faucet.example.com/api/v1/payouts?x=1", nil)
+		w := httptest.NewRecorder()
+		svc.acme.HTTPHandler(svc.redirectHandler()).ServeHTTP(w, req)
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != "https://faucet.example.com:8443/api/v1/payouts?x=1" {
+			t.Fatalf("redirect: unexpected response %d: %v", w.Code, w.Header())
+		}
+	})
+}
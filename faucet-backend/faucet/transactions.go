@@ -0,0 +1,534 @@
+package faucet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oasisprotocol/oasis-core/go/common/cbor"
+	"github.com/oasisprotocol/oasis-core/go/common/crypto/hash"
+	consensusSignature "github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
+	"github.com/oasisprotocol/oasis-core/go/common/quantity"
+	consensus "github.com/oasisprotocol/oasis-core/go/consensus/api"
+	consensusTx "github.com/oasisprotocol/oasis-core/go/consensus/api/transaction"
+	"github.com/oasisprotocol/oasis-core/go/consensus/api/transaction/results"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/crypto/signature/ed25519"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+// ConsensusTxResult is the outcome of a consensus transaction that was
+// included in a block.
+type ConsensusTxResult struct {
+	Hash   hash.Hash
+	Height int64
+	Fee    quantity.Quantity
+	Result *results.Result
+
+	// DryRun is set if the transaction was not submitted.
+	DryRun bool
+
+	// SubmitLatency is the time taken to submit the transaction.
+	SubmitLatency time.Duration
+	// InclusionLatency is the time taken from submission to inclusion.
+	InclusionLatency time.Duration
+}
+
+// consensusTxAttempts are the attempts at submitting a consensus
+// transaction.  The attempts share the nonce, so at most one of them can
+// be included, but it need not be the last one.
+type consensusTxAttempts struct {
+	blkCh    <-chan int64
+	cancelFn context.CancelFunc
+
+	// fees are the fees of the signed attempts, by transaction hash.
+	fees map[hash.Hash]quantity.Quantity
+}
+
+// close stops watching blocks.
+func (a *consensusTxAttempts) close() {
+	if a.cancelFn != nil {
+		a.cancelFn()
+	}
+}
+
+// RuntimeTxResult is the outcome of a runtime transaction that was
+// included in a block.  Hash and Result are unset if an earlier attempt
+// of the transaction was included.
+type RuntimeTxResult struct {
+	Hash   hash.Hash
+	Round  uint64
+	Fee    types.BaseUnits
+	Result types.CallResult
+
+	// DryRun is set if the transaction was not submitted.
+	DryRun bool
+}
+
+// runtimeTxAttempts are the attempts at submitting a runtime
+// transaction.  The attempts share the nonce, so at most one of them can
+// be executed, but it need not be the last one.
+type runtimeTxAttempts struct {
+	nonce uint64
+	// round is the latest round prior to the first submission.
+	round uint64
+
+	// fees are the fees of the signed attempts, by transaction hash.
+	fees map[hash.Hash]types.BaseUnits
+}
+
+// RuntimeEventMatcher returns true iff the decoded event is the one
+// that is being waited for.
+type RuntimeEventMatcher func(ev client.DecodedEvent) bool
+
+// RuntimeEventWatcher is a pending wait for a runtime event.  ResultCh
+// yields the matching event, or is closed without yielding anything if
+// the wait failed, in which case Context holds the reason.
+type RuntimeEventWatcher struct {
+	Context  context.Context
+	ResultCh <-chan client.DecodedEvent
+}
+
+// One would think that the SDK would have nice helpers for doing this,
+// since it is a common operation.  Instead pretend that we are DeFi DEX
+// developers writing solidity, and copy-paste a shitload of code out of
+// the cli.
+//
+// If I had known that I would need to do this, I would have named the
+// binary along the lines of `ponzu-faucet` or `faucet-swap`.
+
+func (svc *Service) SignAndSubmitConsensusTx(
+	ctx context.Context,
+	backend chain.Backend,
+	tx *consensusTx.Transaction,
+	reqID string,
+	attempts *consensusTxAttempts,
+) (*ConsensusTxResult, error) {
+	// Query the current account nonce.  This in theory could be done once
+	// and just incremented, but the faucet probably won't have enough load
+	// to where this is a big deal.
+	//
+	// Retries reuse the nonce of the initial attempt, so that at most one
+	// of the attempts can be executed.
+	attempt := len(attempts.fees)
+	if attempt == 0 {
+		account, err := backend.ConsensusAccount(ctx, svc.address)
+		if err != nil {
+			svc.log.Printf("tx/consensus: failed to query nonce: %v", err)
+			return nil, fmt.Errorf("failed to query nonce")
+		}
+		tx.Nonce = account.General.Nonce
+	}
+
+	// Estimate gas.
+	gas, err := backend.EstimateConsensusGas(ctx, &consensus.EstimateGasRequest{
+		Signer:      svc.signer.Public(),
+		Transaction: tx,
+	})
+	if err != nil {
+		svc.log.Printf("tx/consensus: failed to estimate gas: %v", err)
+		return nil, fmt.Errorf("failed to estimate gas")
+	}
+	tx.Fee.Gas = consensusTx.Gas(svc.cfg.Fees.ScaleGas(uint64(gas)))
+	tx.Fee.Amount = *svc.consensusTxFee(tx.Fee.Gas, attempt)
+
+	// Sign the transaction.
+	sigCtx := consensusSignature.Context([]byte(
+		fmt.Sprintf("%s for chain %s", consensusTx.SignatureContext, svc.network.ChainContext),
+	))
+	signedTx, err := consensusSignature.SignSigned(svc.signer, sigCtx, tx)
+	if err != nil {
+		svc.log.Printf("tx/consensus: failed to sign transaction: %v", err)
+		return nil, fmt.Errorf("failed to sign transaction")
+	}
+	sigTx := &consensusTx.SignedTransaction{
+		Signed: *signedTx,
+	}
+	txHash := sigTx.Hash()
+	svc.requests.Update(reqID, api.RequestSigned, func(st *api.RequestStatus) {
+		st.TxHash = txHash.String()
+		if svc.cfg.DryRun {
+			st.DryRun = true
+			st.SignedTx = cbor.Marshal(sigTx)
+		}
+	})
+
+	if svc.cfg.DryRun {
+		svc.log.Printf("tx/consensus: DRY RUN: not submitting transaction %s", txHash)
+		return &ConsensusTxResult{
+			Hash:   txHash,
+			Fee:    tx.Fee.Amount,
+			DryRun: true,
+		}, nil
+	}
+
+	// Start watching blocks prior to the first submission, so that the
+	// block that includes any of the attempts can not be missed.
+	if attempts.blkCh == nil {
+		watchCtx, cancelFn := context.WithCancel(ctx)
+		blkCh, err := backend.WatchConsensusBlocks(watchCtx)
+		if err != nil {
+			cancelFn()
+			svc.log.Printf("tx/consensus: failed to watch blocks: %v", err)
+			return nil, fmt.Errorf("failed to watch blocks")
+		}
+		attempts.blkCh, attempts.cancelFn = blkCh, cancelFn
+		attempts.fees = make(map[hash.Hash]quantity.Quantity)
+	}
+
+	// Record the attempt before submitting it, as even a failed
+	// submission may have reached the mempool.
+	attempts.fees[txHash] = tx.Fee.Amount
+
+	waitCtx, cancelFn := context.WithTimeout(ctx, faucetConfig.RequestTimeout)
+	defer cancelFn()
+
+	// Submit the transaction.
+	start := time.Now()
+	if err = backend.SubmitConsensusTx(ctx, sigTx); err != nil {
+		svc.log.Printf("tx/consensus: failed to submit transaction: %v", err)
+
+		// If the nonce was used, one of the attempts was executed after
+		// all, and is waited for instead.
+		account, nonceErr := backend.ConsensusAccount(waitCtx, svc.address)
+		if nonceErr != nil || account.General.Nonce <= tx.Nonce {
+			return nil, errTxSubmitFailed
+		}
+		svc.log.Printf("tx/consensus: nonce %d already used, waiting for an earlier attempt", tx.Nonce)
+	}
+	txResult := &ConsensusTxResult{
+		SubmitLatency: time.Since(start),
+	}
+	svc.requests.Update(reqID, api.RequestSubmitted, nil)
+
+	// Wait for one of the attempts to be included in a block.
+	for {
+		var (
+			height int64
+			ok     bool
+		)
+		select {
+		case <-waitCtx.Done():
+			svc.log.Printf("tx/consensus: context canceled, transaction %s timed out", txHash)
+			return nil, errTxNotIncluded
+		case height, ok = <-attempts.blkCh:
+			if !ok {
+				svc.log.Printf("tx/consensus: block channel closed unexpectedly")
+				return nil, errTxNotIncluded
+			}
+		}
+
+		txs, err := backend.ConsensusTransactionsWithResults(waitCtx, height)
+		if err != nil {
+			svc.log.Printf("tx/consensus: failed to query transactions at height %d: %v", height, err)
+			return nil, errTxNotIncluded
+		}
+		for i, rawTx := range txs.Transactions {
+			h := hash.NewFromBytes(rawTx)
+			fee, ok := attempts.fees[h]
+			if !ok {
+				continue
+			}
+
+			txResult.Hash = h
+			txResult.Height = height
+			txResult.Fee = fee
+			txResult.InclusionLatency = time.Since(start)
+			txResult.Result = txs.Results[i]
+			svc.metrics.FeesSpent.WithLabelValues("consensus").Add(metricValue(&txResult.Fee))
+			if !txResult.Result.IsSuccess() {
+				svc.log.Printf("tx/consensus: transaction %s failed with error: module: %s code: %d message: %s",
+					h,
+					txResult.Result.Error.Module,
+					txResult.Result.Error.Code,
+					txResult.Result.Error.Message,
+				)
+				return nil, fmt.Errorf("failed to execute transaction")
+			}
+			svc.requests.Update(reqID, api.RequestIncluded, func(st *api.RequestStatus) {
+				st.TxHash = h.String()
+				st.Height = height
+			})
+			return txResult, nil
+		}
+	}
+}
+
+// SignAndSubmitRuntimeTx signs and submits a runtime transaction, and
+// waits for it to be included in a block.  An error is returned if the
+// transaction failed.
+func (svc *Service) SignAndSubmitRuntimeTx(
+	ctx context.Context,
+	backend chain.Backend,
+	pt *config.ParaTime,
+	tx *types.Transaction,
+	reqID string,
+	attempts *runtimeTxAttempts,
+) (*RuntimeTxResult, error) {
+	// Query the current account nonce.  Retries reuse the nonce of the
+	// initial attempt, so that at most one of the attempts can be executed.
+	attempt := len(attempts.fees)
+	tx.AuthInfo.SignerInfo = nil
+	if attempt == 0 {
+		nonce, err := backend.RuntimeNonce(ctx, pt, types.NewAddressFromConsensus(svc.address))
+		if err != nil {
+			svc.log.Printf("tx/meta: failed to query nonce: %v", err)
+			return nil, fmt.Errorf("failed to query nonce")
+		}
+		attempts.nonce = nonce
+	}
+
+	// Estimate gas.
+	tx.AppendAuthSignature(
+		types.NewSignatureAddressSpecEd25519(ed25519.PublicKey(svc.signer.Public())),
+		attempts.nonce,
+	)
+	var err error
+	tx.AuthInfo.Fee.Gas, err = backend.EstimateRuntimeGas(ctx, pt, tx)
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to estimate gas: %v", err)
+		return nil, fmt.Errorf("failed to estimate gas")
+	}
+	tx.AuthInfo.Fee.Gas = svc.cfg.Fees.ScaleGas(tx.AuthInfo.Fee.Gas)
+
+	// Compute the fee.
+	gasPrice, err := svc.paraTimeGasPrice(ctx, backend, pt, attempt)
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to query gas price: %v", err)
+		return nil, fmt.Errorf("failed to query gas price")
+	}
+	tx.AuthInfo.Fee.Amount = types.NewBaseUnits(*totalFee(tx.AuthInfo.Fee.Gas, gasPrice), types.NativeDenomination)
+
+	chainContext, err := backend.ChainContext(ctx)
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to get ChainContext: %v", err)
+		return nil, fmt.Errorf("failed to get ChainContext")
+	}
+
+	// Sign the transaction.
+	sigCtx := &signature.RichContext{
+		RuntimeID:    pt.Namespace(),
+		ChainContext: chainContext,
+		Base:         types.SignatureContextBase,
+	}
+	ts := tx.PrepareForSigning()
+	if err := ts.AppendSign(signature.Context(sigCtx), ed25519.WrapSigner(svc.signer)); err != nil {
+		svc.log.Printf("tx/meta: failed to sign transaction: %v", err)
+		return nil, fmt.Errorf("failed to sign transaction")
+	}
+
+	// Submit the transaction, and wait for the result.
+	submitCtx, cancelFn := context.WithTimeout(ctx, faucetConfig.RequestTimeout)
+	defer cancelFn()
+
+	signedTx := ts.UnverifiedTransaction()
+	txHash := signedTx.Hash()
+	svc.requests.Update(reqID, api.RequestSigned, func(st *api.RequestStatus) {
+		st.TxHash = txHash.String()
+		if svc.cfg.DryRun {
+			st.DryRun = true
+			st.SignedTx = cbor.Marshal(signedTx)
+		}
+	})
+
+	if svc.cfg.DryRun {
+		svc.log.Printf("tx/meta: DRY RUN: not submitting transaction %s", txHash)
+		return &RuntimeTxResult{
+			Hash:   txHash,
+			Fee:    tx.AuthInfo.Fee.Amount,
+			DryRun: true,
+		}, nil
+	}
+
+	// Remember the round prior to the first submission, so that the
+	// events of any of the attempts can be found.
+	if attempts.fees == nil {
+		round, err := backend.LatestRuntimeRound(ctx, pt)
+		if err != nil {
+			svc.log.Printf("tx/meta: failed to query latest round: %v", err)
+			return nil, fmt.Errorf("failed to query latest round")
+		}
+		attempts.round = round
+		attempts.fees = make(map[hash.Hash]types.BaseUnits)
+	}
+
+	// Record the attempt before submitting it, as even a failed
+	// submission may have reached the mempool.
+	attempts.fees[txHash] = tx.AuthInfo.Fee.Amount
+
+	meta, err := backend.SubmitRuntimeTx(submitCtx, pt, signedTx)
+	switch {
+	case err != nil:
+		svc.log.Printf("tx/meta: failed to submit transaction: %v", err)
+		if !svc.runtimeNonceUsed(ctx, backend, pt, attempts) {
+			return nil, errTxSubmitFailed
+		}
+		return svc.earlierRuntimeTxResult(reqID, tx, attempts), nil
+	case meta.CheckTxError != nil:
+		svc.log.Printf("tx/meta: transaction check failed with error: module: %s code: %d message: %s",
+			meta.CheckTxError.Module,
+			meta.CheckTxError.Code,
+			meta.CheckTxError.Message,
+		)
+		if attempt == 0 || !svc.runtimeNonceUsed(ctx, backend, pt, attempts) {
+			return nil, fmt.Errorf("failed to check meta transaction")
+		}
+		return svc.earlierRuntimeTxResult(reqID, tx, attempts), nil
+	}
+	svc.requests.Update(reqID, api.RequestSubmitted, nil)
+	svc.metrics.FeesSpent.WithLabelValues(svc.paratimeName(pt.ID)).Add(metricValue(&tx.AuthInfo.Fee.Amount.Amount))
+	if meta.Result.Failed != nil {
+		svc.log.Printf("tx/meta: transaction failed with error: %v", meta.Result.Failed)
+		return nil, fmt.Errorf("failed to execute meta transaction")
+	}
+
+	svc.requests.Update(reqID, api.RequestIncluded, func(st *api.RequestStatus) {
+		st.Round = meta.Round
+	})
+
+	return &RuntimeTxResult{
+		Hash:   txHash,
+		Round:  meta.Round,
+		Fee:    tx.AuthInfo.Fee.Amount,
+		Result: meta.Result,
+	}, nil
+}
+
+// runtimeNonceUsed returns true iff the nonce shared by the attempts was
+// used, in which case one of the attempts was executed.
+func (svc *Service) runtimeNonceUsed(
+	ctx context.Context,
+	backend chain.Backend,
+	pt *config.ParaTime,
+	attempts *runtimeTxAttempts,
+) bool {
+	nonce, err := backend.RuntimeNonce(ctx, pt, types.NewAddressFromConsensus(svc.address))
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to query nonce: %v", err)
+		return false
+	}
+	return nonce > attempts.nonce
+}
+
+// earlierRuntimeTxResult returns the result of a runtime transaction,
+// one of the earlier attempts of which was executed.  Which attempt, and
+// in what round, is not known, so the fee of the latest attempt, which
+// is an upper bound as fees are only ever bumped, and the round prior to
+// the first submission are reported.
+func (svc *Service) earlierRuntimeTxResult(reqID string, tx *types.Transaction, attempts *runtimeTxAttempts) *RuntimeTxResult {
+	svc.log.Printf("tx/meta: nonce %d already used, waiting for an earlier attempt", attempts.nonce)
+	svc.requests.Update(reqID, api.RequestSubmitted, nil)
+	return &RuntimeTxResult{
+		Round: attempts.round,
+		Fee:   tx.AuthInfo.Fee.Amount,
+	}
+}
+
+// WatchRuntimeEvent waits for the first event emitted at or after the
+// given round that satisfies match.  Rounds that were finalized before
+// the watch started are scanned as well, so it is safe to start waiting
+// after the transaction that triggers the event was included.
+//
+// The watch is bounded by faucetConfig.RequestTimeout, and ResultCh is buffered so
+// the watch does not leak if nothing reads the result.
+func (svc *Service) WatchRuntimeEvent(
+	ctx context.Context,
+	backend chain.Backend,
+	pt *config.ParaTime,
+	round uint64,
+	decoders []client.EventDecoder,
+	match RuntimeEventMatcher,
+) (*RuntimeEventWatcher, error) {
+	var watchOk bool
+	watchCtx, cancelFn := context.WithTimeout(ctx, faucetConfig.RequestTimeout)
+	defer func() {
+		if !watchOk {
+			cancelFn()
+		}
+	}()
+
+	// Subscribe before querying the latest round, so that no rounds can
+	// be missed between the backfill and the subscription.
+	roundCh, err := backend.WatchRuntimeRounds(watchCtx, pt)
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to watch blocks: %v", err)
+		return nil, fmt.Errorf("failed to watch blocks")
+	}
+	latestRound, err := backend.LatestRuntimeRound(watchCtx, pt)
+	if err != nil {
+		svc.log.Printf("tx/meta: failed to query latest block: %v", err)
+		return nil, fmt.Errorf("failed to query latest block")
+	}
+
+	resultCh := make(chan client.DecodedEvent, 1)
+	go func() {
+		defer close(resultCh)
+		defer cancelFn()
+
+		nextRound := round
+
+		// scanTo scans all rounds up to and including the given round for
+		// the matching event.
+		scanTo := func(toRound uint64) (client.DecodedEvent, error) {
+			for ; nextRound <= toRound; nextRound++ {
+				rawEvs, err := backend.RuntimeEvents(watchCtx, pt, nextRound)
+				if err != nil {
+					return nil, err
+				}
+				for _, rawEv := range rawEvs {
+					for _, decoder := range decoders {
+						evs, err := decoder.DecodeEvent(rawEv)
+						if err != nil {
+							return nil, err
+						}
+						for _, ev := range evs {
+							if match(ev) {
+								return ev, nil
+							}
+						}
+					}
+				}
+			}
+			return nil, nil
+		}
+
+		toRound := latestRound
+		for {
+			ev, err := scanTo(toRound)
+			switch {
+			case err != nil:
+				svc.log.Printf("tx/meta: failed to query events: %v", err)
+				return
+			case ev != nil:
+				resultCh <- ev
+				return
+			}
+
+			select {
+			case <-watchCtx.Done():
+				svc.log.Printf("tx/meta: context canceled, request timed out")
+				return
+			case round, ok := <-roundCh:
+				if !ok {
+					svc.log.Printf("tx/meta: block channel closed unexpectedly")
+					return
+				}
+				toRound = round
+			}
+		}
+	}()
+
+	watchOk = true
+
+	return &RuntimeEventWatcher{
+		Context:  watchCtx,
+		ResultCh: resultCh,
+	}, nil
+}
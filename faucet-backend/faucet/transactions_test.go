@@ -0,0 +1,90 @@
+package faucet
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/client"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/modules/consensusaccounts"
+	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/types"
+
+	"github.com/oasisprotocol/tools/faucet-backend/api"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+)
+
+func TestWatchRuntimeEvent(t *testing.T) {
+	cfg := &faucetConfig.Config{
+		TargetAllowance: testQuantity(t, "10000000000000"),
+	}
+	svc, memChain := newTestService(t, cfg)
+	startBank(t, svc)
+
+	code, resp := fund(t, svc, "sapphire", testAccountSapphire, "1")
+	if code != http.StatusOK {
+		t.Fatalf("fund: unexpected status code %d: %s", code, resp.Result)
+	}
+	if status := waitForRequest(t, svc, resp.RequestID); status.State != api.RequestConfirmed {
+		t.Fatalf("request state: got %v (%v), expected %v", status.State, status.Reason, api.RequestConfirmed)
+	}
+
+	pt := svc.network.ParaTimes.All["sapphire"]
+	decoders := []client.EventDecoder{chain.EventDecoderFunc(consensusaccounts.DecodeEvent)}
+	isDeposit := func(ev client.DecodedEvent) bool {
+		ce, ok := ev.(*consensusaccounts.Event)
+		return ok && ce.Deposit != nil && ce.Deposit.From.Equal(types.NewAddressFromConsensus(svc.address))
+	}
+	never := func(client.DecodedEvent) bool {
+		return false
+	}
+
+	t.Run("Backfill", func(t *testing.T) {
+		// The deposit was emitted before the watch started.
+		watcher, err := svc.WatchRuntimeEvent(context.Background(), memChain, pt, 0, decoders, isDeposit)
+		if err != nil {
+			t.Fatalf("WatchRuntimeEvent: %v", err)
+		}
+		select {
+		case ev, ok := <-watcher.ResultCh:
+			if !ok || !isDeposit(ev) {
+				t.Fatalf("unexpected result: %v (ok: %v, err: %v)", ev, ok, watcher.Context.Err())
+			}
+		case <-time.After(testRequestTimeout):
+			t.Fatalf("deposit event not found")
+		}
+	})
+
+	t.Run("Unread", func(t *testing.T) {
+		// Watches whose result is never read, whether they found the event
+		// or are still waiting for it, end once canceled.
+		goroutines := runtime.NumGoroutine()
+		ctx, cancelFn := context.WithCancel(context.Background())
+		var watchers []*RuntimeEventWatcher
+		for _, match := range []RuntimeEventMatcher{isDeposit, never} {
+			watcher, err := svc.WatchRuntimeEvent(ctx, memChain, pt, 0, decoders, match)
+			if err != nil {
+				t.Fatalf("WatchRuntimeEvent: %v", err)
+			}
+			watchers = append(watchers, watcher)
+		}
+		cancelFn()
+
+		for _, watcher := range watchers {
+			select {
+			case <-watcher.Context.Done():
+			case <-time.After(testRequestTimeout):
+				t.Fatalf("watch not canceled")
+			}
+		}
+		deadline := time.Now().Add(testRequestTimeout)
+		for runtime.NumGoroutine() > goroutines {
+			if time.Now().After(deadline) {
+				t.Fatalf("goroutines leaked: got %d, expected at most %d", runtime.NumGoroutine(), goroutines)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
@@ -0,0 +1,301 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: faucet.proto
+
+// Package oasis.faucet.v1 is the gRPC API of the Oasis testnet faucet.
+
+package faucetpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Faucet_Fund_FullMethodName         = "/oasis.faucet.v1.Faucet/Fund"
+	Faucet_FundBundle_FullMethodName   = "/oasis.faucet.v1.Faucet/FundBundle"
+	Faucet_GetRequest_FullMethodName   = "/oasis.faucet.v1.Faucet/GetRequest"
+	Faucet_WatchRequest_FullMethodName = "/oasis.faucet.v1.Faucet/WatchRequest"
+	Faucet_Info_FullMethodName         = "/oasis.faucet.v1.Faucet/Info"
+)
+
+// FaucetClient is the client API for Faucet service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FaucetClient interface {
+	// Fund submits a funding request.
+	Fund(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*FundResponse, error)
+	// FundBundle submits a bundle request, which funds every item of the
+	// named bundle to the account.
+	FundBundle(ctx context.Context, in *FundBundleRequest, opts ...grpc.CallOption) (*FundResponse, error)
+	// GetRequest returns the status of a funding request.
+	GetRequest(ctx context.Context, in *GetRequestRequest, opts ...grpc.CallOption) (*RequestStatus, error)
+	// WatchRequest streams the status of a funding request until it
+	// reaches a terminal state.
+	WatchRequest(ctx context.Context, in *WatchRequestRequest, opts ...grpc.CallOption) (Faucet_WatchRequestClient, error)
+	// Info returns the faucet's funding information.
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+}
+
+type faucetClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFaucetClient(cc grpc.ClientConnInterface) FaucetClient {
+	return &faucetClient{cc}
+}
+
+func (c *faucetClient) Fund(ctx context.Context, in *FundRequest, opts ...grpc.CallOption) (*FundResponse, error) {
+	out := new(FundResponse)
+	err := c.cc.Invoke(ctx, Faucet_Fund_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *faucetClient) FundBundle(ctx context.Context, in *FundBundleRequest, opts ...grpc.CallOption) (*FundResponse, error) {
+	out := new(FundResponse)
+	err := c.cc.Invoke(ctx, Faucet_FundBundle_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *faucetClient) GetRequest(ctx context.Context, in *GetRequestRequest, opts ...grpc.CallOption) (*RequestStatus, error) {
+	out := new(RequestStatus)
+	err := c.cc.Invoke(ctx, Faucet_GetRequest_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *faucetClient) WatchRequest(ctx context.Context, in *WatchRequestRequest, opts ...grpc.CallOption) (Faucet_WatchRequestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Faucet_ServiceDesc.Streams[0], Faucet_WatchRequest_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &faucetWatchRequestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Faucet_WatchRequestClient interface {
+	Recv() (*RequestStatus, error)
+	grpc.ClientStream
+}
+
+type faucetWatchRequestClient struct {
+	grpc.ClientStream
+}
+
+func (x *faucetWatchRequestClient) Recv() (*RequestStatus, error) {
+	m := new(RequestStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *faucetClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, Faucet_Info_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FaucetServer is the server API for Faucet service.
+// All implementations must embed UnimplementedFaucetServer
+// for forward compatibility
+type FaucetServer interface {
+	// Fund submits a funding request.
+	Fund(context.Context, *FundRequest) (*FundResponse, error)
+	// FundBundle submits a bundle request, which funds every item of the
+	// named bundle to the account.
+	FundBundle(context.Context, *FundBundleRequest) (*FundResponse, error)
+	// GetRequest returns the status of a funding request.
+	GetRequest(context.Context, *GetRequestRequest) (*RequestStatus, error)
+	// WatchRequest streams the status of a funding request until it
+	// reaches a terminal state.
+	WatchRequest(*WatchRequestRequest, Faucet_WatchRequestServer) error
+	// Info returns the faucet's funding information.
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	mustEmbedUnimplementedFaucetServer()
+}
+
+// UnimplementedFaucetServer must be embedded to have forward compatible implementations.
+type UnimplementedFaucetServer struct {
+}
+
+func (UnimplementedFaucetServer) Fund(context.Context, *FundRequest) (*FundResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Fund not implemented")
+}
+func (UnimplementedFaucetServer) FundBundle(context.Context, *FundBundleRequest) (*FundResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FundBundle not implemented")
+}
+func (UnimplementedFaucetServer) GetRequest(context.Context, *GetRequestRequest) (*RequestStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRequest not implemented")
+}
+func (UnimplementedFaucetServer) WatchRequest(*WatchRequestRequest, Faucet_WatchRequestServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRequest not implemented")
+}
+func (UnimplementedFaucetServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedFaucetServer) mustEmbedUnimplementedFaucetServer() {}
+
+// UnsafeFaucetServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FaucetServer will
+// result in compilation errors.
+type UnsafeFaucetServer interface {
+	mustEmbedUnimplementedFaucetServer()
+}
+
+func RegisterFaucetServer(s grpc.ServiceRegistrar, srv FaucetServer) {
+	s.RegisterService(&Faucet_ServiceDesc, srv)
+}
+
+func _Faucet_Fund_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaucetServer).Fund(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Faucet_Fund_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaucetServer).Fund(ctx, req.(*FundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Faucet_FundBundle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FundBundleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaucetServer).FundBundle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Faucet_FundBundle_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaucetServer).FundBundle(ctx, req.(*FundBundleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Faucet_GetRequest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequestRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaucetServer).GetRequest(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Faucet_GetRequest_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaucetServer).GetRequest(ctx, req.(*GetRequestRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Faucet_WatchRequest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequestRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(FaucetServer).WatchRequest(m, &faucetWatchRequestServer{stream})
+}
+
+type Faucet_WatchRequestServer interface {
+	Send(*RequestStatus) error
+	grpc.ServerStream
+}
+
+type faucetWatchRequestServer struct {
+	grpc.ServerStream
+}
+
+func (x *faucetWatchRequestServer) Send(m *RequestStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Faucet_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FaucetServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Faucet_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FaucetServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Faucet_ServiceDesc is the grpc.ServiceDesc for Faucet service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Faucet_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "oasis.faucet.v1.Faucet",
+	HandlerType: (*FaucetServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Fund",
+			Handler:    _Faucet_Fund_Handler,
+		},
+		{
+			MethodName: "FundBundle",
+			Handler:    _Faucet_FundBundle_Handler,
+		},
+		{
+			MethodName: "GetRequest",
+			Handler:    _Faucet_GetRequest_Handler,
+		},
+		{
+			MethodName: "Info",
+			Handler:    _Faucet_Info_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRequest",
+			Handler:       _Faucet_WatchRequest_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "faucet.proto",
+}
@@ -1,19 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
-	"sync"
 
 	"github.com/oasisprotocol/oasis-core/go/common/crypto/signature"
 	fileSigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/file"
+	memorySigner "github.com/oasisprotocol/oasis-core/go/common/crypto/signature/signers/memory"
 	staking "github.com/oasisprotocol/oasis-core/go/staking/api"
 
 	"github.com/oasisprotocol/oasis-sdk/client-sdk/go/config"
+
+	"github.com/oasisprotocol/tools/faucet-backend/access"
+	"github.com/oasisprotocol/tools/faucet-backend/chain"
+	faucetConfig "github.com/oasisprotocol/tools/faucet-backend/config"
+	"github.com/oasisprotocol/tools/faucet-backend/faucet"
+	"github.com/oasisprotocol/tools/faucet-backend/metrics"
 )
 
 // Note: As this is intended to be extremely simple, I am refraining from
@@ -26,32 +34,7 @@ import (
 // of tokens transfered per request, everything else is assumed to be handled
 // by the consumer of the API.
 
-type Service struct {
-	cfg     *Config
-	network *config.Network
-
-	address staking.Address
-	signer  signature.Signer
-
-	log     *log.Logger
-	metrics *FaucetMetrics
-
-	readyCh chan struct{}
-	quitCh  chan struct{}
-	doneCh  chan struct{}
-
-	fundRequestCh chan *FundRequest
-
-	dedupMap  map[string]bool
-	dedupLock sync.Mutex
-}
-
-func NewService(cfg *Config) (*Service, error) {
-	// Carve out the data directory.
-	if err := os.MkdirAll(cfg.DataDir, 0o700); err != nil {
-		return nil, fmt.Errorf("main: failed to create data dir: %w", err)
-	}
-
+func newLogger(cfg *faucetConfig.Config) (*log.Logger, error) {
 	var logWriter io.Writer
 
 	// By default we log to a file, but some environments like docker already
@@ -71,51 +54,96 @@ func NewService(cfg *Config) (*Service, error) {
 		logWriter = io.MultiWriter(os.Stdout, f)
 	}
 
-	// Load the signer.
-	factory, err := fileSigner.NewFactory(cfg.DataDir, signature.SignerEntity)
-	if err != nil {
-		return nil, fmt.Errorf("main: failed to create signer factory: %w", err)
+	return log.New(logWriter, "", log.LstdFlags), nil
+}
+
+func newService(cfg *faucetConfig.Config, logger *log.Logger, accessStore *access.Store) (*faucet.Service, error) {
+	network := config.DefaultNetworks.All["testnet"] // Yes, this is hardcoded.
+
+	opts := []faucet.Option{
+		faucet.WithLogger(logger),
+		faucet.WithMetrics(metrics.NewDefault()),
+		faucet.WithAccessStore(accessStore),
 	}
-	signer, err := factory.Load(signature.SignerEntity)
-	if err != nil {
-		return nil, fmt.Errorf("main: failed to load signer: %w", err)
+
+	var signer signature.Signer
+	switch cfg.MockChain {
+	case nil:
+		// Load the signer.
+		factory, err := fileSigner.NewFactory(cfg.DataDir, signature.SignerEntity)
+		if err != nil {
+			return nil, fmt.Errorf("main: failed to create signer factory: %w", err)
+		}
+		if signer, err = factory.Load(signature.SignerEntity); err != nil {
+			return nil, fmt.Errorf("main: failed to load signer: %w", err)
+		}
+	default:
+		// The simulated chain does not need a key, or funds.
+		var err error
+		if signer, err = memorySigner.NewFactory().Generate(signature.SignerEntity, rand.Reader); err != nil {
+			return nil, fmt.Errorf("main: failed to generate signer: %w", err)
+		}
+		mockChain, err := chain.NewMockChain(network, cfg.MockChain, staking.NewAddress(signer.Public()))
+		if err != nil {
+			return nil, fmt.Errorf("main: failed to initialize mock chain: %w", err)
+		}
+		opts = append(opts, faucet.WithChainBackend(mockChain))
 	}
 
-	return &Service{
-		cfg:           cfg,
-		network:       config.DefaultNetworks.All["testnet"], // Yes, this is hardcoded.
-		address:       staking.NewAddress(signer.Public()),
-		signer:        signer,
-		log:           log.New(logWriter, "", log.LstdFlags),
-		metrics:       NewDefaultFaucetMetrics(),
-		readyCh:       make(chan struct{}),
-		quitCh:        make(chan struct{}),
-		doneCh:        make(chan struct{}),
-		fundRequestCh: make(chan *FundRequest, 10),
-		dedupMap:      make(map[string]bool),
-	}, nil
+	return faucet.New(cfg, network, signer, opts...)
 }
 
 func main() {
 	cfgFile := flag.String("f", "faucet-backend.toml", "path to configuration file")
+	mockChain := flag.Bool("mock-chain", false, "run against a simulated chain, for development")
 	flag.Parse()
 
-	cfg, err := LoadConfig(*cfgFile)
+	cfg, err := faucetConfig.Load(*cfgFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "faucet-backend: failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
+	switch {
+	case !*mockChain:
+		cfg.MockChain = nil
+	case cfg.MockChain == nil:
+		cfg.MockChain = new(faucetConfig.MockChainConfig)
+	}
+
+	// Carve out the data directory.
+	if err = os.MkdirAll(cfg.DataDir, 0o700); err != nil {
+		fmt.Fprintf(os.Stderr, "faucet-backend: failed to create data dir: %v\n", err)
+		os.Exit(1)
+	}
+	logger, err := newLogger(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "faucet-backend: failed to initialize logging: %v\n", err)
+		os.Exit(1)
+	}
 
-	svc, err := NewService(cfg)
+	accessPath := cfg.Access.File
+	if accessPath == "" {
+		accessPath = filepath.Join(cfg.DataDir, "access.json")
+	}
+	accessStore, err := access.NewStore(accessPath, logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "faucet-backend: failed to load access lists: %v\n", err)
+		os.Exit(1)
+	}
+
+	svc, err := newService(cfg, logger, accessStore)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "faucet-backend: failed to initialize service: %v\n", err)
 		os.Exit(1)
 	}
-	svc.log.Printf("service initialized: address: %s", svc.address)
+	logger.Printf("service initialized: address: %s", svc.Address())
+	if cfg.MockChain != nil {
+		logger.Printf("MOCK CHAIN: running against a simulated chain, no tokens will be moved")
+	}
 
+	go accessStore.Watch(context.Background(), cfg.Access.WatchIntervalDuration())
 	go svc.BankWorker()
 	go svc.FrontendWorker()
-	go svc.MetricsWorker()
 
-	<-svc.doneCh
+	<-svc.Done()
 }
@@ -0,0 +1,162 @@
+// Package metrics implements the faucet prometheus metrics.
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultPullAddr is the default address at which the metrics are served.
+const DefaultPullAddr = "0.0.0.0:7000"
+
+var (
+	// Labels to use for partitioning requests.
+	requestLabels = []string{"endpoint", "status"}
+
+	// Labels to use for partitioning request latencies.
+	requestLatencyLabels = []string{"endpoint"}
+
+	// Labels to use for partitioning request stage latencies.
+	requestStageLatencyLabels = []string{"endpoint", "stage"}
+
+	// Labels to use for partitioning fees.
+	feeLabels = []string{"network"}
+
+	// Labels to use for partitioning balances.
+	balanceLabels = []string{"network"}
+
+	// Labels to use for partitioning payout limits.
+	limitLabels = []string{"network"}
+
+	// Labels to use for partitioning denied requests.
+	denialLabels = []string{"reason"}
+
+	// Labels to use for partitioning rate limited requests.
+	rateLimitLabels = []string{"limit"}
+)
+
+// FaucetMetrics are the faucet metrics.
+type FaucetMetrics struct {
+	// Counts of funding requests.
+	Requests *prometheus.CounterVec
+
+	// Latencies of requests.
+	RequestLatencies *prometheus.SummaryVec
+
+	// Latencies of the individual stages of requests.
+	RequestStageLatencies *prometheus.SummaryVec
+
+	// Current faucet balances.
+	Balances *prometheus.GaugeVec
+
+	// Transaction fees spent in base units.
+	FeesSpent *prometheus.CounterVec
+
+	// Current effective per-request maximum amounts in base units.
+	EffectiveMaxAmounts *prometheus.GaugeVec
+
+	// Current factors the per-request maximum amounts are scaled by due
+	// to low reserves.
+	PayoutFactors *prometheus.GaugeVec
+
+	// Counts of requests denied by the access lists.
+	DeniedRequests *prometheus.CounterVec
+
+	// Counts of requests rejected by the rate limits.
+	RateLimitedRequests *prometheus.CounterVec
+}
+
+// NewDefault creates the faucet metrics, and registers them with the
+// default registerer.
+func NewDefault() *FaucetMetrics {
+	return New(prometheus.DefaultRegisterer)
+}
+
+// New creates the faucet metrics, and registers them with the given
+// registerer.
+func New(reg prometheus.Registerer) *FaucetMetrics {
+	metrics := FaucetMetrics{
+		Requests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("faucet_requests"),
+				Help: fmt.Sprintf("How many requests were received, partitioned by endpoint and status"),
+			},
+			requestLabels,
+		),
+		RequestLatencies: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name: fmt.Sprintf("faucet_request_durations"),
+				Help: fmt.Sprintf("How long requests take to process, partitioned by endpoint"),
+			},
+			requestLatencyLabels,
+		),
+		RequestStageLatencies: prometheus.NewSummaryVec(
+			prometheus.SummaryOpts{
+				Name: fmt.Sprintf("faucet_request_stage_durations"),
+				Help: fmt.Sprintf("How long request stages take to process, partitioned by endpoint and stage"),
+			},
+			requestStageLatencyLabels,
+		),
+		Balances: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("faucet_balances"),
+				Help: fmt.Sprintf("Balances of faucet funds, partitioned by paratime"),
+			},
+			balanceLabels,
+		),
+		FeesSpent: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("faucet_fees_spent"),
+				Help: fmt.Sprintf("Transaction fees spent in base units, partitioned by paratime"),
+			},
+			feeLabels,
+		),
+		EffectiveMaxAmounts: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("faucet_effective_max_amounts"),
+				Help: fmt.Sprintf("Effective per-request maximum amounts in base units, partitioned by paratime"),
+			},
+			limitLabels,
+		),
+		PayoutFactors: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("faucet_payout_factors"),
+				Help: fmt.Sprintf("Factors the per-request maximum amounts are scaled by due to low reserves, partitioned by paratime"),
+			},
+			limitLabels,
+		),
+		DeniedRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("faucet_denied_requests"),
+				Help: fmt.Sprintf("How many requests were denied, partitioned by reason"),
+			},
+			denialLabels,
+		),
+		RateLimitedRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("faucet_rate_limited_requests"),
+				Help: fmt.Sprintf("How many requests were rejected by the rate limits, partitioned by limit"),
+			},
+			rateLimitLabels,
+		),
+	}
+	reg.MustRegister(metrics.Requests)
+	reg.MustRegister(metrics.RequestLatencies)
+	reg.MustRegister(metrics.RequestStageLatencies)
+	reg.MustRegister(metrics.Balances)
+	reg.MustRegister(metrics.FeesSpent)
+	reg.MustRegister(metrics.EffectiveMaxAmounts)
+	reg.MustRegister(metrics.PayoutFactors)
+	reg.MustRegister(metrics.DeniedRequests)
+	reg.MustRegister(metrics.RateLimitedRequests)
+	return &metrics
+}
+
+// Handler returns the HTTP handler serving the metrics of the default
+// registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,21 @@
+//go:build embedfrontend
+
+package webui
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// dist is the built faucet frontend, copied to `webui/dist` before
+// building.
+//
+//go:embed all:dist
+var dist embed.FS
+
+func init() {
+	var err error
+	if Assets, err = fs.Sub(dist, "dist"); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,240 @@
+// Package webui serves the faucet frontend's static assets, which may be
+// embedded in the binary by building with the `embedfrontend` tag.
+package webui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// indexFile is the page served for directories, and for unknown
+	// routes of the single page app.
+	indexFile = "index.html"
+
+	// runtimeConfigID is the id of the element carrying the runtime
+	// configuration.
+	runtimeConfigID = "faucet-config"
+
+	// cacheImmutable is the Cache-Control of assets with a content hash
+	// in their name.
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// cacheRevalidate is the Cache-Control of all other assets, which are
+	// revalidated with their ETag.
+	cacheRevalidate = "no-cache"
+
+	// etagLen is the length of the hex encoded content hash in ETags.
+	etagLen = 16
+
+	// pathPrefixAPI is the prefix of the API endpoints, which never fall
+	// back to the index page.
+	pathPrefixAPI = "/api/"
+)
+
+// Assets are the embedded frontend assets, or nil if the binary was built
+// without them.
+var Assets fs.FS
+
+// hashedName matches file names carrying a content hash, eg:
+// `main.6f8e4f1a.js`, which never change and may be cached forever.
+var hashedName = regexp.MustCompile(`\.[0-9a-f]{8,}\.[^./]+$`)
+
+// encodings are the supported precompressed variants, by order of
+// preference.
+var encodings = []struct {
+	name string
+	ext  string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// file is a cached asset.
+type file struct {
+	modTime time.Time
+	size    int64
+
+	content []byte
+	etag    string
+}
+
+// Handler serves the assets of a file system, with precompressed variants
+// and ETags, falling back to the index page for unknown routes.
+type Handler struct {
+	fsys          fs.FS
+	runtimeConfig []byte
+
+	lock  sync.Mutex
+	files map[string]*file
+}
+
+// NewHandler creates a new handler serving the assets of the file system.
+// If the runtime configuration is non-nil, it is injected into HTML pages
+// as a JSON element with the id `faucet-config`.
+func NewHandler(fsys fs.FS, runtimeConfig interface{}) (*Handler, error) {
+	h := &Handler{
+		fsys:  fsys,
+		files: make(map[string]*file),
+	}
+	if runtimeConfig != nil {
+		b, err := json.Marshal(runtimeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("webui: failed to encode runtime config: %w", err)
+		}
+		h.runtimeConfig = []byte(`<script id="` + runtimeConfigID + `" type="application/json">` + string(b) + `</script>`)
+	}
+	return h, nil
+}
+
+// ServeHTTP serves an asset.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	switch {
+	case name == "":
+		name = indexFile
+	case h.isDir(name):
+		name = path.Join(name, indexFile)
+	}
+	f, err := h.open(name)
+	if err != nil && path.Ext(name) == "" && !strings.HasPrefix(req.URL.Path, pathPrefixAPI) {
+		// Unknown routes of the single page app, but not missing assets
+		// or API endpoints, are served the index page.
+		name = indexFile
+		f, err = h.open(name)
+	}
+	if err != nil {
+		http.NotFound(w, req)
+		return
+	}
+
+	hdr := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(f.content)
+	}
+	hdr.Set("Content-Type", contentType)
+	switch {
+	case hashedName.MatchString(name):
+		hdr.Set("Cache-Control", cacheImmutable)
+	default:
+		hdr.Set("Cache-Control", cacheRevalidate)
+	}
+
+	// Serve a precompressed variant if the client accepts it.  Pages
+	// with the runtime configuration injected have none.
+	if !h.isInjected(contentType) {
+		hdr.Add("Vary", "Accept-Encoding")
+		for _, enc := range encodings {
+			if !acceptsEncoding(req.Header.Get("Accept-Encoding"), enc.name) {
+				continue
+			}
+			if variant, err := h.open(name + enc.ext); err == nil {
+				f = variant
+				hdr.Set("Content-Encoding", enc.name)
+				break
+			}
+		}
+	}
+
+	hdr.Set("ETag", f.etag)
+	http.ServeContent(w, req, name, f.modTime, bytes.NewReader(f.content))
+}
+
+// isDir returns true iff the name is a directory.
+func (h *Handler) isDir(name string) bool {
+	fi, err := fs.Stat(h.fsys, name)
+	return err == nil && fi.IsDir()
+}
+
+// isInjected returns true iff the runtime configuration is injected into
+// files of the content type.
+func (h *Handler) isInjected(contentType string) bool {
+	return h.runtimeConfig != nil && strings.HasPrefix(contentType, "text/html")
+}
+
+// open returns the file, reading it again if it has changed since it was
+// cached.
+func (h *Handler) open(name string) (*file, error) {
+	fi, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fs.ErrNotExist
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if f := h.files[name]; f != nil && f.modTime.Equal(fi.ModTime()) && f.size == fi.Size() {
+		return f, nil
+	}
+
+	rd, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+		content: content,
+	}
+	if h.isInjected(mime.TypeByExtension(path.Ext(name))) {
+		f.content = h.inject(content)
+	}
+	sum := sha256.Sum256(f.content)
+	f.etag = `"` + hex.EncodeToString(sum[:])[:etagLen] + `"`
+	h.files[name] = f
+
+	return f, nil
+}
+
+// inject inserts the runtime configuration at the end of the page's head,
+// or at the start of the page if it has no head.
+func (h *Handler) inject(page []byte) []byte {
+	idx := bytes.Index(bytes.ToLower(page), []byte("</head>"))
+	if idx < 0 {
+		idx = 0
+	}
+	out := make([]byte, 0, len(page)+len(h.runtimeConfig))
+	out = append(out, page[:idx]...)
+	out = append(out, h.runtimeConfig...)
+	return append(out, page[idx:]...)
+}
+
+// acceptsEncoding returns true iff the Accept-Encoding header accepts the
+// encoding.
+func acceptsEncoding(header, encoding string) bool {
+	for _, v := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		return !ok || strings.Trim(q, "0.") != ""
+	}
+	return false
+}
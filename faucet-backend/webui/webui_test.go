@@ -0,0 +1,212 @@
+package webui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const testIndex = "<html><head><title>Faucet</title></head><body></body></html>"
+
+func newTestFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":          {Data: []byte(testIndex)},
+		"index.html.gz":       {Data: []byte("gzipped index")},
+		"main.6f8e4f1a.js":    {Data: []byte("console.log('faucet');")},
+		"main.6f8e4f1a.js.br": {Data: []byte("brotli")},
+		"main.6f8e4f1a.js.gz": {Data: []byte("gzipped")},
+		"background.png":      {Data: []byte("png")},
+		"docs/index.html":     {Data: []byte("<html><body>docs</body></html>")},
+	}
+}
+
+func newTestHandler(t *testing.T, fsys fstest.MapFS, runtimeConfig interface{}) func(method, path string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h, err := NewHandler(fsys, runtimeConfig)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	return func(method, path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		for k, vs := range header {
+			req.Header[k] = vs
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+}
+
+func TestHandler(t *testing.T) {
+	fsys := newTestFS()
+	serve := newTestHandler(t, fsys, nil)
+
+	t.Run("Index", func(t *testing.T) {
+		// Directories and unknown routes of the single page app are
+		// served their index page.
+		for _, tc := range []struct {
+			path     string
+			expected string
+		}{
+			{"/", "<title>Faucet</title>"},
+			{"/index.html", "<title>Faucet</title>"},
+			{"/request/sapphire", "<title>Faucet</title>"},
+			{"/../request", "<title>Faucet</title>"},
+			{"/docs/", "docs"},
+			{"/docs", "docs"},
+		} {
+			w := serve(http.MethodGet, tc.path, nil)
+			if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tc.expected) {
+				t.Errorf("%s: unexpected response %d: %s", tc.path, w.Code, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+				t.Errorf("%s: unexpected content type: '%s'", tc.path, ct)
+			}
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Missing assets and API endpoints are not.
+		for _, path := range []string{"/missing.js", "/docs/missing.css", "/api/v1/missing", "/api/"} {
+			if w := serve(http.MethodGet, path, nil); w.Code != http.StatusNotFound {
+				t.Errorf("%s: unexpected status code %d", path, w.Code)
+			}
+		}
+	})
+
+	t.Run("Precompressed", func(t *testing.T) {
+		for _, tc := range []struct {
+			acceptEncoding string
+			encoding       string
+			expected       string
+		}{
+			{"", "", "console.log('faucet');"},
+			{"gzip, deflate, br", "br", "brotli"},
+			{"br;q=0, gzip", "gzip", "gzipped"},
+			{"br;q=0.0, gzip;q=0.5", "gzip", "gzipped"},
+			{"GZIP;q=1", "gzip", "gzipped"},
+			{"br;q=0, gzip;q=0", "", "console.log('faucet');"},
+			{"deflate", "", "console.log('faucet');"},
+		} {
+			w := serve(http.MethodGet, "/main.6f8e4f1a.js", http.Header{"Accept-Encoding": {tc.acceptEncoding}})
+			if w.Code != http.StatusOK || w.Body.String() != tc.expected || w.Header().Get("Content-Encoding") != tc.encoding {
+				t.Errorf("%q: unexpected response %d: %v: %s", tc.acceptEncoding, w.Code, w.Header(), w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+				t.Errorf("%q: unexpected content type: '%s'", tc.acceptEncoding, ct)
+			}
+			if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("%q: unexpected vary: '%s'", tc.acceptEncoding, vary)
+			}
+		}
+
+		// The variants are not served for other files.
+		if w := serve(http.MethodGet, "/background.png", http.Header{"Accept-Encoding": {"gzip"}}); w.Body.String() != "png" {
+			t.Errorf("no variant: unexpected response %v: %s", w.Header(), w.Body)
+		}
+	})
+
+	t.Run("Caching", func(t *testing.T) {
+		for _, tc := range []struct {
+			path     string
+			expected string
+		}{
+			{"/main.6f8e4f1a.js", cacheImmutable},
+			{"/background.png", cacheRevalidate},
+			{"/", cacheRevalidate},
+		} {
+			if cc := serve(http.MethodGet, tc.path, nil).Header().Get("Cache-Control"); cc != tc.expected {
+				t.Errorf("%s: unexpected cache control: '%s'", tc.path, cc)
+			}
+		}
+
+		w := serve(http.MethodGet, "/background.png", nil)
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("missing ETag")
+		}
+		if w = serve(http.MethodGet, "/background.png", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+			t.Errorf("conditional request: unexpected status code %d", w.Code)
+		}
+
+		// Each variant has its own ETag.
+		gz := serve(http.MethodGet, "/main.6f8e4f1a.js", http.Header{"Accept-Encoding": {"gzip"}})
+		identity := serve(http.MethodGet, "/main.6f8e4f1a.js", nil)
+		if gz.Header().Get("ETag") == identity.Header().Get("ETag") {
+			t.Errorf("precompressed variant has the identity ETag")
+		}
+
+		// Changed files are read again.
+		fsys["background.png"] = &fstest.MapFile{Data: []byte("new png"), ModTime: time.Now()}
+		if w = serve(http.MethodGet, "/background.png", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusOK || w.Body.String() != "new png" {
+			t.Errorf("changed file: unexpected response %d: %s", w.Code, w.Body)
+		}
+	})
+
+	t.Run("Methods", func(t *testing.T) {
+		if w := serve(http.MethodHead, "/", nil); w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("HEAD: unexpected response %d: %s", w.Code, w.Body)
+		}
+		w := serve(http.MethodPost, "/", nil)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("POST: unexpected response %d: %v", w.Code, w.Header())
+		}
+	})
+}
+
+func TestRuntimeConfig(t *testing.T) {
+	fsys := newTestFS()
+	fsys["nohead.html"] = &fstest.MapFile{Data: []byte("<p>no head</p>")}
+	serve := newTestHandler(t, fsys, map[string]string{"captcha_site_key": "site-key"})
+
+	const script = `<script id="faucet-config" type="application/json">{"captcha_site_key":"site-key"}</script>`
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{"/", "<html><head><title>Faucet</title>" + script + "</head><body></body></html>"},
+		{"/request/sapphire", "<html><head><title>Faucet</title>" + script + "</head><body></body></html>"},
+		{"/nohead.html", script + "<p>no head</p>"},
+	} {
+		// Pages with the configuration injected have no precompressed
+		// variant.
+		w := serve(http.MethodGet, tc.path, http.Header{"Accept-Encoding": {"gzip"}})
+		if w.Code != http.StatusOK || w.Body.String() != tc.expected || w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: unexpected response %d: %v: %s", tc.path, w.Code, w.Header(), w.Body)
+		}
+	}
+
+	// Other files are served as is.
+	if w := serve(http.MethodGet, "/main.6f8e4f1a.js", nil); w.Body.String() != "console.log('faucet');" {
+		t.Errorf("asset: unexpected response: %s", w.Body)
+	}
+
+	if _, err := NewHandler(fsys, func() {}); err == nil {
+		t.Errorf("NewHandler: unencodable runtime config accepted")
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate, gzip", true},
+		{" gzip ; q=0.8", true},
+		{"gzip;q=0", false},
+		{"gzip;q=0.000", false},
+		{"gzip;q=0.001", true},
+		{"gzipped", false},
+		{"*", false},
+	} {
+		if ok := acceptsEncoding(tc.header, "gzip"); ok != tc.expected {
+			t.Errorf("acceptsEncoding(%q): got %v, expected %v", tc.header, ok, tc.expected)
+		}
+	}
+}
@@ -22,6 +22,41 @@ function $() {
   }
 }
 
+/**
+ * Runtime configuration injected by the faucet backend, if any.
+ * @type {{ captcha_site_key?: string, paratimes?: string[] }}
+ */
+const runtimeConfig = (() => {
+  const element = document.querySelector('#faucet-config');
+  try {
+    return element ? JSON.parse(element.textContent) : {};
+  } catch (error) {
+    console.error(error);
+    return {};
+  }
+})();
+
+/** Apply the runtime configuration, and load reCAPTCHA with the site key. */
+function applyRuntimeConfig() {
+  if (runtimeConfig.paratimes) {
+    for (const option of [...$().paratime.options]) {
+      if (option.value && !runtimeConfig.paratimes.includes(option.value)) {
+        option.remove();
+      }
+    }
+  }
+
+  const captcha = document.querySelector('.g-recaptcha');
+  if (runtimeConfig.captcha_site_key) {
+    captcha.setAttribute('data-sitekey', runtimeConfig.captcha_site_key);
+  }
+  if (captcha.getAttribute('data-sitekey')) {
+    const script = document.createElement('script');
+    script.src = 'https://www.google.com/recaptcha/api.js';
+    script.async = true;
+    document.head.appendChild(script);
+  }
+}
 
 /**
  * @param {null | string} error
@@ -47,6 +82,30 @@ function showResponseStatus(error, success, requestBody) {
     }
   }
 }
+/**
+ * Follow the progress of a funding request until it completes.
+ * @param {string} requestId
+ * @param {URLSearchParams} requestBody
+ */
+function watchRequestProgress(requestId, requestBody) {
+  if (!window.EventSource) return;
+
+  const source = new EventSource('/api/v1/status/stream?id=' + encodeURIComponent(requestId));
+  source.addEventListener('status', (event) => {
+    const status = JSON.parse(event.data);
+    if (status.state === 'failed') {
+      showResponseStatus('Funding request failed: ' + (status.reason || 'unknown error'), null, requestBody);
+      source.close();
+    } else if (status.state === 'confirmed') {
+      showResponseStatus(null, 'Funding request completed', requestBody);
+      source.close();
+    } else {
+      showResponseStatus(null, 'Funding request ' + status.state, requestBody);
+    }
+  });
+  source.onerror = () => source.close();
+}
+
 /** @param {boolean} bool */
 function showLoading(bool) {
   $().request_form_submit.disabled = bool;
@@ -91,6 +150,9 @@ $().request_form.addEventListener('submit', (event) => {
       .then((responseJson) => {
         showLoading(false);
         showResponseStatus(null, responseJson.result, requestBody);
+        if (responseJson.request_id) {
+          watchRequestProgress(responseJson.request_id, requestBody);
+        }
       }, (error) => {
         showLoading(false);
         showResponseStatus(error, null, requestBody);
@@ -105,6 +167,7 @@ $().request_form.addEventListener('submit', (event) => {
   }
 });
 
+applyRuntimeConfig();
 preselectParatimeFromURL();
 preselectWalletFromURL();
 